@@ -50,6 +50,7 @@ var (
 	DeviceType       = &AssertionType{"device", []string{"brand-id", "model", "serial"}, assembleDevice}
 	SnapBuildType    = &AssertionType{"snap-build", []string{"snap-id", "snap-digest"}, assembleSnapBuild}
 	SnapRevisionType = &AssertionType{"snap-revision", []string{"snap-id", "snap-digest"}, assembleSnapRevision}
+	StoreType        = &AssertionType{"store", []string{"store"}, assembleStore}
 
 // ...
 )
@@ -60,6 +61,7 @@ var typeRegistry = map[string]*AssertionType{
 	DeviceType.Name:       DeviceType,
 	SnapBuildType.Name:    SnapBuildType,
 	SnapRevisionType.Name: SnapRevisionType,
+	StoreType.Name:        StoreType,
 }
 
 // Type returns the AssertionType with name or nil
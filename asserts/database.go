@@ -398,6 +398,23 @@ func CheckTimestampVsSigningKeyValidity(assert Assertion, signature Signature, s
 
 // XXX: keeping these in this form until we know better
 
+// A rootAuthorityChecker knows which authorities are root authorities,
+// i.e. have trusted account keys.
+type rootAuthorityChecker interface {
+	isRootAuthority(authorityID string) bool
+}
+
+// isRootAuthority returns whether the given authority has a trusted
+// account key.
+func (db *Database) isRootAuthority(authorityID string) bool {
+	found := false
+	err := db.trusted.Search(AccountKeyType, map[string]string{"account-id": authorityID}, func(Assertion) {
+		found = true
+	})
+
+	return err == nil && found
+}
+
 // A consistencyChecker performs further checks based on the full
 // assertion database knowledge and its own signing key.
 type consistencyChecker interface {
@@ -0,0 +1,99 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Store holds a store assertion, which is a statement binding a store
+// identifier to the location of the store.
+type Store struct {
+	assertionBase
+	url       *url.URL
+	timestamp time.Time
+}
+
+// Store returns the identifier of the store.
+func (store *Store) Store() string {
+	return store.Header("store")
+}
+
+// URL returns the base URL of the store API.
+func (store *Store) URL() *url.URL {
+	return store.url
+}
+
+// Timestamp returns the time when the store assertion was issued.
+func (store *Store) Timestamp() time.Time {
+	return store.timestamp
+}
+
+// Implement further consistency checks.
+func (store *Store) checkConsistency(db RODatabase, acck *AccountKey) error {
+	// the root authority can vouch for any store
+	if checker, ok := db.(rootAuthorityChecker); ok && checker.isRootAuthority(store.AuthorityID()) {
+		return nil
+	}
+
+	// a brand only for the store its models use
+	_, err := db.FindMany(ModelType, map[string]string{
+		"brand-id": store.AuthorityID(),
+		"store":    store.Store(),
+	})
+	if err == ErrNotFound {
+		return fmt.Errorf("store %q can only be vouched for by the root authority or the brand of a model using it, not by %q", store.Store(), store.AuthorityID())
+	}
+
+	return err
+}
+
+// sanity
+var _ consistencyChecker = (*Store)(nil)
+
+func assembleStore(assert assertionBase) (Assertion, error) {
+	_, err := checkMandatory(assert.headers, "store")
+	if err != nil {
+		return nil, err
+	}
+
+	urlStr, err := checkMandatory(assert.headers, "url")
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(urlStr)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q header must be an absolute http(s) URL: %q", "url", urlStr)
+	}
+
+	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
+	if err != nil {
+		return nil, err
+	}
+
+	// ignore extra headers and non-empty body for future compatibility
+	return &Store{
+		assertionBase: assert,
+		url:           u,
+		timestamp:     timestamp,
+	}, nil
+}
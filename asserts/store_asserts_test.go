@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package asserts_test
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/asserts"
+)
+
+type storeSuite struct {
+	ts     time.Time
+	tsLine string
+}
+
+var _ = Suite(&storeSuite{})
+
+func (sts *storeSuite) SetUpSuite(c *C) {
+	sts.ts = time.Now().Truncate(time.Second).UTC()
+	sts.tsLine = "timestamp: " + sts.ts.Format(time.RFC3339) + "\n"
+}
+
+const storeExample = "type: store\n" +
+	"authority-id: canonical\n" +
+	"store: brand-store\n" +
+	"url: https://store.example.com/api/v1/\n" +
+	"TSLINE" +
+	"body-length: 0" +
+	"\n\n" +
+	"openpgp c2ln"
+
+func (sts *storeSuite) TestDecodeOK(c *C) {
+	encoded := strings.Replace(storeExample, "TSLINE", sts.tsLine, 1)
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	c.Check(a.Type(), Equals, asserts.StoreType)
+	store := a.(*asserts.Store)
+	c.Check(store.AuthorityID(), Equals, "canonical")
+	c.Check(store.Timestamp(), Equals, sts.ts)
+	c.Check(store.Store(), Equals, "brand-store")
+	c.Check(store.URL().String(), Equals, "https://store.example.com/api/v1/")
+}
+
+const (
+	storeErrPrefix = "assertion store: "
+)
+
+func (sts *storeSuite) TestDecodeInvalidMandatory(c *C) {
+	encoded := strings.Replace(storeExample, "TSLINE", sts.tsLine, 1)
+
+	for _, mandatory := range []string{"store", "url", "timestamp"} {
+		invalid := strings.Replace(encoded, mandatory+":", "xyz:", 1)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, fmt.Sprintf("%s%q header is mandatory", storeErrPrefix, mandatory))
+	}
+}
+
+func (sts *storeSuite) TestDecodeInvalid(c *C) {
+	encoded := strings.Replace(storeExample, "TSLINE", sts.tsLine, 1)
+
+	invalidTests := []struct{ original, invalid, expectedErr string }{
+		{"url: https://store.example.com/api/v1/\n", "url: /api/v1/\n", `"url" header must be an absolute http\(s\) URL: "/api/v1/"`},
+		{"url: https://store.example.com/api/v1/\n", "url: ftp://store.example.com/\n", `"url" header must be an absolute http\(s\) URL: "ftp://store.example.com/"`},
+		{sts.tsLine, "timestamp: 12:30\n", `"timestamp" header is not a RFC3339 date: .*`},
+	}
+
+	for _, test := range invalidTests {
+		invalid := strings.Replace(encoded, test.original, test.invalid, 1)
+		_, err := asserts.Decode([]byte(invalid))
+		c.Check(err, ErrorMatches, storeErrPrefix+test.expectedErr)
+	}
+}
+
+func (sts *storeSuite) TestStoreCheck(c *C) {
+	ex, err := asserts.Decode([]byte(strings.Replace(storeExample, "TSLINE", sts.tsLine, 1)))
+	c.Assert(err, IsNil)
+
+	signingKeyID, accSignDB, db := makeSignAndCheckDbWithAccountKey(c, "canonical")
+
+	headers := ex.Headers()
+	headers["timestamp"] = "2015-11-25T20:00:00Z"
+	store, err := accSignDB.Sign(asserts.StoreType, headers, nil, signingKeyID)
+	c.Assert(err, IsNil)
+
+	err = db.Check(store)
+	c.Assert(err, IsNil)
+}
+
+func (sts *storeSuite) signStore(c *C, authorityID, signingKeyID string, signDB *asserts.Database) asserts.Assertion {
+	ex, err := asserts.Decode([]byte(strings.Replace(storeExample, "TSLINE", sts.tsLine, 1)))
+	c.Assert(err, IsNil)
+
+	headers := ex.Headers()
+	headers["authority-id"] = authorityID
+	headers["timestamp"] = "2015-11-25T20:00:00Z"
+	store, err := signDB.Sign(asserts.StoreType, headers, nil, signingKeyID)
+	c.Assert(err, IsNil)
+
+	return store
+}
+
+func (sts *storeSuite) TestStoreCheckBrandOfModel(c *C) {
+	signingKeyID, accSignDB, db := makeSignAndCheckDbWithAccountKey(c, "brand-id1")
+
+	model, err := accSignDB.Sign(asserts.ModelType, map[string]string{
+		"authority-id":   "brand-id1",
+		"brand-id":       "brand-id1",
+		"model":          "baz-3000",
+		"series":         "16",
+		"os":             "core",
+		"architecture":   "amd64",
+		"gadget":         "brand-gadget",
+		"kernel":         "baz-linux",
+		"store":          "brand-store",
+		"class":          "fixed",
+		"allowed-modes":  "",
+		"required-snaps": "",
+		"timestamp":      "2015-11-25T20:00:00Z",
+	}, nil, signingKeyID)
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(model), IsNil)
+
+	err = db.Check(sts.signStore(c, "brand-id1", signingKeyID, accSignDB))
+	c.Check(err, IsNil)
+}
+
+func (sts *storeSuite) TestStoreCheckOtherAuthority(c *C) {
+	signingKeyID, accSignDB, db := makeSignAndCheckDbWithAccountKey(c, "brand-id1")
+
+	// brand-id1 has no model using brand-store
+	err := db.Check(sts.signStore(c, "brand-id1", signingKeyID, accSignDB))
+	c.Check(err, ErrorMatches, `store assertion violates other knowledge: store "brand-store" can only be vouched for by the root authority or the brand of a model using it, not by "brand-id1"`)
+}
@@ -23,6 +23,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -39,13 +40,34 @@ type Snap struct {
 	Version       string `json:"version"`
 }
 
-// SnapFilter is used to filter snaps by source, name and/or type
+// SnapFilter is used to filter snaps by source, name and/or type, and to
+// page through and sort the snaps of the store
 type SnapFilter struct {
 	Sources []string
 	Types   []string
 	Query   string
+	Sort    string
+	Page    int
+	Size    int
 }
 
+// SnapPage is a page of snaps as returned by FilterSnapsPage
+type SnapPage struct {
+	Snaps map[string]*Snap
+	// Order holds the names of the snaps in the requested sort order,
+	// it is empty if no sort order was requested
+	Order []string
+	Page  int
+	Pages int
+}
+
+// The keys snaps of the store can be sorted by.
+const (
+	SortByName        = "name"
+	SortByLastUpdated = "last_updated"
+	SortByRatings     = "ratings"
+)
+
 // Statuses and types a snap may have.
 const (
 	StatusNotInstalled = "not installed"
@@ -69,6 +91,17 @@ func (client *Client) Snaps() (map[string]*Snap, error) {
 // FilterSnaps returns a list of snaps per Snaps() but filtered by source, name
 // and/or type
 func (client *Client) FilterSnaps(filter SnapFilter) (map[string]*Snap, error) {
+	page, err := client.FilterSnapsPage(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Snaps, nil
+}
+
+// FilterSnapsPage returns the page of snaps selected by the filter, along
+// with the order they were sorted in and the number of pages there are
+func (client *Client) FilterSnapsPage(filter SnapFilter) (*SnapPage, error) {
 	q := url.Values{}
 
 	if filter.Query != "" {
@@ -83,28 +116,60 @@ func (client *Client) FilterSnaps(filter SnapFilter) (map[string]*Snap, error) {
 		q.Set("types", strings.Join(filter.Types, ","))
 	}
 
-	return client.snapsFromPath("/2.0/snaps", q)
+	if filter.Sort != "" {
+		q.Set("sort", filter.Sort)
+	}
+
+	if filter.Page > 0 {
+		q.Set("page", strconv.Itoa(filter.Page))
+	}
+
+	if filter.Size > 0 {
+		q.Set("size", strconv.Itoa(filter.Size))
+	}
+
+	return client.snapPageFromPath("/2.0/snaps", q)
 }
 
 func (client *Client) snapsFromPath(path string, query url.Values) (map[string]*Snap, error) {
+	page, err := client.snapPageFromPath(path, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Snaps, nil
+}
+
+func (client *Client) snapPageFromPath(path string, query url.Values) (*SnapPage, error) {
 	const errPrefix = "cannot list snaps"
 
-	var result map[string]json.RawMessage
+	var result struct {
+		Snaps  json.RawMessage `json:"snaps"`
+		Order  []string        `json:"order"`
+		Paging struct {
+			Page  int `json:"page"`
+			Pages int `json:"pages"`
+		} `json:"paging"`
+	}
 	if err := client.doSync("GET", path, query, nil, &result); err != nil {
 		return nil, fmt.Errorf("%s: %s", errPrefix, err)
 	}
 
-	snapsJSON := result["snaps"]
-	if snapsJSON == nil {
+	if result.Snaps == nil {
 		return nil, fmt.Errorf("%s: response has no snaps", errPrefix)
 	}
 
 	var snaps map[string]*Snap
-	if err := json.Unmarshal(snapsJSON, &snaps); err != nil {
+	if err := json.Unmarshal(result.Snaps, &snaps); err != nil {
 		return nil, fmt.Errorf("%s: failed to unmarshal snaps: %v", errPrefix, err)
 	}
 
-	return snaps, nil
+	return &SnapPage{
+		Snaps: snaps,
+		Order: result.Order,
+		Page:  result.Paging.Page,
+		Pages: result.Paging.Pages,
+	}, nil
 }
 
 // Snap returns the most recently published revision of the snap with the
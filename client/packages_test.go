@@ -105,6 +105,8 @@ func (cs *clientSuite) TestClientFilterSnaps(c *check.C) {
 		{client.SnapFilter{Sources: []string{"local"}, Types: []string{"app"}}, "/2.0/snaps", "sources=local&types=app"},
 		{client.SnapFilter{Query: "foo"}, "/2.0/snaps", "q=foo"},
 		{client.SnapFilter{Query: "foo", Sources: []string{"local"}, Types: []string{"app"}}, "/2.0/snaps", "q=foo&sources=local&types=app"},
+		{client.SnapFilter{Sort: client.SortByRatings}, "/2.0/snaps", "sort=ratings"},
+		{client.SnapFilter{Page: 2, Size: 10}, "/2.0/snaps", "page=2&size=10"},
 	}
 
 	for _, tt := range filterTests {
@@ -114,6 +116,26 @@ func (cs *clientSuite) TestClientFilterSnaps(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientFilterSnapsPage(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {
+			"snaps": {
+				"foo.bar": {"name": "foo", "origin": "bar"},
+				"baz.bar": {"name": "baz", "origin": "bar"}
+			},
+			"order": ["foo.bar", "baz.bar"],
+			"paging": {"count": 2, "page": 2, "pages": 3}
+		}
+	}`
+	page, err := cs.cli.FilterSnapsPage(client.SnapFilter{Sort: client.SortByName, Page: 2, Size: 2})
+	c.Assert(err, check.IsNil)
+	c.Check(page.Snaps, check.HasLen, 2)
+	c.Check(page.Order, check.DeepEquals, []string{"foo.bar", "baz.bar"})
+	c.Check(page.Page, check.Equals, 2)
+	c.Check(page.Pages, check.Equals, 3)
+}
+
 const (
 	pkgName = "chatroom.ogra"
 )
@@ -22,6 +22,7 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"net/url"
 )
 
 // Skill represents a capacity offered by a snap.
@@ -50,6 +51,26 @@ type SkillGrants struct {
 	GrantedTo []Slot `json:"granted_to"`
 }
 
+// SkillRef identifies a skill or a slot by snap and name.
+type SkillRef struct {
+	Snap string `json:"snap"`
+	Name string `json:"name"`
+}
+
+// SkillGraphGrant represents a single grant in the skill graph.
+// The skill is the offering side and the slot is the using side.
+type SkillGraphGrant struct {
+	Skill SkillRef `json:"skill"`
+	Slot  SkillRef `json:"slot"`
+}
+
+// SkillGraph represents all the skills, slots and grants in the system.
+type SkillGraph struct {
+	Skills []Skill           `json:"skills"`
+	Slots  []Slot            `json:"slots"`
+	Grants []SkillGraphGrant `json:"grants"`
+}
+
 // SkillAction represents an action performed on the skill system.
 type SkillAction struct {
 	Action string `json:"action"`
@@ -63,6 +84,14 @@ func (client *Client) AllSkills() (grants []SkillGrants, err error) {
 	return
 }
 
+// SkillGraph returns all the skills, slots and grants in the system.
+func (client *Client) SkillGraph() (graph *SkillGraph, err error) {
+	query := url.Values{}
+	query.Set("view", "graph")
+	err = client.doSync("GET", "/2.0/skills", query, nil, &graph)
+	return
+}
+
 // performSkillAction performs a single action on the skill system.
 func (client *Client) performSkillAction(sa *SkillAction) error {
 	b, err := json.Marshal(sa)
@@ -68,6 +68,55 @@ func (cs *clientSuite) TestClientAllSkills(c *check.C) {
 	})
 }
 
+func (cs *clientSuite) TestClientSkillGraph(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {
+			"skills": [
+				{"snap": "canonical-pi2", "name": "pin-13", "type": "bool-file",
+				 "attrs": {"path": "/sys/class/gpio/gpio13/value"}}
+			],
+			"slots": [
+				{"snap": "keyboard-lights", "name": "capslock-led", "type": "bool-file",
+				 "apps": ["capslock"]}
+			],
+			"grants": [
+				{"skill": {"snap": "canonical-pi2", "name": "pin-13"},
+				 "slot": {"snap": "keyboard-lights", "name": "capslock-led"}}
+			]
+		}
+	}`
+	graph, err := cs.cli.SkillGraph()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/2.0/skills")
+	c.Check(cs.req.URL.Query().Get("view"), check.Equals, "graph")
+	c.Check(graph, check.DeepEquals, &client.SkillGraph{
+		Skills: []client.Skill{
+			{
+				Snap:  "canonical-pi2",
+				Name:  "pin-13",
+				Type:  "bool-file",
+				Attrs: map[string]interface{}{"path": "/sys/class/gpio/gpio13/value"},
+			},
+		},
+		Slots: []client.Slot{
+			{
+				Snap: "keyboard-lights",
+				Name: "capslock-led",
+				Type: "bool-file",
+				Apps: []string{"capslock"},
+			},
+		},
+		Grants: []client.SkillGraphGrant{
+			{
+				Skill: client.SkillRef{Snap: "canonical-pi2", Name: "pin-13"},
+				Slot:  client.SkillRef{Snap: "keyboard-lights", Name: "capslock-led"},
+			},
+		},
+	})
+}
+
 func (cs *clientSuite) TestClientGrantCallsEndpoint(c *check.C) {
 	_ = cs.cli.Grant("producer", "skill", "consumer", "slot")
 	c.Check(cs.req.Method, check.Equals, "POST")
@@ -20,6 +20,8 @@
 package client
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strings"
 )
@@ -33,6 +35,51 @@ func (client *Client) AddSnap(name string) (string, error) {
 	return client.doAsync("POST", path, nil, body)
 }
 
+// AddSnaps adds the snaps with the given names from the given channel in
+// a single background operation, returning its UUID upon success. While
+// the operation runs its output is the state of each of the snaps.
+func (client *Client) AddSnaps(names []string, channel string) (string, error) {
+	action := struct {
+		Action  string   `json:"action"`
+		Snaps   []string `json:"snaps"`
+		Channel string   `json:"channel,omitempty"`
+	}{
+		Action:  "install",
+		Snaps:   names,
+		Channel: channel,
+	}
+
+	return client.install(&action)
+}
+
+// AddSnapFromURL adds the snap downloaded from the given http or https
+// URL, returning the UUID of the background operation upon success. If
+// sha512 is not empty the download must have that sha512.
+func (client *Client) AddSnapFromURL(snapURL, sha512 string) (string, error) {
+	action := struct {
+		Action string `json:"action"`
+		URL    string `json:"url"`
+		Sha512 string `json:"sha512,omitempty"`
+	}{
+		Action: "install",
+		URL:    snapURL,
+		Sha512: sha512,
+	}
+
+	return client.install(&action)
+}
+
+// install sends the given install instruction to the daemon, returning
+// the UUID of the background operation upon success.
+func (client *Client) install(action interface{}) (string, error) {
+	body, err := json.Marshal(action)
+	if err != nil {
+		return "", err
+	}
+
+	return client.doAsync("POST", "/2.0/install", nil, bytes.NewReader(body))
+}
+
 // RemoveSnap removes the snap with the given name, returning the UUID of the
 // background operation upon success.
 func (client *Client) RemoveSnap(name string) (string, error) {
@@ -43,12 +90,23 @@ func (client *Client) RemoveSnap(name string) (string, error) {
 }
 
 // RefreshSnap refreshes the snap with the given name, returning the UUID of the
-// background operation upon success.
-func (client *Client) RefreshSnap(name string) (string, error) {
+// background operation upon success. If a channel is given the snap is
+// switched to it.
+func (client *Client) RefreshSnap(name, channel string) (string, error) {
 	path := fmt.Sprintf("/2.0/snaps/%s", name)
-	body := strings.NewReader(`{"action":"update"}`)
-
-	return client.doAsync("POST", path, nil, body)
+	action := struct {
+		Action  string `json:"action"`
+		Channel string `json:"channel,omitempty"`
+	}{
+		Action:  "update",
+		Channel: channel,
+	}
+	body, err := json.Marshal(&action)
+	if err != nil {
+		return "", err
+	}
+
+	return client.doAsync("POST", path, nil, bytes.NewReader(body))
 }
 
 // PurgeSnap purges the snap with the given name, returning the UUID of the
@@ -36,7 +36,7 @@ var ops = []struct {
 }{
 	{(*client.Client).AddSnap, "install"},
 	{(*client.Client).RemoveSnap, "remove"},
-	{(*client.Client).RefreshSnap, "update"},
+	{func(cli *client.Client, name string) (string, error) { return cli.RefreshSnap(name, "") }, "update"},
 	{(*client.Client).PurgeSnap, "purge"},
 	{(*client.Client).RollbackSnap, "rollback"},
 	{(*client.Client).ActivateSnap, "activate"},
@@ -139,3 +139,57 @@ func (cs *clientSuite) TestClientOpSnap(c *check.C) {
 		c.Check(uuid, check.Equals, "5a70dffa-66b3-3567-d728-55b0da48bdc7", check.Commentf(s.action))
 	}
 }
+
+func (cs *clientSuite) TestClientRefreshSnapChannel(c *check.C) {
+	cs.rsp = `{
+		"result": {
+			"resource": "/2.0/operations/5a70dffa-66b3-3567-d728-55b0da48bdc7"
+		},
+		"status_code": 202,
+		"type": "async"
+	}`
+	_, err := cs.cli.RefreshSnap(pkgName, "beta")
+	c.Assert(err, check.IsNil)
+
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"update","channel":"beta"}`)
+}
+
+func (cs *clientSuite) TestClientAddSnaps(c *check.C) {
+	cs.rsp = `{
+		"result": {
+			"resource": "/2.0/operations/5a70dffa-66b3-3567-d728-55b0da48bdc7"
+		},
+		"status_code": 202,
+		"type": "async"
+	}`
+	uuid, err := cs.cli.AddSnaps([]string{"foo", "bar.baz"}, "edge")
+	c.Assert(err, check.IsNil)
+	c.Check(uuid, check.Equals, "5a70dffa-66b3-3567-d728-55b0da48bdc7")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/2.0/install")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"install","snaps":["foo","bar.baz"],"channel":"edge"}`)
+}
+
+func (cs *clientSuite) TestClientAddSnapFromURL(c *check.C) {
+	cs.rsp = `{
+		"result": {
+			"resource": "/2.0/operations/5a70dffa-66b3-3567-d728-55b0da48bdc7"
+		},
+		"status_code": 202,
+		"type": "async"
+	}`
+	uuid, err := cs.cli.AddSnapFromURL("https://example.com/foo.snap", "abcd")
+	c.Assert(err, check.IsNil)
+	c.Check(uuid, check.Equals, "5a70dffa-66b3-3567-d728-55b0da48bdc7")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/2.0/install")
+	body, err := ioutil.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(string(body), check.Equals, `{"action":"install","url":"https://example.com/foo.snap","sha512":"abcd"}`)
+}
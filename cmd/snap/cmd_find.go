@@ -22,6 +22,7 @@ package main
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/ubuntu-core/snappy/client"
@@ -31,9 +32,21 @@ import (
 var shortFindHelp = i18n.G("Finds packages to install")
 var longFindHelp = i18n.G(`
 The find command queries the store for available packages.
+
+$ snap find --type=<type>[,<type>...] --sort=name|last_updated|ratings
+
+Lists only packages of the given types, in the given order.
+
+$ snap find --page=<page> --size=<size>
+
+Lists the given page of the results, with size packages per page.
 `)
 
 type cmdFind struct {
+	Type       string `long:"type" description:"only list packages of these comma-separated types"`
+	Sort       string `long:"sort" description:"sort the packages by name, last_updated or ratings"`
+	Page       int    `long:"page" description:"list the given page of the results"`
+	Size       int    `long:"size" description:"list this many packages per page"`
 	Positional struct {
 		Query string `positional-arg-name:"<query>"`
 	} `positional-args:"yes"`
@@ -46,15 +59,28 @@ func init() {
 }
 
 func (x *cmdFind) Execute([]string) error {
+	switch x.Sort {
+	case "", client.SortByName, client.SortByLastUpdated, client.SortByRatings:
+	default:
+		return fmt.Errorf(i18n.G("unsupported sort order %q, use name, last_updated or ratings"), x.Sort)
+	}
+
 	cli := Client()
 	filter := client.SnapFilter{
 		Query:   x.Positional.Query,
 		Sources: []string{"store"},
+		Sort:    x.Sort,
+		Page:    x.Page,
+		Size:    x.Size,
 	}
-	snaps, err := cli.FilterSnaps(filter)
+	if x.Type != "" {
+		filter.Types = strings.Split(x.Type, ",")
+	}
+	page, err := cli.FilterSnapsPage(filter)
 	if err != nil {
 		return err
 	}
+	snaps := page.Snaps
 
 	if len(snaps) == 0 {
 		if filter.Query == "" {
@@ -64,13 +90,15 @@ func (x *cmdFind) Execute([]string) error {
 		return fmt.Errorf("no snaps found for %q", filter.Query)
 	}
 
-	names := make([]string, len(snaps))
-	i := 0
-	for k := range snaps {
-		names[i] = k
-		i++
+	// keep the order of the store if one was asked for
+	names := page.Order
+	if len(names) == 0 {
+		names = make([]string, 0, len(snaps))
+		for k := range snaps {
+			names = append(names, k)
+		}
+		sort.Strings(names)
 	}
-	sort.Strings(names)
 
 	w := tabwriter.NewWriter(Stdout, 5, 3, 1, ' ', 0)
 	defer w.Flush()
@@ -78,9 +106,17 @@ func (x *cmdFind) Execute([]string) error {
 	fmt.Fprintln(w, i18n.G("Name\tVersion\tSummary"))
 
 	for _, name := range names {
-		snap := snaps[name]
+		snap, ok := snaps[name]
+		if !ok {
+			continue
+		}
 		fmt.Fprintf(w, "%s\t%s\t%s\n", name, snap.Version, snap.Description)
 	}
 
+	if page.Pages > 1 {
+		w.Flush()
+		fmt.Fprintf(Stdout, i18n.G("Page %d of %d\n"), page.Page, page.Pages)
+	}
+
 	return nil
 }
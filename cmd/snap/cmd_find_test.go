@@ -0,0 +1,64 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"net/http"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/ubuntu-core/snappy/cmd/snap"
+)
+
+func (s *SnapSuite) TestFindPagedAndSorted(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/2.0/snaps")
+		q := r.URL.Query()
+		c.Check(q.Get("sources"), Equals, "store")
+		c.Check(q.Get("types"), Equals, "app,framework")
+		c.Check(q.Get("sort"), Equals, "ratings")
+		c.Check(q.Get("page"), Equals, "2")
+		c.Check(q.Get("size"), Equals, "2")
+		fmt.Fprintln(w, `{"type": "sync", "result": {
+			"snaps": {
+				"foo.bar": {"name": "foo", "origin": "bar", "version": "1.0", "description": "Foo"},
+				"baz.bar": {"name": "baz", "origin": "bar", "version": "2.0", "description": "Baz"}
+			},
+			"order": ["foo.bar", "baz.bar"],
+			"paging": {"count": 2, "page": 2, "pages": 3}
+		}}`)
+	})
+	rest, err := Parser().ParseArgs([]string{"find", "--type=app,framework", "--sort=ratings", "--page=2", "--size=2"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals, ""+
+		"Name    Version Summary\n"+
+		"foo.bar 1.0     Foo\n"+
+		"baz.bar 2.0     Baz\n"+
+		"Page 2 of 3\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestFindBadSort(c *C) {
+	_, err := Parser().ParseArgs([]string{"find", "--sort=popularity"})
+	c.Check(err, ErrorMatches, `unsupported sort order "popularity", use name, last_updated or ratings`)
+}
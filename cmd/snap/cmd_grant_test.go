@@ -149,3 +149,14 @@ func (s *SnapSuite) TestGrantImplicitSkillImplicitSlot(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(rest, DeepEquals, []string{})
 }
+
+func (s *SnapSuite) TestGrantReportsIncompatibility(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/2.0/skills")
+		w.WriteHeader(400)
+		fmt.Fprintln(w, `{"type":"error", "result":{"message":"cannot grant skill \"producer:skill\" to \"consumer:slot\": slot requires version 2"}}`)
+	})
+	_, err := Parser().ParseArgs([]string{"grant", "producer:skill", "consumer:slot"})
+	c.Assert(err, ErrorMatches, `cannot grant skill "producer:skill" to "consumer:slot": slot requires version 2`)
+}
@@ -20,7 +20,10 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/ubuntu-core/snappy/i18n"
@@ -28,6 +31,8 @@ import (
 
 type cmdSkills struct {
 	Type        string `long:"type" description:"constrain listing to skills of this type"`
+	Verbose     bool   `long:"verbose" description:"show the type and attributes of each skill and apps of each grant"`
+	Format      string `long:"format" description:"output the whole skill graph in the given format (dot or json)"`
 	Positionals struct {
 		Query SnapAndName `positional-arg-name:"<snap>:<skill>" description:"snap or snap:name" skip-help:"true"`
 	} `positional-args:"true"`
@@ -50,6 +55,16 @@ Lists the skills offered and used by the specified snap.
 $ snap skills --type=<type> [<snap name>]
 
 Lists only skills of the specified type.
+
+$ snap skills --verbose
+
+Lists the type and the attributes of each skill as well. Each grant is
+followed by the list of apps that can use it.
+
+$ snap skills --format=dot|json
+
+Outputs the whole graph of skills, slots and grants, either as a Graphviz
+dot digraph or as structured JSON.
 `)
 
 func init() {
@@ -59,10 +74,21 @@ func init() {
 }
 
 func (x *cmdSkills) Execute(args []string) error {
+	switch x.Format {
+	case "":
+	case "dot", "json":
+		return x.showGraph()
+	default:
+		return fmt.Errorf(i18n.G("unsupported output format %q, use dot or json"), x.Format)
+	}
 	skills, err := Client().AllSkills()
 	if err == nil {
 		w := tabwriter.NewWriter(Stdout, 0, 4, 1, ' ', 0)
-		fmt.Fprintln(w, i18n.G("Skill\tGranted To"))
+		if x.Verbose {
+			fmt.Fprintln(w, i18n.G("Skill\tType\tAttributes\tGranted To"))
+		} else {
+			fmt.Fprintln(w, i18n.G("Skill\tGranted To"))
+		}
 		defer w.Flush()
 		for _, skill := range skills {
 			if x.Positionals.Query.Snap != "" && x.Positionals.Query.Snap != skill.Snap {
@@ -75,6 +101,9 @@ func (x *cmdSkills) Execute(args []string) error {
 				continue
 			}
 			fmt.Fprintf(w, "%s:%s\t", skill.Snap, skill.Name)
+			if x.Verbose {
+				fmt.Fprintf(w, "%s\t%s\t", skill.Type, formatAttrs(skill.Attrs))
+			}
 			for i := 0; i < len(skill.GrantedTo); i++ {
 				if i > 0 {
 					fmt.Fprint(w, ",")
@@ -84,9 +113,66 @@ func (x *cmdSkills) Execute(args []string) error {
 				} else {
 					fmt.Fprintf(w, "%s", skill.GrantedTo[i].Snap)
 				}
+				if x.Verbose && len(skill.GrantedTo[i].Apps) > 0 {
+					fmt.Fprintf(w, "(%s)", strings.Join(skill.GrantedTo[i].Apps, ","))
+				}
 			}
 			fmt.Fprintf(w, "\n")
 		}
 	}
 	return err
 }
+
+// formatAttrs returns a compact, sorted, key=value representation of attributes.
+func formatAttrs(attrs map[string]interface{}) string {
+	if len(attrs) == 0 {
+		return "-"
+	}
+	var pairs []string
+	for key, value := range attrs {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (x *cmdSkills) showGraph() error {
+	graph, err := Client().SkillGraph()
+	if err != nil {
+		return err
+	}
+	if x.Format == "json" {
+		b, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, "%s\n", b)
+		return nil
+	}
+	// Skills (the offering side) are boxes, slots (the using side) are
+	// ellipses and each grant is an edge from a skill to a slot.
+	fmt.Fprintln(Stdout, "digraph skills {")
+	for _, skill := range graph.Skills {
+		fmt.Fprintf(Stdout, "\t%q [shape=box, label=%q];\n",
+			"skill:"+skill.Snap+":"+skill.Name, dotLabel(skill.Snap, skill.Name, skill.Type, skill.Attrs))
+	}
+	for _, slot := range graph.Slots {
+		fmt.Fprintf(Stdout, "\t%q [shape=ellipse, label=%q];\n",
+			"slot:"+slot.Snap+":"+slot.Name, dotLabel(slot.Snap, slot.Name, slot.Type, slot.Attrs))
+	}
+	for _, grant := range graph.Grants {
+		fmt.Fprintf(Stdout, "\t%q -> %q;\n",
+			"skill:"+grant.Skill.Snap+":"+grant.Skill.Name, "slot:"+grant.Slot.Snap+":"+grant.Slot.Name)
+	}
+	fmt.Fprintln(Stdout, "}")
+	return nil
+}
+
+// dotLabel returns the label of a node in the dot representation of the skill graph.
+func dotLabel(snap, name, typeName string, attrs map[string]interface{}) string {
+	label := fmt.Sprintf("%s:%s\n%s", snap, name, typeName)
+	if len(attrs) > 0 {
+		label += "\n" + formatAttrs(attrs)
+	}
+	return label
+}
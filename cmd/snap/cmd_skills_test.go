@@ -20,6 +20,7 @@
 package main_test
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"net/http"
 
@@ -49,11 +50,25 @@ $ snap skills --type=<type> [<snap name>]
 
 Lists only skills of the specified type.
 
+$ snap skills --verbose
+
+Lists the type and the attributes of each skill as well. Each grant is
+followed by the list of apps that can use it.
+
+$ snap skills --format=dot|json
+
+Outputs the whole graph of skills, slots and grants, either as a Graphviz
+dot digraph or as structured JSON.
+
 Help Options:
   -h, --help                Show this help message
 
 [skills command options]
           --type=           constrain listing to skills of this type
+          --verbose         show the type and attributes of each skill and apps
+                            of each grant
+          --format=         output the whole skill graph in the given format
+                            (dot or json)
 `
 	rest, _ := Parser().ParseArgs([]string{"skills", "--help"})
 	// TODO: Re-enable this after go-flags is updated.
@@ -355,3 +370,125 @@ func (s *SnapSuite) TestSkillsOfSpecificSnapAndSkill(c *C) {
 	c.Assert(s.Stdout(), Equals, expectedStdout)
 	c.Assert(s.Stderr(), Equals, "")
 }
+
+func (s *SnapSuite) TestSkillsVerbose(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/2.0/skills")
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type": "sync",
+			"result": []client.SkillGrants{
+				{
+					Skill: client.Skill{
+						Snap:  "canonical-pi2",
+						Name:  "debug-console",
+						Type:  "serial-port",
+						Label: "Serial port on the expansion header",
+					},
+					GrantedTo: []client.Slot{},
+				},
+				{
+					Skill: client.Skill{
+						Snap: "canonical-pi2",
+						Name: "pin-13",
+						Type: "bool-file",
+						Attrs: map[string]interface{}{
+							"path": "/sys/class/gpio/gpio13/value",
+							"mode": "out",
+						},
+						Label: "Pin 13",
+					},
+					GrantedTo: []client.Slot{
+						{
+							Snap: "keyboard-lights",
+							Name: "capslock-led",
+							Apps: []string{"capslock", "numlock"},
+						},
+					},
+				},
+			},
+		})
+	})
+	rest, err := Parser().ParseArgs([]string{"skills", "--verbose"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	expectedStdout := "" +
+		"Skill                       Type        Attributes                                 Granted To\n" +
+		"canonical-pi2:debug-console serial-port -                                          \n" +
+		"canonical-pi2:pin-13        bool-file   mode=out,path=/sys/class/gpio/gpio13/value keyboard-lights:capslock-led(capslock,numlock)\n"
+	c.Assert(s.Stdout(), Equals, expectedStdout)
+	c.Assert(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) redirectClientToGraph(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		c.Check(r.URL.Path, Equals, "/2.0/skills")
+		c.Check(r.URL.Query().Get("view"), Equals, "graph")
+		EncodeResponseBody(c, w, map[string]interface{}{
+			"type": "sync",
+			"result": client.SkillGraph{
+				Skills: []client.Skill{
+					{
+						Snap:  "canonical-pi2",
+						Name:  "pin-13",
+						Type:  "bool-file",
+						Attrs: map[string]interface{}{"path": "/sys/class/gpio/gpio13/value"},
+					},
+				},
+				Slots: []client.Slot{
+					{
+						Snap: "keyboard-lights",
+						Name: "capslock-led",
+						Type: "bool-file",
+					},
+				},
+				Grants: []client.SkillGraphGrant{
+					{
+						Skill: client.SkillRef{Snap: "canonical-pi2", Name: "pin-13"},
+						Slot:  client.SkillRef{Snap: "keyboard-lights", Name: "capslock-led"},
+					},
+				},
+			},
+		})
+	})
+}
+
+func (s *SnapSuite) TestSkillsFormatDot(c *C) {
+	s.redirectClientToGraph(c)
+	rest, err := Parser().ParseArgs([]string{"skills", "--format=dot"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	expectedStdout := "" +
+		"digraph skills {\n" +
+		"\t\"skill:canonical-pi2:pin-13\" [shape=box, label=\"canonical-pi2:pin-13\\nbool-file\\npath=/sys/class/gpio/gpio13/value\"];\n" +
+		"\t\"slot:keyboard-lights:capslock-led\" [shape=ellipse, label=\"keyboard-lights:capslock-led\\nbool-file\"];\n" +
+		"\t\"skill:canonical-pi2:pin-13\" -> \"slot:keyboard-lights:capslock-led\";\n" +
+		"}\n"
+	c.Assert(s.Stdout(), Equals, expectedStdout)
+	c.Assert(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestSkillsFormatJSON(c *C) {
+	s.redirectClientToGraph(c)
+	rest, err := Parser().ParseArgs([]string{"skills", "--format=json"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	var graph client.SkillGraph
+	err = json.Unmarshal([]byte(s.Stdout()), &graph)
+	c.Assert(err, IsNil)
+	c.Check(graph.Skills, HasLen, 1)
+	c.Check(graph.Slots, HasLen, 1)
+	c.Check(graph.Grants, DeepEquals, []client.SkillGraphGrant{
+		{
+			Skill: client.SkillRef{Snap: "canonical-pi2", Name: "pin-13"},
+			Slot:  client.SkillRef{Snap: "keyboard-lights", Name: "capslock-led"},
+		},
+	})
+	c.Assert(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestSkillsFormatUnsupported(c *C) {
+	_, err := Parser().ParseArgs([]string{"skills", "--format=yaml"})
+	c.Assert(err, ErrorMatches, `unsupported output format "yaml", use dot or json`)
+}
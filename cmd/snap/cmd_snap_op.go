@@ -52,7 +52,10 @@ var (
 )
 
 var longAddHelp = i18n.G(`
-The add command installs and activates the named snap in the system.
+The add command installs and activates the named snaps in the system.
+
+Several snaps are downloaded in parallel and installed in a single
+operation, frameworks first.
 `)
 
 var longRemoveHelp = i18n.G(`
@@ -68,6 +71,9 @@ The purge command removes the data of the named snap from the system.
 
 var longRefreshHelp = i18n.G(`
 The refresh command refreshes (updates) the named snap.
+
+With --channel the snap is switched to the given channel and follows that
+channel from then on.
 `)
 
 var longRollbackHelp = i18n.G(`
@@ -100,10 +106,8 @@ func init() {
 		long  string
 		op    func(*client.Client, string) (string, error)
 	}{
-		{"add", shortAddHelp, longAddHelp, (*client.Client).AddSnap},
 		{"remove", shortRemoveHelp, longRemoveHelp, (*client.Client).RemoveSnap},
 		{"purge", shortPurgeHelp, longPurgeHelp, (*client.Client).PurgeSnap},
-		{"refresh", shortRefreshHelp, longRefreshHelp, (*client.Client).RefreshSnap},
 		{"rollback", shortRollbackHelp, longRollbackHelp, (*client.Client).RollbackSnap},
 		{"activate", shortActivateHelp, longActivateHelp, (*client.Client).ActivateSnap},
 		{"deactivate", shortDeactivateHelp, longDeactivateHelp, (*client.Client).DeactivateSnap},
@@ -111,6 +115,8 @@ func init() {
 		op := s.op
 		addCommand(s.name, s.short, s.long, func() interface{} { return &cmdOp{op: op} })
 	}
+	addCommand("add", shortAddHelp, longAddHelp, func() interface{} { return &cmdAdd{} })
+	addCommand("refresh", shortRefreshHelp, longRefreshHelp, func() interface{} { return &cmdRefresh{} })
 }
 
 func (x *cmdOp) Execute([]string) error {
@@ -122,3 +128,42 @@ func (x *cmdOp) Execute([]string) error {
 
 	return wait(cli, uuid)
 }
+
+type cmdAdd struct {
+	Positional struct {
+		Snaps []string `positional-arg-name:"<snap>" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *cmdAdd) Execute([]string) error {
+	cli := Client()
+	var uuid string
+	var err error
+	if len(x.Positional.Snaps) == 1 {
+		uuid, err = cli.AddSnap(x.Positional.Snaps[0])
+	} else {
+		uuid, err = cli.AddSnaps(x.Positional.Snaps, "")
+	}
+	if err != nil {
+		return err
+	}
+
+	return wait(cli, uuid)
+}
+
+type cmdRefresh struct {
+	Channel    string `long:"channel" description:"Switch to the given channel (stable, candidate, beta or edge)"`
+	Positional struct {
+		Snap string `positional-arg-name:"<snap>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *cmdRefresh) Execute([]string) error {
+	cli := Client()
+	uuid, err := cli.RefreshSnap(x.Positional.Snap, x.Channel)
+	if err != nil {
+		return err
+	}
+
+	return wait(cli, uuid)
+}
@@ -56,5 +56,12 @@ func (x *cmdActivate) Execute(args []string) error {
 }
 
 func (x *cmdActivate) doActivate() error {
-	return snappy.SetActive(x.Args.Snap, x.activate, progress.MakeProgressBar())
+	action := "deactivate"
+	if x.activate {
+		action = "activate"
+	}
+
+	return audited(snappy.AuditSourceCLI, action, x.Args.Snap, func() error {
+		return snappy.SetActive(x.Args.Snap, x.activate, progress.MakeProgressBar())
+	})
 }
@@ -89,7 +89,18 @@ func configurePackage(pkgName, configFile string) ([]byte, error) {
 	}
 
 	overlord := &snappy.Overlord{}
-	return overlord.Configure(snap.(*snappy.SnapPart), config)
+	if config == nil {
+		// only the current configuration is queried
+		return overlord.Configure(snap.(*snappy.SnapPart), config)
+	}
+
+	var newConfig []byte
+	err = audited(snappy.AuditSourceCLI, "config", snappy.QualifiedName(snap), func() (err error) {
+		newConfig, err = overlord.Configure(snap.(*snappy.SnapPart), config)
+		return err
+	})
+
+	return newConfig, err
 }
 
 func readConfiguration(configInput string) (config []byte, err error) {
@@ -0,0 +1,110 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ubuntu-core/snappy/i18n"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/progress"
+	"github.com/ubuntu-core/snappy/snappy"
+)
+
+type cmdGC struct {
+	DryRun bool `long:"dry-run"`
+}
+
+var shortGCHelp = i18n.G("Remove old versions of packages")
+
+var longGCHelp = i18n.G("Removes the old versions of the listed packages, or of all installed packages, that are not kept by the garbage collection policy in /etc/snappy/gc.yaml. By default the active version and the one before it are kept. The expired snapshots of the data of purged packages are removed as well.")
+
+func init() {
+	arg, err := parser.AddCommand("gc",
+		shortGCHelp,
+		longGCHelp,
+		&cmdGC{})
+	if err != nil {
+		logger.Panicf("Unable to gc: %v", err)
+	}
+	addOptionDescription(arg, "dry-run", i18n.G("Only list the versions that would be removed."))
+}
+
+func (x *cmdGC) Execute(args []string) error {
+	if x.DryRun {
+		return x.doGC(args)
+	}
+
+	return withMutexAndRetry(func() error {
+		return x.doGC(args)
+	})
+}
+
+func (x *cmdGC) doGC(args []string) error {
+	var flags snappy.GCFlags
+	if x.DryRun {
+		flags = snappy.DoGCDryRun
+	}
+
+	report, err := snappy.CollectGarbage(args, flags, progress.MakeProgressBar())
+	if err != nil {
+		return err
+	}
+
+	for _, removal := range report.Removed {
+		if report.DryRun {
+			// TRANSLATORS: the first %s is a pkgname, the second a version
+			fmt.Printf(i18n.G("Would remove %s (%s)\n"), removal.Name+"."+removal.Origin, removal.Version)
+		} else {
+			// TRANSLATORS: the first %s is a pkgname, the second a version
+			fmt.Printf(i18n.G("Removed %s (%s)\n"), removal.Name+"."+removal.Origin, removal.Version)
+		}
+	}
+
+	for _, id := range report.ExpiredSnapshots {
+		if report.DryRun {
+			// TRANSLATORS: the %d is the id of a snapshot
+			fmt.Printf(i18n.G("Would remove expired snapshot %d\n"), id)
+		} else {
+			// TRANSLATORS: the %d is the id of a snapshot
+			fmt.Printf(i18n.G("Removed expired snapshot %d\n"), id)
+		}
+	}
+
+	for _, name := range report.CachedDownloads {
+		if report.DryRun {
+			// TRANSLATORS: the %s is the file name of a downloaded snap
+			fmt.Printf(i18n.G("Would remove cached download %s\n"), name)
+		} else {
+			// TRANSLATORS: the %s is the file name of a downloaded snap
+			fmt.Printf(i18n.G("Removed cached download %s\n"), name)
+		}
+	}
+
+	if report.DryRun {
+		// TRANSLATORS: the %d is a number of bytes
+		fmt.Printf(i18n.G("%d bytes would be reclaimed\n"), report.Reclaimed)
+	} else {
+		// TRANSLATORS: the %d is a number of bytes
+		fmt.Printf(i18n.G("%d bytes reclaimed\n"), report.Reclaimed)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/ubuntu-core/snappy/i18n"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/snappy"
+)
+
+type cmdHistory struct {
+	Args struct {
+		Snaps []string `positional-arg-name:"snap"`
+	} `positional-args:"yes"`
+}
+
+var shortHistoryHelp = i18n.G("Show the history of changes to packages")
+
+var longHistoryHelp = i18n.G(`Shows the changes made to the installed packages, oldest first: when they were made, by whom and from where, the versions before and after, and whether they succeeded.
+
+Only the changes of the given packages are shown, if any are given.`)
+
+func init() {
+	_, err := parser.AddCommand("history",
+		shortHistoryHelp,
+		longHistoryHelp,
+		&cmdHistory{})
+	if err != nil {
+		logger.Panicf("Unable to history: %v", err)
+	}
+}
+
+func (x *cmdHistory) Execute([]string) error {
+	entries, err := snappy.AuditLog(x.Args.Snaps)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 3, 1, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Date\tAction\tName\tFrom\tTo\tUid\tSource\tResult"))
+	for _, entry := range entries {
+		uid := "-"
+		if entry.UID != snappy.AuditUnknownUID {
+			uid = strconv.Itoa(entry.UID)
+		}
+		result := entry.Result
+		if entry.Error != "" {
+			result = fmt.Sprintf("%s (%s)", result, entry.Error)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format("2006-01-02 15:04"), entry.Action, entry.Snap, dashIfEmpty(entry.From), dashIfEmpty(entry.To), uid, entry.Source, result)
+	}
+
+	return nil
+}
+
+// dashIfEmpty returns s, or "-" if it is empty
+func dashIfEmpty(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
@@ -0,0 +1,144 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ubuntu-core/snappy/i18n"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/snappy"
+)
+
+type cmdHold struct {
+	Set    holdSet    `command:"set"`
+	List   holdList   `command:"list"`
+	Remove holdRemove `command:"remove"`
+}
+
+type holdSet struct {
+	Until   string `long:"until" description:"Hold until the given date (YYYY-MM-DD) or time (RFC 3339) instead of indefinitely"`
+	Version string `long:"version" description:"Pin the package to the given version instead of holding all updates"`
+	Args    struct {
+		Snaps []string `positional-arg-name:"snap" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type holdList struct{}
+
+type holdRemove struct {
+	Args struct {
+		Snaps []string `positional-arg-name:"snap" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortHoldHelp = i18n.G("Keep packages from being updated")
+
+var longHoldHelp = i18n.G(`Holds packages so that "snappy update" and autopilot do not update them, indefinitely or until the given date.
+
+A package can also be pinned to a version, then only updates to that version are installed.`)
+
+func init() {
+	_, err := parser.AddCommand("hold",
+		shortHoldHelp,
+		longHoldHelp,
+		&cmdHold{})
+	if err != nil {
+		logger.Panicf("Unable to hold: %v", err)
+	}
+}
+
+// parseUntil parses the given date or time, a date is taken as midnight
+// local time
+func parseUntil(until string) (*time.Time, error) {
+	if until == "" {
+		return nil, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", until, time.Local)
+	if err != nil {
+		t, err = time.Parse(time.RFC3339, until)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot parse %q as a date or time"), until)
+	}
+
+	return &t, nil
+}
+
+func (x *holdSet) Execute([]string) error {
+	until, err := parseUntil(x.Until)
+	if err != nil {
+		return err
+	}
+
+	return withMutexAndRetry(func() error {
+		for _, name := range x.Args.Snaps {
+			hold, err := snappy.SetHold(name, until, x.Version)
+			if err != nil {
+				return err
+			}
+			// TRANSLATORS: the first %s is a pkgname, the second the hold
+			fmt.Printf(i18n.G("%s is %s\n"), hold.Snap, hold)
+		}
+
+		return nil
+	})
+}
+
+func (x *holdList) Execute([]string) error {
+	holds, err := snappy.Holds()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 3, 1, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Name\tVersion\tUntil"))
+	for _, hold := range holds {
+		version := "-"
+		if hold.Version != "" {
+			version = hold.Version
+		}
+		until := "-"
+		if hold.Until != nil {
+			until = hold.Until.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", hold.Snap, version, until)
+	}
+
+	return nil
+}
+
+func (x *holdRemove) Execute([]string) error {
+	return withMutexAndRetry(func() error {
+		for _, name := range x.Args.Snaps {
+			if err := snappy.RemoveHold(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -74,9 +74,9 @@ func (x *cmdInfo) Execute(args []string) (err error) {
 func snapInfo(pkgname string, includeStore, verbose bool) error {
 	snap := snappy.ActiveSnapByName(pkgname)
 	if snap == nil && includeStore {
-		m := snappy.NewUbuntuStoreSnapRepository()
+		m := snappy.NewStoreRepository()
 		var err error
-		snap, err = m.Snap(pkgname)
+		snap, err = m.Snap(pkgname, "")
 		if err != nil {
 			return fmt.Errorf("cannot get details for snap %q: %s", pkgname, err)
 		}
@@ -31,8 +31,9 @@ import (
 )
 
 type cmdInstall struct {
-	AllowUnauthenticated bool `long:"allow-unauthenticated"`
-	DisableGC            bool `long:"no-gc"`
+	AllowUnauthenticated bool   `long:"allow-unauthenticated"`
+	DisableGC            bool   `long:"no-gc"`
+	Sha512               string `long:"sha512"`
 	Positional           struct {
 		PackageName string `positional-arg-name:"package name"`
 		ConfigFile  string `positional-arg-name:"config file"`
@@ -49,7 +50,8 @@ func init() {
 	}
 	addOptionDescription(arg, "allow-unauthenticated", i18n.G("Install snaps even if the signature can not be verified."))
 	addOptionDescription(arg, "no-gc", i18n.G("Do not clean up old versions of the package."))
-	addOptionDescription(arg, "package name", i18n.G("The Package to install (name or path)"))
+	addOptionDescription(arg, "sha512", i18n.G("The sha512 the snap downloaded from a URL must have."))
+	addOptionDescription(arg, "package name", i18n.G("The Package to install (name, path or http(s) URL)"))
 	addOptionDescription(arg, "config file", i18n.G("The configuration for the given install"))
 }
 
@@ -76,7 +78,19 @@ func (x *cmdInstall) doInstall() error {
 	// TRANSLATORS: the %s is a pkgname
 	fmt.Printf(i18n.G("Installing %s\n"), pkgName)
 
-	realPkgName, err := snappy.Install(pkgName, flags, progress.MakeProgressBar())
+	if x.Sha512 != "" && !snappy.IsSnapURL(pkgName) {
+		return errors.New(i18n.G("--sha512 can only be used with a URL"))
+	}
+
+	var realPkgName string
+	err := audited(snappy.AuditSourceCLI, "install", pkgName, func() (err error) {
+		if snappy.IsSnapURL(pkgName) {
+			realPkgName, err = snappy.InstallURL(pkgName, x.Sha512, flags, progress.MakeProgressBar())
+		} else {
+			realPkgName, err = snappy.Install(pkgName, "", flags, progress.MakeProgressBar())
+		}
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -40,7 +40,7 @@ var shortListHelp = i18n.G("List active components installed on a snappy system"
 
 var longListHelp = i18n.G(`Provides a list of all active components installed on a snappy system.
 
-If requested, the command will find out if there are updates for any of the components and indicate that by appending a * to the date. This will be slower as it requires a round trip to the app store on the network.
+If requested, the command will find out if there are updates for any of the components and indicate that by appending a * to the date. This will be slower as it requires a round trip to the app store on the network. Components that are held or pinned (see hold) show the hold, and updates they hold back are not indicated. Components whose data is close to, or over, its quota (see quota) are warned about.
 
 The developer information refers to non-mainline versions of a package (much like PPAs in deb-based Ubuntu). If the package is the primary version of that package in Ubuntu then the developer info is not shown. This allows one to identify packages which have custom, non-standard versions installed. As a special case, the “sideload” developer refers to packages installed manually on the system.
 
@@ -101,6 +101,7 @@ func showInstalledList(installed []snappy.Part, o io.Writer) {
 	w.Flush()
 
 	showRebootMessage(installed, o)
+	showQuotaWarnings(installed, o)
 }
 
 func showVerboseList(installed []snappy.Part, o io.Writer) {
@@ -123,6 +124,7 @@ func showVerboseList(installed []snappy.Part, o io.Writer) {
 	w.Flush()
 
 	showRebootMessage(installed, o)
+	showQuotaWarnings(installed, o)
 }
 
 func showRebootMessage(installed []snappy.Part, o io.Writer) {
@@ -137,12 +139,28 @@ func showRebootMessage(installed []snappy.Part, o io.Writer) {
 	}
 }
 
+func showQuotaWarnings(installed []snappy.Part, o io.Writer) {
+	// display all parts whose data is close to their quota
+	for _, part := range installed {
+		if !part.IsActive() {
+			continue
+		}
+		usage, err := snappy.SnapQuotaUsage(part)
+		if err != nil || usage == nil || !usage.Warn() {
+			continue
+		}
+
+		// TRANSLATORS: the first %s is a pkgname, the numbers are the percentage of the quota used, the usage and the quota in bytes
+		fmt.Fprintln(o, fmt.Sprintf(i18n.G("Warning: the data of %s uses %d%% of its quota (%d of %d bytes)."), part.Name(), usage.Percent(), usage.Usage, usage.Limit))
+	}
+}
+
 func showUpdatesList(installed []snappy.Part, updates []snappy.Part, o io.Writer) {
 	// TODO tabwriter and output in general to adapt to the spec
 	w := tabwriter.NewWriter(o, 5, 3, 1, ' ', 0)
 	defer w.Flush()
 
-	fmt.Fprintln(w, i18n.G("Name\tDate\tVersion\t"))
+	fmt.Fprintln(w, i18n.G("Name\tDate\tVersion\tHold\t"))
 	for _, part := range installed {
 		if !part.IsActive() {
 			continue
@@ -150,12 +168,17 @@ func showUpdatesList(installed []snappy.Part, updates []snappy.Part, o io.Writer
 		hasUpdate := ""
 		ver := part.Version()
 		date := part.Date()
+		held := ""
+		hold := snappy.SnapHold(snappy.QualifiedName(part))
+		if hold != nil {
+			held = hold.String()
+		}
 		update := snappy.FindSnapsByName(part.Name(), updates)
-		if len(update) == 1 {
+		if len(update) == 1 && (hold == nil || !hold.Blocks(update[0].Version())) {
 			hasUpdate = "*"
 			ver = update[0].Version()
 			date = update[0].Date()
 		}
-		fmt.Fprintln(w, fmt.Sprintf("%s%s\t%v\t%s\t", part.Name(), hasUpdate, formatDate(date), ver))
+		fmt.Fprintln(w, fmt.Sprintf("%s%s\t%v\t%s\t%s\t", part.Name(), hasUpdate, formatDate(date), ver, held))
 	}
 }
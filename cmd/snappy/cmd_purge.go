@@ -29,12 +29,15 @@ import (
 )
 
 type cmdPurge struct {
-	Installed bool `long:"installed"`
+	Installed  bool `long:"installed"`
+	NoSnapshot bool `long:"no-snapshot"`
 }
 
 var (
 	shortPurgeHelp = i18n.G("Remove all the data from the listed packages")
-	longPurgeHelp  = i18n.G(`Remove all the data from the listed packages. Normally this is used for packages that have been removed and attempting to purge data for an installed package will result in an error. The --installed option  overrides that and enables the administrator to purge all data for an installed package (effectively resetting the package completely).`)
+	longPurgeHelp  = i18n.G(`Remove all the data from the listed packages. Normally this is used for packages that have been removed and attempting to purge data for an installed package will result in an error. The --installed option  overrides that and enables the administrator to purge all data for an installed package (effectively resetting the package completely).
+
+Unless --no-snapshot is given the data is kept in a snapshot, that can be restored with "snappy snapshot restore", until it expires after the snapshot-expiry of the garbage collection policy in /etc/snappy/gc.yaml, 30 days by default.`)
 )
 
 func init() {
@@ -46,6 +49,7 @@ func init() {
 		logger.Panicf("Unable to purge: %v", err)
 	}
 	addOptionDescription(arg, "installed", i18n.G("Purge an installed package."))
+	addOptionDescription(arg, "no-snapshot", i18n.G("Do not keep the data in a snapshot."))
 }
 
 func (x *cmdPurge) Execute(args []string) error {
@@ -57,14 +61,20 @@ func (x *cmdPurge) Execute(args []string) error {
 func (x *cmdPurge) doPurge(args []string) error {
 	var flags snappy.PurgeFlags
 	if x.Installed {
-		flags = snappy.DoPurgeActive
+		flags |= snappy.DoPurgeActive
+	}
+	if x.NoSnapshot {
+		flags |= snappy.DoPurgeNoSnapshot
 	}
 
 	for _, part := range args {
 		// TRANSLATORS: the %s is a pkgname
 		fmt.Printf(i18n.G("Purging %s\n"), part)
 
-		if err := snappy.Purge(part, flags, progress.MakeProgressBar()); err != nil {
+		err := audited(snappy.AuditSourceCLI, "purge", part, func() error {
+			return snappy.Purge(part, flags, progress.MakeProgressBar())
+		})
+		if err != nil {
 			return err
 		}
 	}
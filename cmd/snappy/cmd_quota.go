@@ -0,0 +1,142 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ubuntu-core/snappy/i18n"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/snappy"
+)
+
+type cmdQuota struct {
+	Set    quotaSet    `command:"set"`
+	List   quotaList   `command:"list"`
+	Remove quotaRemove `command:"remove"`
+}
+
+type quotaSet struct {
+	Args struct {
+		Snap  string `positional-arg-name:"snap"`
+		Limit string `positional-arg-name:"size"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type quotaList struct{}
+
+type quotaRemove struct {
+	Args struct {
+		Snaps []string `positional-arg-name:"snap" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortQuotaHelp = i18n.G("Limit the size of the data of packages")
+
+var longQuotaHelp = i18n.G(`Limits the size of the data directory of the active version of a package, given in bytes or with a K, M or G suffix.
+
+A filesystem project quota is used where it is supported. Otherwise the quota is enforced softly: the package can use more, and updating it warns while its data does not fit in the quota. "snappy list" warns about packages close to their quota.`)
+
+func init() {
+	_, err := parser.AddCommand("quota",
+		shortQuotaHelp,
+		longQuotaHelp,
+		&cmdQuota{})
+	if err != nil {
+		logger.Panicf("Unable to quota: %v", err)
+	}
+}
+
+// parseSize parses a size in bytes, with an optional K, M or G suffix
+func parseSize(size string) (uint64, error) {
+	multiplier := uint64(1)
+	number := size
+	if n := len(size); n > 0 {
+		switch strings.ToUpper(size[n-1:]) {
+		case "K":
+			multiplier = 1 << 10
+		case "M":
+			multiplier = 1 << 20
+		case "G":
+			multiplier = 1 << 30
+		}
+		if multiplier != 1 {
+			number = size[:n-1]
+		}
+	}
+
+	value, err := strconv.ParseUint(number, 10, 64)
+	if err != nil || value == 0 {
+		return 0, fmt.Errorf(i18n.G("cannot parse %q as a size"), size)
+	}
+
+	return value * multiplier, nil
+}
+
+func (x *quotaSet) Execute([]string) error {
+	limit, err := parseSize(x.Args.Limit)
+	if err != nil {
+		return err
+	}
+
+	return withMutexAndRetry(func() error {
+		quota, err := snappy.SetQuota(x.Args.Snap, limit)
+		if err != nil {
+			return err
+		}
+		// TRANSLATORS: the first %s is a pkgname, the second how the quota is enforced
+		fmt.Printf(i18n.G("%s is limited to %d bytes (%s)\n"), quota.Snap, quota.Limit, quota.Enforcement())
+
+		return nil
+	})
+}
+
+func (x *quotaList) Execute([]string) error {
+	usages, err := snappy.QuotaUsages()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 3, 1, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Name\tVersion\tLimit\tUsage\tUsed\tEnforcement"))
+	for _, usage := range usages {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d%%\t%s\n", usage.Snap, usage.Version, usage.Limit, usage.Usage, usage.Percent(), usage.Enforcement)
+	}
+
+	return nil
+}
+
+func (x *quotaRemove) Execute([]string) error {
+	return withMutexAndRetry(func() error {
+		for _, name := range x.Args.Snaps {
+			if err := snappy.RemoveQuota(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -59,7 +59,10 @@ func (x *cmdRemove) doRemove(args []string) error {
 		// TRANSLATORS: the %s is a pkgname
 		fmt.Printf(i18n.G("Removing %s\n"), part)
 
-		if err := snappy.Remove(part, flags, progress.MakeProgressBar()); err != nil {
+		err := audited(snappy.AuditSourceCLI, "remove", part, func() error {
+			return snappy.Remove(part, flags, progress.MakeProgressBar())
+		})
+		if err != nil {
 			return err
 		}
 	}
@@ -63,7 +63,11 @@ func (x *cmdRollback) doRollback() error {
 		return errNeedPackageName
 	}
 
-	nowVersion, err := snappy.Rollback(pkg, version, progress.MakeProgressBar())
+	var nowVersion string
+	err := audited(snappy.AuditSourceCLI, "rollback", pkg, func() (err error) {
+		nowVersion, err = snappy.Rollback(pkg, version, progress.MakeProgressBar())
+		return err
+	})
 	if err != nil {
 		return err
 	}
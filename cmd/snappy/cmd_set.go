@@ -63,7 +63,9 @@ func (x *cmdSet) doSet() (err error) {
 		return err
 	}
 
-	return snappy.SetProperty(pkgname, progress.MakeProgressBar(), args...)
+	return audited(snappy.AuditSourceCLI, "set", pkgname, func() error {
+		return snappy.SetProperty(pkgname, progress.MakeProgressBar(), args...)
+	})
 }
 
 func parseSetPropertyCmdline(args ...string) (pkgname string, out []string, err error) {
@@ -0,0 +1,160 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/i18n"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/snappy"
+)
+
+type cmdSnapshot struct {
+	Save    snapshotSave    `command:"save"`
+	List    snapshotList    `command:"list"`
+	Restore snapshotRestore `command:"restore"`
+	Export  snapshotExport  `command:"export"`
+	Import  snapshotImport  `command:"import"`
+}
+
+type snapshotSave struct {
+	Args struct {
+		Snaps []string `positional-arg-name:"snap" required:"1"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type snapshotList struct {
+	Args struct {
+		Snaps []string `positional-arg-name:"snap"`
+	} `positional-args:"yes"`
+}
+
+type snapshotRestore struct {
+	Version string `long:"version" description:"Restore into this version of the package instead of the one the snapshot was saved from"`
+	Args    struct {
+		ID int `positional-arg-name:"id"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type snapshotExport struct {
+	Args struct {
+		ID   int    `positional-arg-name:"id"`
+		File string `positional-arg-name:"file"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+type snapshotImport struct {
+	Args struct {
+		File string `positional-arg-name:"file"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortSnapshotHelp = i18n.G("Save and restore the data of packages")
+
+var longSnapshotHelp = i18n.G(`Saves the data of a version of a package, that of the system and of every user, in a snapshot, and restores it later on.
+
+A package is given as name[.origin][=version], without a version the data of the active version is saved. Snapshots can be exported to a file and imported on another system.`)
+
+func init() {
+	_, err := parser.AddCommand("snapshot",
+		shortSnapshotHelp,
+		longSnapshotHelp,
+		&cmdSnapshot{})
+	if err != nil {
+		logger.Panicf("Unable to snapshot: %v", err)
+	}
+}
+
+func (x *snapshotSave) Execute([]string) error {
+	return withMutexAndRetry(func() error {
+		for _, part := range x.Args.Snaps {
+			snapshot, err := snappy.SaveSnapshot(part)
+			if err != nil {
+				return err
+			}
+			// TRANSLATORS: the first %s is a pkgname, the second a version
+			fmt.Printf(i18n.G("Saved snapshot %d of %s (%s)\n"), snapshot.ID, snapshotName(snapshot), snapshot.Version)
+		}
+
+		return nil
+	})
+}
+
+func (x *snapshotList) Execute([]string) error {
+	snapshots, err := snappy.Snapshots(x.Args.Snaps)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 5, 3, 1, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintln(w, i18n.G("Id\tName\tVersion\tDate\tSize\tUsers\tExpires"))
+	for _, snapshot := range snapshots {
+		expires := "-"
+		if snapshot.Expires != nil {
+			expires = snapshot.Expires.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\t%s\n", snapshot.ID, snapshotName(snapshot), snapshot.Version, snapshot.Time.Local().Format("2006-01-02 15:04"), snapshot.Size, strings.Join(snapshot.Users, ","), expires)
+	}
+
+	return nil
+}
+
+func (x *snapshotRestore) Execute([]string) error {
+	return withMutexAndRetry(func() error {
+		return snappy.RestoreSnapshot(x.Args.ID, x.Version)
+	})
+}
+
+func (x *snapshotExport) Execute([]string) error {
+	path, err := snappy.SnapshotFile(x.Args.ID)
+	if err != nil {
+		return err
+	}
+
+	return helpers.CopyFile(path, x.Args.File, helpers.CopyFlagSync)
+}
+
+func (x *snapshotImport) Execute([]string) error {
+	return withMutexAndRetry(func() error {
+		snapshot, err := snappy.ImportSnapshot(x.Args.File)
+		if err != nil {
+			return err
+		}
+		// TRANSLATORS: the first %s is a pkgname, the second a version
+		fmt.Printf(i18n.G("Imported snapshot %d of %s (%s)\n"), snapshot.ID, snapshotName(snapshot), snapshot.Version)
+
+		return nil
+	})
+}
+
+func snapshotName(snapshot *snappy.Snapshot) string {
+	if snapshot.Origin == "" {
+		return snapshot.Name
+	}
+
+	return snapshot.Name + "." + snapshot.Origin
+}
@@ -32,9 +32,10 @@ import (
 )
 
 type cmdUpdate struct {
-	DisableGC  bool `long:"no-gc"`
-	AutoReboot bool `long:"automatic-reboot"`
-	Positional struct {
+	DisableGC    bool `long:"no-gc"`
+	AutoReboot   bool `long:"automatic-reboot"`
+	AllOrNothing bool `long:"all-or-nothing"`
+	Positional   struct {
 		PackageName string `positional-arg-name:"package name"`
 	} `positional-args:"yes"`
 }
@@ -49,6 +50,7 @@ func init() {
 	}
 	addOptionDescription(arg, "no-gc", i18n.G("Do not clean up old versions of the package."))
 	addOptionDescription(arg, "automatic-reboot", i18n.G("Reboot if necessary to be on the latest running system."))
+	addOptionDescription(arg, "all-or-nothing", i18n.G("Roll back all updated parts if any of the updates fails."))
 	addOptionDescription(arg, "package name", i18n.G("The Package to update"))
 }
 
@@ -70,15 +72,26 @@ func (x *cmdUpdate) doUpdate() error {
 	if x.DisableGC {
 		flags = 0
 	}
+	if x.AllOrNothing {
+		flags |= snappy.TransactionalUpdate
+	}
 
-	var err error
 	var updates []snappy.Part
-	if x.Positional.PackageName != "" {
-		updates, err = snappy.Update(x.Positional.PackageName, flags, progress.MakeProgressBar())
-	} else {
-		updates, err = snappy.UpdateAll(flags, progress.MakeProgressBar())
+	update := func() (err error) {
+		if x.Positional.PackageName != "" {
+			updates, err = snappy.Update(x.Positional.PackageName, "", flags, progress.MakeProgressBar())
+		} else {
+			updates, err = snappy.UpdateAll(flags, progress.MakeProgressBar())
+		}
+		return err
 	}
-	if err != nil {
+
+	source := snappy.AuditSourceCLI
+	if x.AutoReboot {
+		// this is how the autopilot service updates
+		source = snappy.AuditSourceAutopilot
+	}
+	if err := audited(source, "update", x.Positional.PackageName, update); err != nil {
 		return err
 	}
 
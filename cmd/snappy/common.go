@@ -35,6 +35,13 @@ import (
 	"github.com/jessevdk/go-flags"
 )
 
+// audited makes the given change of the given snap, recording it in the
+// audit log as requested by the user running the command, through the
+// given source
+func audited(source, action, snap string, change func() error) error {
+	return snappy.Audited(source, sys.Getuid(), action, snap, change)
+}
+
 func isAutoUpdateRunning() bool {
 	unitName := "snappy-autopilot"
 	bs, err := exec.Command("systemctl", "show", "--property=SubState", unitName).CombinedOutput()
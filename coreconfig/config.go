@@ -81,6 +81,7 @@ type systemConfig struct {
 	Modules    []string        `yaml:"load-kernel-modules,omitempty"`
 	Network    *networkConfig  `yaml:"network,omitempty"`
 	Watchdog   *watchdogConfig `yaml:"watchdog,omitempty"`
+	Refresh    *RefreshConfig  `yaml:"refresh,omitempty"`
 }
 
 type networkConfig struct {
@@ -141,6 +142,10 @@ func newSystemConfig() (*systemConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	refresh, err := getRefresh()
+	if err != nil {
+		return nil, err
+	}
 
 	var network *networkConfig
 	if len(interfaces) > 0 || len(ppp) > 0 {
@@ -158,6 +163,7 @@ func newSystemConfig() (*systemConfig, error) {
 		Modules:    modules,
 		Network:    network,
 		Watchdog:   watchdog,
+		Refresh:    refresh,
 	}
 
 	return config, nil
@@ -282,6 +288,14 @@ func Set(rawConfig []byte) (newRawConfig []byte, err error) {
 			if err := setWatchdog(newConfig.Watchdog); err != nil {
 				return nil, err
 			}
+		case "Refresh":
+			if oldConfig.Refresh != nil && reflect.DeepEqual(*oldConfig.Refresh, *newConfig.Refresh) {
+				continue
+			}
+
+			if err := setRefresh(newConfig.Refresh); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -513,8 +527,24 @@ var (
 	cmdSystemctl         = "systemctl"
 )
 
-// getAutoUpdate returns the autoupdate state
+// AutoUpdate returns whether the snaps are updated automatically
+func AutoUpdate() (bool, error) {
+	return getAutoUpdate()
+}
+
+// getAutoUpdate returns the autoupdate state. Once snapd schedules the
+// refreshes the timer is off and the state is kept next to the refresh
+// config instead.
 var getAutoUpdate = func() (state bool, err error) {
+	if refreshScheduled() {
+		return !helpers.FileExists(autoUpdateDisabledPath), nil
+	}
+
+	return getAutoUpdateTimer()
+}
+
+// getAutoUpdateTimer returns whether the autoupdate timer is enabled
+func getAutoUpdateTimer() (state bool, err error) {
 	out, err := exec.Command(cmdSystemctl, cmdAutoUpdateEnabled...).Output()
 	if exitErr, ok := err.(*exec.ExitError); ok {
 		waitStatus := exitErr.Sys().(syscall.WaitStatus)
@@ -544,8 +574,18 @@ var (
 	cmdStopAutoUpdate    = []string{"stop", autoUpdateTimer}
 )
 
-// setAutoUpdate enables and starts, or stops and disables autoupdate
+// setAutoUpdate enables or disables autoupdate
 var setAutoUpdate = func(stateEnabled bool) error {
+	if refreshScheduled() {
+		return writeAutoUpdateState(stateEnabled)
+	}
+
+	return setAutoUpdateTimer(stateEnabled)
+}
+
+// setAutoUpdateTimer enables and starts, or stops and disables the
+// autoupdate timer
+func setAutoUpdateTimer(stateEnabled bool) error {
 	if stateEnabled {
 		if err := exec.Command(cmdSystemctl, cmdEnableAutoUpdate...).Run(); err != nil {
 			return err
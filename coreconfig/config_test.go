@@ -57,6 +57,8 @@ var (
 	originalPppRoot              = pppRoot
 	originalWatchdogStartupPath  = watchdogStartupPath
 	originalWatchdogConfigPath   = watchdogConfigPath
+	originalRefreshConfigPath    = refreshConfigPath
+	originalAutoUpdateDisabled   = autoUpdateDisabledPath
 	originalTzZoneInfoTarget     = tzZoneInfoTarget
 )
 
@@ -81,6 +83,8 @@ func (cts *ConfigTestSuite) SetUpTest(c *C) {
 	cmdAutoUpdateEnabled = []string{"-c", "echo disabled"}
 	cmdEnableAutoUpdate = []string{"-c", "/bin/true"}
 	cmdStartAutoUpdate = []string{"-c", "/bin/true"}
+	cmdStopAutoUpdate = []string{"-c", "/bin/true"}
+	cmdDisableAutoUpdate = []string{"-c", "/bin/true"}
 
 	hostname := "testhost"
 	getHostname = func() (string, error) { return hostname, nil }
@@ -94,6 +98,8 @@ func (cts *ConfigTestSuite) SetUpTest(c *C) {
 	pppRoot = c.MkDir() + "/"
 	watchdogConfigPath = filepath.Join(c.MkDir(), "watchdog-config")
 	watchdogStartupPath = filepath.Join(c.MkDir(), "watchdog-startup")
+	refreshConfigPath = filepath.Join(c.MkDir(), "snappy", "refresh.yaml")
+	autoUpdateDisabledPath = filepath.Join(filepath.Dir(refreshConfigPath), "autoupdate-disabled")
 
 	cts.sysctlerr = nil
 	cts.sysctlargses = nil
@@ -129,6 +135,8 @@ func (cts *ConfigTestSuite) TearDownTest(c *C) {
 	pppRoot = originalPppRoot
 	watchdogStartupPath = originalWatchdogStartupPath
 	watchdogConfigPath = originalWatchdogConfigPath
+	refreshConfigPath = originalRefreshConfigPath
+	autoUpdateDisabledPath = originalAutoUpdateDisabled
 	tzZoneInfoTarget = originalTzZoneInfoTarget
 
 	systemd.SystemctlCmd = cts.sysctlcmd
@@ -945,3 +953,103 @@ config:
 	// systemctl hasn't been called
 	c.Check(cts.sysctlargses, HasLen, 0)
 }
+
+func (cts *ConfigTestSuite) TestRefreshGetNone(c *C) {
+	rc, err := getRefresh()
+	c.Assert(err, IsNil)
+	c.Check(rc, IsNil)
+
+	schedule, err := GetRefreshSchedule()
+	c.Assert(err, IsNil)
+	c.Check(schedule, IsNil)
+}
+
+func (cts *ConfigTestSuite) TestRefreshSetViaYaml(c *C) {
+	input := `
+config:
+  ubuntu-core:
+    refresh:
+      days: [sat, sun]
+      hours: 02:00-04:00
+      jitter: 30m
+      metered: defer
+`
+	rawConfig, err := Set([]byte(input))
+	c.Assert(err, IsNil)
+	c.Check(string(rawConfig), Matches, `(?s).*refresh:\n      days:\n      - sat\n      - sun\n      hours: 02:00-04:00\n.*`)
+
+	rc, err := getRefresh()
+	c.Assert(err, IsNil)
+	c.Check(rc, DeepEquals, &RefreshConfig{
+		Days:    []string{"sat", "sun"},
+		Hours:   "02:00-04:00",
+		Jitter:  "30m",
+		Metered: "defer",
+	})
+
+	schedule, err := GetRefreshSchedule()
+	c.Assert(err, IsNil)
+	c.Check(schedule.DeferOnMetered, Equals, true)
+}
+
+func (cts *ConfigTestSuite) TestRefreshSetInvalid(c *C) {
+	input := `
+config:
+  ubuntu-core:
+    refresh:
+      hours: sometime
+`
+	_, err := Set([]byte(input))
+	c.Assert(err, ErrorMatches, `cannot use "sometime" as refresh hours, expected HH:MM-HH:MM`)
+
+	rc, err := getRefresh()
+	c.Assert(err, IsNil)
+	c.Check(rc, IsNil)
+}
+
+func (cts *ConfigTestSuite) TestRefreshSetTakesOverFromTimer(c *C) {
+	timerCalls := filepath.Join(c.MkDir(), "timer")
+	cmdAutoUpdateEnabled = []string{"-c", "echo enabled"}
+	cmdEnableAutoUpdate = []string{"-c", "echo enable >> " + timerCalls}
+	cmdStopAutoUpdate = []string{"-c", "echo stop >> " + timerCalls}
+	cmdDisableAutoUpdate = []string{"-c", "echo disable >> " + timerCalls}
+
+	_, err := Set([]byte("config:\n  ubuntu-core:\n    refresh:\n      hours: 02:00-04:00\n"))
+	c.Assert(err, IsNil)
+
+	// the timer is turned off, autoupdate stays on
+	content, err := ioutil.ReadFile(timerCalls)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "stop\ndisable\n")
+	enabled, err := AutoUpdate()
+	c.Assert(err, IsNil)
+	c.Check(enabled, Equals, true)
+
+	// from now on autoupdate no longer touches the timer
+	_, err = Set([]byte("config:\n  ubuntu-core:\n    autoupdate: false\n"))
+	c.Assert(err, IsNil)
+	enabled, err = AutoUpdate()
+	c.Assert(err, IsNil)
+	c.Check(enabled, Equals, false)
+
+	_, err = Set([]byte("config:\n  ubuntu-core:\n    autoupdate: true\n"))
+	c.Assert(err, IsNil)
+	enabled, err = AutoUpdate()
+	c.Assert(err, IsNil)
+	c.Check(enabled, Equals, true)
+
+	content, err = ioutil.ReadFile(timerCalls)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "stop\ndisable\n")
+}
+
+func (cts *ConfigTestSuite) TestRefreshSetKeepsAutoUpdateDisabled(c *C) {
+	cmdAutoUpdateEnabled = []string{"-c", "echo disabled; exit 1"}
+
+	_, err := Set([]byte("config:\n  ubuntu-core:\n    refresh:\n      hours: 02:00-04:00\n"))
+	c.Assert(err, IsNil)
+
+	enabled, err := AutoUpdate()
+	c.Assert(err, IsNil)
+	c.Check(enabled, Equals, false)
+}
@@ -0,0 +1,265 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package coreconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/ubuntu-core/snappy/helpers"
+)
+
+var (
+	refreshConfigPath      = "/etc/snappy/refresh.yaml"
+	autoUpdateDisabledPath = "/etc/snappy/autoupdate-disabled"
+)
+
+// RefreshConfig controls when snapd refreshes the installed snaps, it is
+// the "refresh" key of the ubuntu-core configuration.
+type RefreshConfig struct {
+	// Days the refresh can happen on ("mon", "tue", ...), every day if empty
+	Days []string `yaml:"days,omitempty"`
+	// Hours is the time of the day the refresh can happen at, as
+	// "HH:MM-HH:MM", the whole day if empty
+	Hours string `yaml:"hours,omitempty"`
+	// Jitter is the maximum random delay of the refresh into the window,
+	// for example "30m"
+	Jitter string `yaml:"jitter,omitempty"`
+	// Hold is a date, as "YYYY-MM-DD", until which no refresh happens
+	Hold string `yaml:"hold,omitempty"`
+	// Metered is "defer" to not refresh on a metered network, or
+	// "allow" (the default)
+	Metered string `yaml:"metered,omitempty"`
+}
+
+// RefreshSchedule is the parsed form of a RefreshConfig
+type RefreshSchedule struct {
+	// Days the refresh can happen on, every day if empty
+	Days map[time.Weekday]bool
+	// Start and End of the window as offsets from midnight, if End is
+	// not after Start the window ends on the next day
+	Start time.Duration
+	End   time.Duration
+	// Jitter is the maximum random delay into the window
+	Jitter time.Duration
+	// Hold is the time until which no refresh happens
+	Hold time.Time
+	// DeferOnMetered is set if refreshes are deferred on metered networks
+	DeferOnMetered bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseClock(clock string) (time.Duration, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %q as a time of the day", clock)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Schedule checks the refresh configuration and returns its parsed form
+func (rc *RefreshConfig) Schedule() (*RefreshSchedule, error) {
+	schedule := &RefreshSchedule{
+		Days: make(map[time.Weekday]bool),
+		End:  24 * time.Hour,
+	}
+
+	for _, day := range rc.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("cannot use %q as a refresh day", day)
+		}
+		schedule.Days[weekday] = true
+	}
+
+	if rc.Hours != "" {
+		l := strings.Split(rc.Hours, "-")
+		if len(l) != 2 {
+			return nil, fmt.Errorf("cannot use %q as refresh hours, expected HH:MM-HH:MM", rc.Hours)
+		}
+		var err error
+		if schedule.Start, err = parseClock(l[0]); err != nil {
+			return nil, err
+		}
+		if schedule.End, err = parseClock(l[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if rc.Jitter != "" {
+		jitter, err := time.ParseDuration(rc.Jitter)
+		if err != nil || jitter < 0 {
+			return nil, fmt.Errorf("cannot use %q as refresh jitter", rc.Jitter)
+		}
+		schedule.Jitter = jitter
+	}
+
+	if rc.Hold != "" {
+		hold, err := time.ParseInLocation("2006-01-02", rc.Hold, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("cannot use %q as refresh hold date, expected YYYY-MM-DD", rc.Hold)
+		}
+		schedule.Hold = hold
+	}
+
+	switch rc.Metered {
+	case "", "allow":
+	case "defer":
+		schedule.DeferOnMetered = true
+	default:
+		return nil, fmt.Errorf("cannot use %q for metered networks, expected allow or defer", rc.Metered)
+	}
+
+	return schedule, nil
+}
+
+// Next returns the start and the end of the first refresh window that is
+// not over at the given time and in which no refresh happened since the
+// last one. The start is never before the given time or the hold date.
+func (s *RefreshSchedule) Next(last, now time.Time) (start, end time.Time) {
+	from := now
+	if s.Hold.After(from) {
+		from = s.Hold
+	}
+
+	length := s.End - s.Start
+	if length <= 0 {
+		length += 24 * time.Hour
+	}
+
+	// start the day before, its window might go past midnight
+	for day := -1; day < 8; day++ {
+		midnight := time.Date(from.Year(), from.Month(), from.Day()+day, 0, 0, 0, 0, from.Location())
+		if len(s.Days) > 0 && !s.Days[midnight.Weekday()] {
+			continue
+		}
+
+		start = midnight.Add(s.Start)
+		end = start.Add(length)
+		if !end.After(from) || !last.Before(start) {
+			continue
+		}
+		if start.Before(from) {
+			start = from
+		}
+
+		return start, end
+	}
+
+	return time.Time{}, time.Time{}
+}
+
+// getRefresh returns the current refresh config, nil if there is none
+var getRefresh = func() (*RefreshConfig, error) {
+	content, err := ioutil.ReadFile(refreshConfigPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rc RefreshConfig
+	if err := yaml.Unmarshal(content, &rc); err != nil {
+		return nil, err
+	}
+
+	return &rc, nil
+}
+
+// refreshScheduled returns true if snapd schedules the refreshes
+func refreshScheduled() bool {
+	return helpers.FileExists(refreshConfigPath)
+}
+
+// writeAutoUpdateState records the autoupdate state for when snapd
+// schedules the refreshes
+func writeAutoUpdateState(enabled bool) error {
+	if enabled {
+		if err := os.Remove(autoUpdateDisabledPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	return helpers.AtomicWriteFile(autoUpdateDisabledPath, nil, 0644, helpers.AtomicWriteFollow)
+}
+
+// setRefresh checks and sets the given refresh config. The first time
+// around snapd takes over from the autoupdate timer, which is turned off
+// so that snaps are not refreshed outside of the schedule.
+var setRefresh = func(rc *RefreshConfig) error {
+	if _, err := rc.Schedule(); err != nil {
+		return err
+	}
+
+	content, err := yaml.Marshal(rc)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(refreshConfigPath), 0755); err != nil {
+		return err
+	}
+
+	if refreshScheduled() {
+		return helpers.AtomicWriteFile(refreshConfigPath, content, 0644, helpers.AtomicWriteFollow)
+	}
+
+	enabled, err := getAutoUpdateTimer()
+	if err != nil {
+		return err
+	}
+	if err := writeAutoUpdateState(enabled); err != nil {
+		return err
+	}
+	if err := helpers.AtomicWriteFile(refreshConfigPath, content, 0644, helpers.AtomicWriteFollow); err != nil {
+		return err
+	}
+
+	return setAutoUpdateTimer(false)
+}
+
+// GetRefreshSchedule returns the configured refresh schedule, or nil if
+// refreshes are not scheduled.
+func GetRefreshSchedule() (*RefreshSchedule, error) {
+	rc, err := getRefresh()
+	if err != nil || rc == nil {
+		return nil, err
+	}
+
+	return rc.Schedule()
+}
@@ -0,0 +1,124 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package coreconfig
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type refreshSuite struct{}
+
+var _ = Suite(&refreshSuite{})
+
+func (s *refreshSuite) TestSchedule(c *C) {
+	rc := &RefreshConfig{
+		Days:    []string{"Mon", "wed"},
+		Hours:   "22:00-02:30",
+		Jitter:  "1h",
+		Hold:    "2016-05-01",
+		Metered: "defer",
+	}
+	schedule, err := rc.Schedule()
+	c.Assert(err, IsNil)
+	c.Check(schedule, DeepEquals, &RefreshSchedule{
+		Days:           map[time.Weekday]bool{time.Monday: true, time.Wednesday: true},
+		Start:          22 * time.Hour,
+		End:            2*time.Hour + 30*time.Minute,
+		Jitter:         time.Hour,
+		Hold:           time.Date(2016, 5, 1, 0, 0, 0, 0, time.Local),
+		DeferOnMetered: true,
+	})
+}
+
+func (s *refreshSuite) TestScheduleDefaults(c *C) {
+	schedule, err := (&RefreshConfig{}).Schedule()
+	c.Assert(err, IsNil)
+	c.Check(schedule, DeepEquals, &RefreshSchedule{
+		Days: map[time.Weekday]bool{},
+		End:  24 * time.Hour,
+	})
+}
+
+func (s *refreshSuite) TestScheduleInvalid(c *C) {
+	for _, t := range []struct {
+		rc  RefreshConfig
+		err string
+	}{
+		{RefreshConfig{Days: []string{"someday"}}, `cannot use "someday" as a refresh day`},
+		{RefreshConfig{Hours: "02:00"}, `cannot use "02:00" as refresh hours, expected HH:MM-HH:MM`},
+		{RefreshConfig{Hours: "02:00-25:00"}, `cannot parse "25:00" as a time of the day`},
+		{RefreshConfig{Jitter: "a while"}, `cannot use "a while" as refresh jitter`},
+		{RefreshConfig{Jitter: "-1h"}, `cannot use "-1h" as refresh jitter`},
+		{RefreshConfig{Hold: "tomorrow"}, `cannot use "tomorrow" as refresh hold date, expected YYYY-MM-DD`},
+		{RefreshConfig{Metered: "maybe"}, `cannot use "maybe" for metered networks, expected allow or defer`},
+	} {
+		_, err := t.rc.Schedule()
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
+func (s *refreshSuite) TestNext(c *C) {
+	// 2016-05-02 is a monday
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2016, 5, day, hour, min, 0, 0, time.Local)
+	}
+	schedule, err := (&RefreshConfig{Days: []string{"mon", "wed"}, Hours: "22:00-02:00"}).Schedule()
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		last, now  time.Time
+		start, end time.Time
+	}{
+		// never refreshed, the window is later that day
+		{time.Time{}, at(2, 12, 0), at(2, 22, 0), at(3, 2, 0)},
+		// in the window that started the day before
+		{time.Time{}, at(3, 1, 0), at(3, 1, 0), at(3, 2, 0)},
+		// already refreshed in that window, the next one is on wednesday
+		{at(3, 0, 30), at(3, 1, 0), at(4, 22, 0), at(5, 2, 0)},
+		// on tuesday the next window is on wednesday
+		{at(3, 0, 30), at(3, 12, 0), at(4, 22, 0), at(5, 2, 0)},
+		// after wednesday comes monday
+		{at(4, 23, 0), at(5, 12, 0), at(9, 22, 0), at(10, 2, 0)},
+	} {
+		start, end := schedule.Next(t.last, t.now)
+		c.Check(start, Equals, t.start, Commentf("now %v", t.now))
+		c.Check(end, Equals, t.end, Commentf("now %v", t.now))
+	}
+}
+
+func (s *refreshSuite) TestNextHold(c *C) {
+	schedule, err := (&RefreshConfig{Hold: "2016-05-10"}).Schedule()
+	c.Assert(err, IsNil)
+
+	start, end := schedule.Next(time.Time{}, time.Date(2016, 5, 2, 12, 0, 0, 0, time.Local))
+	c.Check(start, Equals, time.Date(2016, 5, 10, 0, 0, 0, 0, time.Local))
+	c.Check(end, Equals, time.Date(2016, 5, 11, 0, 0, 0, 0, time.Local))
+}
+
+func (s *refreshSuite) TestNextDaily(c *C) {
+	schedule, err := (&RefreshConfig{}).Schedule()
+	c.Assert(err, IsNil)
+
+	now := time.Date(2016, 5, 2, 12, 0, 0, 0, time.Local)
+	start, _ := schedule.Next(now.Add(-time.Hour), now)
+	c.Check(start, Equals, time.Date(2016, 5, 3, 0, 0, 0, 0, time.Local))
+}
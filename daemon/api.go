@@ -21,15 +21,20 @@ package daemon
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -40,6 +45,7 @@ import (
 	"github.com/ubuntu-core/snappy/progress"
 	"github.com/ubuntu-core/snappy/release"
 	"github.com/ubuntu-core/snappy/skills"
+	"github.com/ubuntu-core/snappy/snap"
 	"github.com/ubuntu-core/snappy/snap/lightweight"
 	"github.com/ubuntu-core/snappy/snappy"
 )
@@ -53,8 +59,11 @@ var api = []*Command{
 	sysInfoCmd,
 	appIconCmd,
 	snapsCmd,
+	installCmd,
 	snapCmd,
 	snapConfigCmd,
+	snapHoldCmd,
+	snapQuotaCmd,
 	snapSvcCmd,
 	snapSvcsCmd,
 	snapSvcLogsCmd,
@@ -62,6 +71,10 @@ var api = []*Command{
 	skillsCmd,
 	assertsCmd,
 	assertsFindManyCmd,
+	gcCmd,
+	snapshotsCmd,
+	snapshotCmd,
+	historyCmd,
 }
 
 var (
@@ -90,6 +103,11 @@ var (
 		POST:   sideloadSnap,
 	}
 
+	installCmd = &Command{
+		Path: "/2.0/install",
+		POST: installSnaps,
+	}
+
 	snapCmd = &Command{
 		Path:   "/2.0/snaps/{name}.{origin}",
 		UserOK: true,
@@ -103,6 +121,22 @@ var (
 		PUT:  snapConfig,
 	}
 
+	snapHoldCmd = &Command{
+		Path:   "/2.0/snaps/{name}.{origin}/hold",
+		UserOK: true,
+		GET:    getSnapHold,
+		PUT:    putSnapHold,
+		DELETE: deleteSnapHold,
+	}
+
+	snapQuotaCmd = &Command{
+		Path:   "/2.0/snaps/{name}.{origin}/quota",
+		UserOK: true,
+		GET:    getSnapQuota,
+		PUT:    putSnapQuota,
+		DELETE: deleteSnapQuota,
+	}
+
 	snapSvcsCmd = &Command{
 		Path:   "/2.0/snaps/{name}.{origin}/services",
 		UserOK: true,
@@ -146,6 +180,27 @@ var (
 		UserOK: true,
 		GET:    assertsFindMany,
 	}
+
+	gcCmd = &Command{
+		Path: "/2.0/gc",
+		POST: postGC,
+	}
+
+	snapshotsCmd = &Command{
+		Path: "/2.0/snapshots",
+		GET:  getSnapshots,
+		POST: postSnapshots,
+	}
+
+	snapshotCmd = &Command{
+		Path: "/2.0/snapshots/{id}",
+		GET:  getSnapshot,
+	}
+
+	historyCmd = &Command{
+		Path: "/2.0/history",
+		GET:  getHistory,
+	}
 )
 
 func sysInfo(c *Command, r *http.Request) Response {
@@ -163,20 +218,30 @@ func sysInfo(c *Command, r *http.Request) Response {
 		"api_compat":      apiCompatLevel,
 	}
 
-	if store := snappy.StoreID(); store != "" {
+	if store := snappy.SelectedStoreID(); store != "" {
 		m["store"] = store
 	}
 
+	if c.d != nil && c.d.refresher != nil {
+		if last := c.d.refresher.Last(); !last.IsZero() {
+			m["last_refresh"] = last.Format(time.RFC3339)
+		}
+		if next := c.d.refresher.Next(); !next.IsZero() {
+			m["next_refresh"] = next.Format(time.RFC3339)
+		}
+	}
+
 	return SyncResponse(m)
 }
 
 type metarepo interface {
 	Details(string, string) ([]snappy.Part, error)
 	Find(string) ([]snappy.Part, error)
+	FindPage(snappy.SearchOptions) (*snappy.SearchPage, error)
 }
 
 var newRemoteRepo = func() metarepo {
-	return snappy.NewUbuntuStoreSnapRepository()
+	return snappy.NewStoreRepository()
 }
 
 var muxVars = mux.Vars
@@ -287,6 +352,22 @@ func getSnapsInfo(c *Command, r *http.Request) Response {
 		includeTypes = strings.Split(query["types"][0], ",")
 	}
 
+	sortKey := query.Get("sort")
+	switch sortKey {
+	case "", snappy.SortByName, snappy.SortByLastUpdated, snappy.SortByRatings:
+	default:
+		return BadRequest("invalid sort %q", sortKey)
+	}
+
+	page, err := intParam(query, "page")
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+	size, err := intParam(query, "size")
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
 	var bags map[string]*lightweight.PartBag
 
 	if includeLocal {
@@ -315,23 +396,44 @@ func getSnapsInfo(c *Command, r *http.Request) Response {
 		}
 	}
 
+	pages := 1
+	if page < 1 {
+		page = 1
+	}
+	var order []string
+
 	if includeStore {
 		repo := newRemoteRepo()
-		var found []snappy.Part
 
-		// repo.Find("") finds all
+		opts := snappy.SearchOptions{
+			Query: searchTerm,
+			Sort:  sortKey,
+			Page:  page,
+			Size:  size,
+		}
+		for _, t := range includeTypes {
+			opts.Types = append(opts.Types, snap.Type(t))
+		}
+
+		// repo.FindPage with an empty query finds all
 		//
-		// TODO: Instead of ignoring the error from Find:
+		// TODO: Instead of ignoring other errors from FindPage:
 		//   * if there are no results, return an error response.
 		//   * If there are results at all (perhaps local), include a
 		//     warning in the response
-		found, _ = repo.Find(searchTerm)
+		found, _ := repo.FindPage(opts)
+		if found == nil {
+			found = &snappy.SearchPage{Page: page, Pages: 1}
+		}
+		pages = found.Pages
 
 		sources = append(sources, "store")
 
-		sort.Sort(byQN(found))
+		if sortKey == "" {
+			sort.Sort(byQN(found.Parts))
+		}
 
-		for _, part := range found {
+		for _, part := range found.Parts {
 			name := part.Name()
 			origin := part.Origin()
 
@@ -343,12 +445,27 @@ func getSnapsInfo(c *Command, r *http.Request) Response {
 			fullname := name + "." + origin
 			qn := snappy.QualifiedName(part)
 			results[fullname] = webify(bags[qn].Map(part), url.String())
+			order = append(order, fullname)
+		}
+	}
+
+	// with paging only the snaps on the page of the store are listed,
+	// the local ones would otherwise show up on every page
+	paged := includeStore && (query.Get("page") != "" || size > 0)
+	if paged {
+		onPage := make(map[string]bool, len(order))
+		for _, fullname := range order {
+			onPage[fullname] = true
+		}
+		for fullname := range results {
+			if !onPage[fullname] {
+				delete(results, fullname)
+			}
 		}
 	}
 
-	// TODO: it should be possible to search on	the "content" field on the store
-	//       with multiple values, see:
-	//       https://wiki.ubuntu.com/AppStore/Interfaces/ClickPackageIndex#Search
+	// the store filters its snaps by type itself, the local ones are
+	// filtered here
 	if len(includeTypes) > 0 {
 		for name, result := range results {
 			if !resultHasType(result, includeTypes) {
@@ -357,15 +474,50 @@ func getSnapsInfo(c *Command, r *http.Request) Response {
 		}
 	}
 
-	return SyncResponse(map[string]interface{}{
+	rsp := map[string]interface{}{
 		"snaps":   results,
 		"sources": sources,
 		"paging": map[string]interface{}{
-			"pages": 1,
-			"page":  1,
+			"pages": pages,
+			"page":  page,
 			"count": len(results),
 		},
-	})
+	}
+
+	if sortKey != "" {
+		// the snaps are in the order of the store, followed by the
+		// local snaps it did not return (there are none when paged)
+		seen := make(map[string]bool, len(order))
+		for _, fullname := range order {
+			seen[fullname] = true
+		}
+		var local []string
+		for fullname := range results {
+			if !seen[fullname] {
+				local = append(local, fullname)
+			}
+		}
+		sort.Strings(local)
+		order = append(order, local...)
+		rsp["order"] = order
+	}
+
+	return SyncResponse(rsp)
+}
+
+// intParam returns the value of the given non-negative integer query
+// parameter, 0 if it is not set.
+func intParam(query url.Values, key string) (int, error) {
+	v := query.Get(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s %q", key, v)
+	}
+
+	return n, nil
 }
 
 func resultHasType(r map[string]interface{}, allowedTypes []string) bool {
@@ -569,7 +721,17 @@ func snapConfig(c *Command, r *http.Request) Response {
 	}
 
 	overlord := getConfigurator()
-	config, err := overlord.Configure(part.(*snappy.SnapPart), bs)
+	var config []byte
+	configure := func() (err error) {
+		config, err = overlord.Configure(part.(*snappy.SnapPart), bs)
+		return err
+	}
+
+	if r.Method == "PUT" {
+		err = snappy.Audited(snappy.AuditSourceAPI, requestUID(r), "config", pkgName, configure)
+	} else {
+		err = configure()
+	}
 	if err != nil {
 		return InternalError("unable to retrieve config for %s: %v", pkgName, err)
 	}
@@ -577,6 +739,157 @@ func snapConfig(c *Command, r *http.Request) Response {
 	return SyncResponse(string(config))
 }
 
+func getSnapHold(c *Command, r *http.Request) Response {
+	vars := muxVars(r)
+	name := vars["name"]
+	origin := vars["origin"]
+
+	if lightweight.PartBagByName(name, origin) == nil {
+		return NotFound("no snap found with name %q and origin %q", name, origin)
+	}
+
+	return SyncResponse(snappy.SnapHold(name + "." + origin))
+}
+
+// holdInstruction is the body of a request to hold a snap
+type holdInstruction struct {
+	Until   *time.Time `json:"until"`
+	Version string     `json:"version"`
+}
+
+func putSnapHold(c *Command, r *http.Request) Response {
+	vars := muxVars(r)
+	pkgName := vars["name"] + "." + vars["origin"]
+
+	decoder := json.NewDecoder(r.Body)
+	var inst holdInstruction
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("can't decode request body into hold instruction: %v", err)
+	}
+
+	lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+	if err != nil {
+		return InternalError("unable to acquire lock: %v", err)
+	}
+	defer lock.Unlock()
+
+	hold, err := snappy.SetHold(pkgName, inst.Until, inst.Version)
+	switch err {
+	case nil:
+		return SyncResponse(hold)
+	case snappy.ErrNotInstalled:
+		return NotFound("no snap found with name %q", pkgName)
+	default:
+		return BadRequest("unable to hold %s: %v", pkgName, err)
+	}
+}
+
+func deleteSnapHold(c *Command, r *http.Request) Response {
+	vars := muxVars(r)
+	pkgName := vars["name"] + "." + vars["origin"]
+
+	lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+	if err != nil {
+		return InternalError("unable to acquire lock: %v", err)
+	}
+	defer lock.Unlock()
+
+	switch err := snappy.RemoveHold(pkgName); err {
+	case nil:
+		return SyncResponse(nil)
+	case snappy.ErrNotInstalled:
+		return NotFound("no snap found with name %q", pkgName)
+	default:
+		return InternalError("unable to remove the hold of %s: %v", pkgName, err)
+	}
+}
+
+func getSnapQuota(c *Command, r *http.Request) Response {
+	vars := muxVars(r)
+	name := vars["name"]
+	origin := vars["origin"]
+
+	lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+	if err != nil {
+		return InternalError("unable to acquire lock: %v", err)
+	}
+	defer lock.Unlock()
+
+	bag := lightweight.PartBagByName(name, origin)
+	if bag == nil {
+		return NotFound("no snap found with name %q and origin %q", name, origin)
+	}
+
+	idx := bag.ActiveIndex()
+	if idx < 0 {
+		return BadRequest("unable to get the quota of non-active snap")
+	}
+
+	part, err := bag.Load(idx)
+	if err != nil {
+		return InternalError("unable to load active snap: %v", err)
+	}
+
+	usage, err := snappy.SnapQuotaUsage(part)
+	if err != nil {
+		return InternalError("unable to get the quota usage of %s.%s: %v", name, origin, err)
+	}
+
+	return SyncResponse(usage)
+}
+
+// quotaInstruction is the body of a request to set the quota of a snap
+type quotaInstruction struct {
+	Limit uint64 `json:"limit"`
+}
+
+func putSnapQuota(c *Command, r *http.Request) Response {
+	vars := muxVars(r)
+	pkgName := vars["name"] + "." + vars["origin"]
+
+	decoder := json.NewDecoder(r.Body)
+	var inst quotaInstruction
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("can't decode request body into quota instruction: %v", err)
+	}
+
+	lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+	if err != nil {
+		return InternalError("unable to acquire lock: %v", err)
+	}
+	defer lock.Unlock()
+
+	quota, err := snappy.SetQuota(pkgName, inst.Limit)
+	switch err {
+	case nil:
+		return SyncResponse(quota)
+	case snappy.ErrNotInstalled:
+		return NotFound("no snap found with name %q", pkgName)
+	default:
+		return BadRequest("unable to set the quota of %s: %v", pkgName, err)
+	}
+}
+
+func deleteSnapQuota(c *Command, r *http.Request) Response {
+	vars := muxVars(r)
+	pkgName := vars["name"] + "." + vars["origin"]
+
+	lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+	if err != nil {
+		return InternalError("unable to acquire lock: %v", err)
+	}
+	defer lock.Unlock()
+
+	switch err := snappy.RemoveQuota(pkgName); err {
+	case nil:
+		return SyncResponse(nil)
+	case snappy.ErrNotInstalled:
+		return NotFound("no snap found with name %q", pkgName)
+	default:
+		return InternalError("unable to remove the quota of %s: %v", pkgName, err)
+	}
+}
+
 func getOpInfo(c *Command, r *http.Request) Response {
 	route := c.d.router.Get(c.Path)
 	if route == nil {
@@ -624,10 +937,12 @@ func (*licenseData) Error() string {
 
 type snapInstruction struct {
 	progress.NullProgress
-	Action   string       `json:"action"`
-	LeaveOld bool         `json:"leave_old"`
-	License  *licenseData `json:"license"`
-	pkg      string
+	Action     string       `json:"action"`
+	LeaveOld   bool         `json:"leave_old"`
+	License    *licenseData `json:"license"`
+	Channel    string       `json:"channel"`
+	NoSnapshot bool         `json:"no_snapshot"`
+	pkg        string
 }
 
 // Agreed is part of the progress.Meter interface (q.v.)
@@ -648,7 +963,7 @@ func (inst *snapInstruction) install() interface{} {
 	if inst.LeaveOld {
 		flags = 0
 	}
-	_, err := snappyInstall(inst.pkg, flags, inst)
+	_, err := snappyInstall(inst.pkg, inst.Channel, flags, inst)
 	if err != nil {
 		if inst.License != nil && snappy.IsLicenseNotAccepted(err) {
 			return inst.License
@@ -661,13 +976,15 @@ func (inst *snapInstruction) install() interface{} {
 	return nil
 }
 
+var snappyUpdate = snappy.Update
+
 func (inst *snapInstruction) update() interface{} {
 	flags := snappy.DoInstallGC
 	if inst.LeaveOld {
 		flags = 0
 	}
 
-	_, err := snappy.Update(inst.pkg, flags, inst)
+	_, err := snappyUpdate(inst.pkg, inst.Channel, flags, inst)
 	return err
 }
 
@@ -680,8 +997,15 @@ func (inst *snapInstruction) remove() interface{} {
 	return snappy.Remove(inst.pkg, flags, inst)
 }
 
+var snappyPurge = snappy.Purge
+
 func (inst *snapInstruction) purge() interface{} {
-	return snappy.Purge(inst.pkg, 0, inst)
+	var flags snappy.PurgeFlags
+	if inst.NoSnapshot {
+		flags = snappy.DoPurgeNoSnapshot
+	}
+
+	return snappyPurge(inst.pkg, flags, inst)
 }
 
 func (inst *snapInstruction) rollback() interface{} {
@@ -724,6 +1048,33 @@ func pkgActionDispatchImpl(inst *snapInstruction) func() interface{} {
 
 var pkgActionDispatch = pkgActionDispatchImpl
 
+// requestUID returns the uid of the sender of the request, for the audit
+// log
+func requestUID(r *http.Request) int {
+	uid, err := ucrednetGetUID(r.RemoteAddr)
+	if err != nil {
+		return snappy.AuditUnknownUID
+	}
+
+	return int(uid)
+}
+
+// audited runs the given task function, recording the change it makes
+// in the audit log as the given action on the given snap, on behalf of
+// the given uid
+func audited(uid int, action, snap string, f func() interface{}) interface{} {
+	var out interface{}
+	snappy.Audited(snappy.AuditSourceAPI, uid, action, snap, func() error {
+		out = f()
+		if err, ok := out.(error); ok {
+			return err
+		}
+		return nil
+	})
+
+	return out
+}
+
 func postSnap(c *Command, r *http.Request) Response {
 	route := c.d.router.Get(operationCmd.Path)
 	if route == nil {
@@ -744,16 +1095,200 @@ func postSnap(c *Command, r *http.Request) Response {
 		return BadRequest("unknown action %s", inst.Action)
 	}
 
+	uid := requestUID(r)
 	return AsyncResponse(c.d.AddTask(func() interface{} {
 		lock, err := lockfile.Lock(dirs.SnapLockFile, true)
 		if err != nil {
 			return err
 		}
 		defer lock.Unlock()
+		return audited(uid, inst.Action, inst.pkg, f)
+	}).Map(route))
+}
+
+// gcInstruction is the body of a garbage collection request
+type gcInstruction struct {
+	DryRun bool     `json:"dry-run"`
+	Snaps  []string `json:"snaps"`
+}
+
+var snappyCollectGarbage = snappy.CollectGarbage
+
+func postGC(c *Command, r *http.Request) Response {
+	route := c.d.router.Get(operationCmd.Path)
+	if route == nil {
+		return InternalError("router can't find route for operation")
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var inst gcInstruction
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("can't decode request body into gc instruction: %v", err)
+	}
+
+	var flags snappy.GCFlags
+	if inst.DryRun {
+		flags = snappy.DoGCDryRun
+	}
+
+	return AsyncResponse(c.d.AddTask(func() interface{} {
+		lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		report, err := snappyCollectGarbage(inst.Snaps, flags, &progress.NullProgress{})
+		if err != nil {
+			return err
+		}
+		return report
+	}).Map(route))
+}
+
+func getSnapshots(c *Command, r *http.Request) Response {
+	var names []string
+	if snaps := r.URL.Query().Get("snaps"); snaps != "" {
+		names = strings.Split(snaps, ",")
+	}
+
+	lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+	if err != nil {
+		return InternalError("unable to acquire lock: %v", err)
+	}
+	defer lock.Unlock()
+
+	snapshots, err := snappy.Snapshots(names)
+	if err != nil {
+		return InternalError("cannot list snapshots: %v", err)
+	}
+	if snapshots == nil {
+		snapshots = []*snappy.Snapshot{}
+	}
+
+	return SyncResponse(snapshots)
+}
+
+// getSnapshot exports the snapshot with the given id
+func getSnapshot(c *Command, r *http.Request) Response {
+	vars := muxVars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return BadRequest("invalid snapshot id %q", vars["id"])
+	}
+
+	lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+	if err != nil {
+		return InternalError("unable to acquire lock: %v", err)
+	}
+	defer lock.Unlock()
+
+	path, err := snappy.SnapshotFile(id)
+	if err == snappy.ErrSnapshotNotFound {
+		return NotFound("no snapshot with id %d", id)
+	}
+	if err != nil {
+		return InternalError("cannot export snapshot %d: %v", id, err)
+	}
+
+	return FileResponse(path)
+}
+
+// snapshotInstruction is the body of a request to save or restore a
+// snapshot
+type snapshotInstruction struct {
+	Action  string `json:"action"`
+	Snap    string `json:"snap"`
+	ID      int    `json:"id"`
+	Version string `json:"version"`
+}
+
+var (
+	snappySaveSnapshot    = snappy.SaveSnapshot
+	snappyRestoreSnapshot = snappy.RestoreSnapshot
+	snappyImportSnapshot  = snappy.ImportSnapshot
+)
+
+func postSnapshots(c *Command, r *http.Request) Response {
+	route := c.d.router.Get(operationCmd.Path)
+	if route == nil {
+		return InternalError("router can't find route for operation")
+	}
+
+	if r.Header.Get("Content-Type") == "application/x-tar" {
+		return importSnapshot(c, r, route)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var inst snapshotInstruction
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("can't decode request body into snapshot instruction: %v", err)
+	}
+
+	var f func() interface{}
+	switch inst.Action {
+	case "save":
+		if inst.Snap == "" {
+			return BadRequest("missing snap to save")
+		}
+		f = func() interface{} {
+			snapshot, err := snappySaveSnapshot(inst.Snap)
+			if err != nil {
+				return err
+			}
+			return snapshot
+		}
+	case "restore":
+		if strings.Contains(inst.Version, "/") || strings.Contains(inst.Version, "..") {
+			return BadRequest("invalid version %q", inst.Version)
+		}
+		f = func() interface{} {
+			return snappyRestoreSnapshot(inst.ID, inst.Version)
+		}
+	default:
+		return BadRequest("unknown action %q", inst.Action)
+	}
+
+	return AsyncResponse(c.d.AddTask(func() interface{} {
+		lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
 		return f()
 	}).Map(route))
 }
 
+func importSnapshot(c *Command, r *http.Request, route *mux.Route) Response {
+	tmpf, err := ioutil.TempFile("", "snapd-snapshot-")
+	if err != nil {
+		return InternalError("can't create tempfile: %v", err)
+	}
+	defer tmpf.Close()
+
+	if _, err := io.Copy(tmpf, r.Body); err != nil {
+		os.Remove(tmpf.Name())
+		return InternalError("can't copy request into tempfile: %v", err)
+	}
+
+	return AsyncResponse(c.d.AddTask(func() interface{} {
+		defer os.Remove(tmpf.Name())
+
+		lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		snapshot, err := snappyImportSnapshot(tmpf.Name())
+		if err != nil {
+			return err
+		}
+		return snapshot
+	}).Map(route))
+}
+
 const maxReadBuflen = 1024 * 1024
 
 func newSnapImpl(filename string, origin string, unsignedOk bool) (snappy.Part, error) {
@@ -822,6 +1357,7 @@ func sideloadSnap(c *Command, r *http.Request) Response {
 		return InternalError("can't copy request into tempfile: %v", err)
 	}
 
+	uid := requestUID(r)
 	return AsyncResponse(c.d.AddTask(func() interface{} {
 		defer os.Remove(tmpf.Name())
 
@@ -840,13 +1376,135 @@ func sideloadSnap(c *Command, r *http.Request) Response {
 		if unsignedOk {
 			flags |= snappy.AllowUnauthenticated
 		}
-		overlord := &snappy.Overlord{}
-		name, err := overlord.Install(tmpf.Name(), snappy.SideloadedOrigin, flags, &progress.NullProgress{})
+		return audited(uid, "install", "", func() interface{} {
+			overlord := &snappy.Overlord{}
+			name, err := overlord.Install(tmpf.Name(), snappy.SideloadedOrigin, flags, &progress.NullProgress{})
+			if err != nil {
+				return err
+			}
+
+			return name
+		})
+	}).Map(route))
+}
+
+// installInstruction is the body of a request to install several snaps
+// from the store, or a snap from a URL, in one operation
+type installInstruction struct {
+	Action        string   `json:"action"`
+	Snaps         []string `json:"snaps"`
+	URL           string   `json:"url"`
+	Sha512        string   `json:"sha512"`
+	AllowUnsigned bool     `json:"allow_unsigned"`
+	Channel       string   `json:"channel"`
+	LeaveOld      bool     `json:"leave_old"`
+}
+
+var (
+	snappyInstallMany = snappy.InstallMany
+	snappyInstallURL  = snappy.InstallURL
+)
+
+func installSnaps(c *Command, r *http.Request) Response {
+	route := c.d.router.Get(operationCmd.Path)
+	if route == nil {
+		return InternalError("router can't find route for operation")
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	var inst installInstruction
+	if err := decoder.Decode(&inst); err != nil {
+		return BadRequest("can't decode request body into install instruction: %v", err)
+	}
+	if inst.Action != "install" {
+		return BadRequest("unknown action %s", inst.Action)
+	}
+
+	flags := snappy.DoInstallGC
+	if inst.LeaveOld {
+		flags = 0
+	}
+
+	if inst.URL != "" {
+		if len(inst.Snaps) != 0 {
+			return BadRequest("cannot install snaps and a url in one request")
+		}
+		return installSnapFromURL(c, r, route, &inst, flags)
+	}
+	if len(inst.Snaps) == 0 {
+		return BadRequest("no snaps to install")
+	}
+	seen := make(map[string]bool, len(inst.Snaps))
+	for _, name := range inst.Snaps {
+		bare, _ := snappy.SplitOrigin(name)
+		if seen[bare] {
+			return BadRequest("cannot install %q more than once", bare)
+		}
+		seen[bare] = true
+	}
+
+	var mu sync.Mutex
+	results := make(map[string]snappy.InstallManyResult, len(inst.Snaps))
+	report := func(res snappy.InstallManyResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[res.Name] = res
+	}
+	output := func() interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		out := make([]snappy.InstallManyResult, 0, len(results))
+		for _, name := range inst.Snaps {
+			if res, ok := results[name]; ok {
+				out = append(out, res)
+			}
+		}
+		return out
+	}
+
+	uid := requestUID(r)
+	return AsyncResponse(c.d.AddTaskWithProgress(func() interface{} {
+		lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+		if err != nil {
+			return err
+		}
+		defer lock.Unlock()
+
+		return audited(uid, "install", strings.Join(inst.Snaps, ","), func() interface{} {
+			res, err := snappyInstallMany(inst.Snaps, inst.Channel, flags, &progress.NullProgress{}, report)
+			if err != nil {
+				return err
+			}
+
+			return res
+		})
+	}, output).Map(route))
+}
+
+func installSnapFromURL(c *Command, r *http.Request, route *mux.Route, inst *installInstruction, flags snappy.InstallFlags) Response {
+	if !snappy.IsSnapURL(inst.URL) {
+		return BadRequest("url must be an http or https URL, not %q", inst.URL)
+	}
+	if inst.AllowUnsigned {
+		flags |= snappy.AllowUnauthenticated
+	}
+
+	uid := requestUID(r)
+	return AsyncResponse(c.d.AddTask(func() interface{} {
+		lock, err := lockfile.Lock(dirs.SnapLockFile, true)
 		if err != nil {
 			return err
 		}
+		defer lock.Unlock()
+
+		return audited(uid, "install", inst.URL, func() interface{} {
+			name, err := snappyInstallURL(inst.URL, inst.Sha512, flags, &progress.NullProgress{})
+			if err != nil {
+				return err
+			}
 
-		return name
+			return name
+		})
 	}).Map(route))
 }
 
@@ -920,21 +1578,32 @@ func appIconGet(c *Command, r *http.Request) Response {
 
 // skillGrant holds the identification of a slot that has been granted to a skill.
 type skillGrant struct {
-	Snap string `json:"snap"`
-	Name string `json:"name"`
+	Snap string   `json:"snap"`
+	Name string   `json:"name"`
+	Apps []string `json:"apps,omitempty"`
 }
 
 // skillInfo holds details for a skill as returned by the REST API.
 type skillInfo struct {
-	Snap      string       `json:"snap"`
-	Name      string       `json:"name"`
-	Type      string       `json:"type"`
-	Label     string       `json:"label"`
-	GrantedTo []skillGrant `json:"granted_to"`
+	Snap      string                 `json:"snap"`
+	Name      string                 `json:"name"`
+	Type      string                 `json:"type"`
+	Label     string                 `json:"label"`
+	Attrs     map[string]interface{} `json:"attrs,omitempty"`
+	Apps      []string               `json:"apps,omitempty"`
+	GrantedTo []skillGrant           `json:"granted_to"`
 }
 
 // getSkills returns a response with a list of all the skills and which slots use them.
+// With view=graph the whole skill graph is returned instead, see getSkillGraph.
 func getSkills(c *Command, r *http.Request) Response {
+	switch view := r.URL.Query().Get("view"); view {
+	case "":
+	case "graph":
+		return getSkillGraph(c, r)
+	default:
+		return BadRequest("unsupported skills view: %q", view)
+	}
 	var skills []skillInfo
 	for _, skill := range c.d.skills.AllSkills("") {
 		var slots []skillGrant
@@ -942,6 +1611,7 @@ func getSkills(c *Command, r *http.Request) Response {
 			slots = append(slots, skillGrant{
 				Snap: slot.Snap,
 				Name: slot.Name,
+				Apps: slot.Apps,
 			})
 		}
 		skills = append(skills, skillInfo{
@@ -949,12 +1619,87 @@ func getSkills(c *Command, r *http.Request) Response {
 			Name:      skill.Name,
 			Type:      skill.Type,
 			Label:     skill.Label,
+			Attrs:     skill.Attrs,
+			Apps:      skill.Apps,
 			GrantedTo: slots,
 		})
 	}
 	return SyncResponse(skills)
 }
 
+// skillNode holds details for a skill or a slot in the skill graph.
+type skillNode struct {
+	Snap  string                 `json:"snap"`
+	Name  string                 `json:"name"`
+	Type  string                 `json:"type"`
+	Label string                 `json:"label,omitempty"`
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+	Apps  []string               `json:"apps,omitempty"`
+}
+
+// skillEdge holds a single grant in the skill graph.
+// The skill is the offering side and the slot is the using side.
+type skillEdge struct {
+	Skill skillGrant `json:"skill"`
+	Slot  skillGrant `json:"slot"`
+}
+
+// skillGraph holds all the skills, slots and grants in the system.
+type skillGraph struct {
+	Skills []skillNode `json:"skills"`
+	Slots  []skillNode `json:"slots"`
+	Grants []skillEdge `json:"grants"`
+}
+
+// getSkillGraph returns a response with all the skills, slots and grants.
+func getSkillGraph(c *Command, r *http.Request) Response {
+	repo := c.d.skills
+	graph := skillGraph{
+		Skills: []skillNode{},
+		Slots:  []skillNode{},
+		Grants: []skillEdge{},
+	}
+	offering := make(map[string]bool)
+	for _, skill := range repo.AllSkills("") {
+		graph.Skills = append(graph.Skills, skillNode{
+			Snap:  skill.Snap,
+			Name:  skill.Name,
+			Type:  skill.Type,
+			Label: skill.Label,
+			Attrs: skill.Attrs,
+			Apps:  skill.Apps,
+		})
+		offering[skill.Snap] = true
+	}
+	for _, slot := range repo.AllSlots("") {
+		graph.Slots = append(graph.Slots, skillNode{
+			Snap:  slot.Snap,
+			Name:  slot.Name,
+			Type:  slot.Type,
+			Label: slot.Label,
+			Attrs: slot.Attrs,
+			Apps:  slot.Apps,
+		})
+	}
+	var snapNames []string
+	for snapName := range offering {
+		snapNames = append(snapNames, snapName)
+	}
+	sort.Strings(snapNames)
+	for _, snapName := range snapNames {
+		granted := repo.GrantedBy(snapName)
+		for _, skill := range repo.Skills(snapName) {
+			for _, slot := range granted[skill] {
+				graph.Grants = append(graph.Grants, skillEdge{
+					Skill: skillGrant{Snap: skill.Snap, Name: skill.Name},
+					Slot:  skillGrant{Snap: slot.Snap, Name: slot.Name},
+				})
+			}
+		}
+	}
+	return SyncResponse(graph)
+}
+
 // skillAction is an action performed on the skill system.
 type skillAction struct {
 	Action string       `json:"action"`
@@ -1064,3 +1809,22 @@ func assertsFindMany(c *Command, r *http.Request) Response {
 	}
 	return AssertResponse(assertions, true)
 }
+
+// getHistory returns the entries of the audit log, optionally only those
+// of the given snaps
+func getHistory(c *Command, r *http.Request) Response {
+	var names []string
+	if snaps := r.URL.Query().Get("snaps"); snaps != "" {
+		names = strings.Split(snaps, ",")
+	}
+
+	entries, err := snappy.AuditLog(names)
+	if err != nil {
+		return InternalError("cannot read the audit log: %v", err)
+	}
+	if entries == nil {
+		entries = []snappy.AuditEntry{}
+	}
+
+	return SyncResponse(entries)
+}
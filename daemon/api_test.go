@@ -55,6 +55,8 @@ type apiSuite struct {
 	err        error
 	vars       map[string]string
 	searchTerm string
+	searchOpts snappy.SearchOptions
+	pages      int
 	overlord   *fakeOverlord
 }
 
@@ -74,6 +76,24 @@ func (s *apiSuite) Find(searchTerm string) ([]snappy.Part, error) {
 	return s.parts, s.err
 }
 
+func (s *apiSuite) FindPage(opts snappy.SearchOptions) (*snappy.SearchPage, error) {
+	s.searchTerm = opts.Query
+	s.searchOpts = opts
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	page := &snappy.SearchPage{Parts: s.parts, Page: opts.Page, Pages: s.pages}
+	if page.Page < 1 {
+		page.Page = 1
+	}
+	if page.Pages < page.Page {
+		page.Pages = page.Page
+	}
+
+	return page, nil
+}
+
 func (s *apiSuite) muxVars(*http.Request) map[string]string {
 	return s.vars
 }
@@ -98,6 +118,8 @@ func (s *apiSuite) SetUpTest(c *check.C) {
 	s.parts = nil
 	s.err = nil
 	s.vars = nil
+	s.searchOpts = snappy.SearchOptions{}
+	s.pages = 0
 	s.overlord = &fakeOverlord{
 		configs: map[string]string{},
 	}
@@ -292,8 +314,18 @@ func (s *apiSuite) TestListIncludesAll(c *check.C) {
 		"newRemoteRepo",
 		"newSnap",
 		"pkgActionDispatch",
+		"snappyCollectGarbage",
 		// snapInstruction vars:
 		"snappyInstall",
+		"snappyPurge",
+		"snappyUpdate",
+		// snapshotInstruction vars:
+		"snappySaveSnapshot",
+		"snappyRestoreSnapshot",
+		"snappyImportSnapshot",
+		// installInstruction vars:
+		"snappyInstallMany",
+		"snappyInstallURL",
 		"getConfigurator",
 	}
 	c.Check(found, check.Equals, len(api)+len(exceptions),
@@ -577,6 +609,97 @@ func (s *apiSuite) TestSnapsInfoAppsAndFrameworks(c *check.C) {
 	c.Assert(snaps, check.HasLen, 2)
 }
 
+func (s *apiSuite) TestSnapsInfoStoreTypes(c *check.C) {
+	s.parts = []snappy.Part{&tP{name: "store", origin: "foo", _type: snap.TypeFramework}}
+
+	req, err := http.NewRequest("GET", "/2.0/snaps?sources=store&types=app,framework", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getSnapsInfo(snapsCmd, req).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+
+	c.Check(s.searchOpts.Types, check.DeepEquals, []snap.Type{snap.TypeApp, snap.TypeFramework})
+}
+
+func (s *apiSuite) TestSnapsInfoPaging(c *check.C) {
+	s.parts = []snappy.Part{&tP{name: "store", origin: "foo"}}
+	s.pages = 3
+
+	req, err := http.NewRequest("GET", "/2.0/snaps?sources=store&page=2&size=1", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getSnapsInfo(snapsCmd, req).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+
+	c.Check(s.searchOpts.Page, check.Equals, 2)
+	c.Check(s.searchOpts.Size, check.Equals, 1)
+
+	result := rsp.Result.(map[string]interface{})
+	c.Check(result["paging"], check.DeepEquals, map[string]interface{}{
+		"pages": 3,
+		"page":  2,
+		"count": 1,
+	})
+	c.Check(result["order"], check.IsNil)
+}
+
+func (s *apiSuite) TestSnapsInfoPagingLeavesOutLocal(c *check.C) {
+	s.parts = []snappy.Part{&tP{name: "store", origin: "foo"}}
+	s.pages = 3
+	s.mkInstalled(c, "local", "foo", "v1", true, "")
+	s.mkInstalled(c, "store", "foo", "v1", true, "")
+
+	req, err := http.NewRequest("GET", "/2.0/snaps?page=2&sort=name", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getSnapsInfo(snapsCmd, req).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+
+	// only the snaps on the page of the store are listed, with their
+	// local state
+	result := rsp.Result.(map[string]interface{})
+	snaps := result["snaps"].(map[string]map[string]interface{})
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps["store.foo"]["status"], check.Equals, "active")
+	c.Check(result["order"], check.DeepEquals, []string{"store.foo"})
+	c.Check(result["paging"], check.DeepEquals, map[string]interface{}{
+		"pages": 3,
+		"page":  2,
+		"count": 1,
+	})
+}
+
+func (s *apiSuite) TestSnapsInfoSort(c *check.C) {
+	s.parts = []snappy.Part{
+		&tP{name: "zed", origin: "foo"},
+		&tP{name: "abc", origin: "foo"},
+	}
+	s.mkInstalled(c, "local", "foo", "v1", true, "")
+
+	req, err := http.NewRequest("GET", "/2.0/snaps?sort=ratings", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getSnapsInfo(snapsCmd, req).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+
+	c.Check(s.searchOpts.Sort, check.Equals, snappy.SortByRatings)
+
+	// the order of the store is kept, local snaps come last
+	result := rsp.Result.(map[string]interface{})
+	c.Check(result["order"], check.DeepEquals, []string{"zed.foo", "abc.foo", "local.foo"})
+}
+
+func (s *apiSuite) TestSnapsInfoBadParams(c *check.C) {
+	for _, q := range []string{"sort=popularity", "page=x", "size=-1"} {
+		req, err := http.NewRequest("GET", "/2.0/snaps?"+q, nil)
+		c.Assert(err, check.IsNil)
+
+		rsp := getSnapsInfo(snapsCmd, req).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError, check.Commentf(q))
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest, check.Commentf(q))
+	}
+}
+
 func (s *apiSuite) TestDeleteOpNotFound(c *check.C) {
 	s.vars = map[string]string{"uuid": "42"}
 	rsp := deleteOp(operationCmd, nil).Self(nil, nil).(*resp)
@@ -808,6 +931,128 @@ func (s *apiSuite) TestSnapGetConfig(c *check.C) {
 	})
 }
 
+func (s *apiSuite) TestSnapHold(c *check.C) {
+	s.vars = map[string]string{"name": "foo", "origin": "bar"}
+	s.mkInstalled(c, "foo", "bar", "v1", true, "")
+
+	req, err := http.NewRequest("GET", "/2.0/snaps/foo.bar/hold", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSnapHold(snapHoldCmd, req).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(rsp.Result, check.IsNil)
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	body := fmt.Sprintf(`{"until": %q, "version": "v2"}`, until.Format(time.RFC3339))
+	req, err = http.NewRequest("PUT", "/2.0/snaps/foo.bar/hold", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+	rsp = putSnapHold(snapHoldCmd, req).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	hold := &snappy.Hold{Snap: "foo.bar", Until: &until, Version: "v2"}
+	c.Check(rsp.Result, check.DeepEquals, hold)
+
+	req, err = http.NewRequest("GET", "/2.0/snaps/foo.bar/hold", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getSnapHold(snapHoldCmd, req).(*resp)
+	c.Check(rsp.Result, check.DeepEquals, hold)
+
+	req, err = http.NewRequest("DELETE", "/2.0/snaps/foo.bar/hold", nil)
+	c.Assert(err, check.IsNil)
+	rsp = deleteSnapHold(snapHoldCmd, req).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(snappy.SnapHold("foo.bar"), check.IsNil)
+}
+
+func (s *apiSuite) TestSnapQuota(c *check.C) {
+	s.vars = map[string]string{"name": "foo", "origin": "bar"}
+	s.mkInstalled(c, "foo", "bar", "v1", true, "")
+	dataFile := filepath.Join(dirs.SnapDataDir, "foo.bar", "v1", "data")
+	c.Assert(ioutil.WriteFile(dataFile, make([]byte, 950), 0644), check.IsNil)
+
+	req, err := http.NewRequest("GET", "/2.0/snaps/foo.bar/quota", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSnapQuota(snapQuotaCmd, req).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(rsp.Result, check.IsNil)
+
+	req, err = http.NewRequest("PUT", "/2.0/snaps/foo.bar/quota", bytes.NewBufferString(`{"limit": 1000}`))
+	c.Assert(err, check.IsNil)
+	rsp = putSnapQuota(snapQuotaCmd, req).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	quota := rsp.Result.(*snappy.Quota)
+	c.Check(quota.Snap, check.Equals, "foo.bar")
+	c.Check(quota.Limit, check.Equals, uint64(1000))
+
+	req, err = http.NewRequest("GET", "/2.0/snaps/foo.bar/quota", nil)
+	c.Assert(err, check.IsNil)
+	rsp = getSnapQuota(snapQuotaCmd, req).(*resp)
+	usage := rsp.Result.(*snappy.QuotaUsage)
+	c.Check(usage.Snap, check.Equals, "foo.bar")
+	c.Check(usage.Version, check.Equals, "v1")
+	c.Check(usage.Limit, check.Equals, uint64(1000))
+	c.Check(usage.Usage >= 950, check.Equals, true)
+	c.Check(usage.Warn(), check.Equals, true)
+
+	req, err = http.NewRequest("DELETE", "/2.0/snaps/foo.bar/quota", nil)
+	c.Assert(err, check.IsNil)
+	rsp = deleteSnapQuota(snapQuotaCmd, req).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusOK)
+	c.Check(snappy.SnapQuota("foo.bar"), check.IsNil)
+}
+
+func (s *apiSuite) TestSnapQuotaErrors(c *check.C) {
+	s.vars = map[string]string{"name": "foo", "origin": "bar"}
+
+	req, err := http.NewRequest("GET", "/2.0/snaps/foo.bar/quota", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSnapQuota(snapQuotaCmd, req).Self(nil, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+
+	req, err = http.NewRequest("PUT", "/2.0/snaps/foo.bar/quota", bytes.NewBufferString(`{"limit": 1000}`))
+	c.Assert(err, check.IsNil)
+	rsp = putSnapQuota(snapQuotaCmd, req).Self(nil, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+
+	req, err = http.NewRequest("DELETE", "/2.0/snaps/foo.bar/quota", nil)
+	c.Assert(err, check.IsNil)
+	rsp = deleteSnapQuota(snapQuotaCmd, req).Self(nil, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+
+	s.mkInstalled(c, "foo", "bar", "v1", true, "")
+	for _, body := range []string{`garbage`, `{"limit": 0}`} {
+		req, err = http.NewRequest("PUT", "/2.0/snaps/foo.bar/quota", bytes.NewBufferString(body))
+		c.Assert(err, check.IsNil)
+		rsp = putSnapQuota(snapQuotaCmd, req).Self(nil, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest, check.Commentf(body))
+	}
+}
+
+func (s *apiSuite) TestSnapHoldErrors(c *check.C) {
+	s.vars = map[string]string{"name": "foo", "origin": "bar"}
+
+	req, err := http.NewRequest("GET", "/2.0/snaps/foo.bar/hold", nil)
+	c.Assert(err, check.IsNil)
+	rsp := getSnapHold(snapHoldCmd, req).Self(nil, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+
+	req, err = http.NewRequest("PUT", "/2.0/snaps/foo.bar/hold", bytes.NewBufferString(`{}`))
+	c.Assert(err, check.IsNil)
+	rsp = putSnapHold(snapHoldCmd, req).Self(nil, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+
+	req, err = http.NewRequest("DELETE", "/2.0/snaps/foo.bar/hold", nil)
+	c.Assert(err, check.IsNil)
+	rsp = deleteSnapHold(snapHoldCmd, req).Self(nil, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+
+	s.mkInstalled(c, "foo", "bar", "v1", true, "")
+	for _, body := range []string{`garbage`, `{"until": "2016-01-01T00:00:00Z"}`} {
+		req, err = http.NewRequest("PUT", "/2.0/snaps/foo.bar/hold", bytes.NewBufferString(body))
+		c.Assert(err, check.IsNil)
+		rsp = putSnapHold(snapHoldCmd, req).Self(nil, nil).(*resp)
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest, check.Commentf(body))
+	}
+}
+
 func (s *apiSuite) TestSnapGetConfigMissing(c *check.C) {
 	s.vars = map[string]string{"name": "foo", "origin": "bar"}
 
@@ -965,6 +1210,102 @@ func (s *apiSuite) TestSideloadSnap(c *check.C) {
 	s.sideloadCheck(c, "----hello--\r\nContent-Disposition: form-data; name=\"unsigned-ok\"\r\n\r\n----hello--\r\nContent-Disposition: form-data; name=\"x\"; filename=\"x\"\r\n\r\nxyzzy\r\n----hello----\r\n", false, map[string]string{"Content-Type": "multipart/thing; boundary=--hello--"})
 }
 
+func (s *apiSuite) TestInstallSnaps(c *check.C) {
+	orig := snappyInstallMany
+	defer func() { snappyInstallMany = orig }()
+
+	d := newTestDaemon()
+
+	reported := make(chan struct{})
+	done := make(chan struct{})
+	results := []snappy.InstallManyResult{
+		{Name: "foo", Version: "1", Status: snappy.InstallManyInstalled},
+		{Name: "bar", Version: "2", Status: snappy.InstallManyInstalled},
+	}
+	snappyInstallMany = func(names []string, channel string, flags snappy.InstallFlags, meter progress.Meter, report func(snappy.InstallManyResult)) ([]snappy.InstallManyResult, error) {
+		c.Check(names, check.DeepEquals, []string{"foo", "bar"})
+		c.Check(channel, check.Equals, "edge")
+		c.Check(flags, check.Equals, snappy.DoInstallGC)
+
+		report(snappy.InstallManyResult{Name: "bar", Status: snappy.InstallManyDownloading})
+		report(snappy.InstallManyResult{Name: "foo", Status: snappy.InstallManyInstalling})
+		reported <- struct{}{}
+		<-done
+
+		return results, nil
+	}
+
+	buf := bytes.NewBufferString(`{"action": "install", "snaps": ["foo", "bar"], "channel": "edge"}`)
+	req, err := http.NewRequest("POST", "/2.0/install", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := installSnaps(installCmd, req).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	m := rsp.Result.(map[string]interface{})
+	task := d.GetTask(m["resource"].(string)[16:])
+	c.Assert(task, check.NotNil)
+
+	<-reported
+	c.Check(task.State(), check.Equals, TaskRunning)
+	c.Check(task.Output(), check.DeepEquals, []snappy.InstallManyResult{
+		{Name: "foo", Status: snappy.InstallManyInstalling},
+		{Name: "bar", Status: snappy.InstallManyDownloading},
+	})
+
+	close(done)
+	task.tomb.Wait()
+	c.Check(task.State(), check.Equals, TaskSucceeded)
+	c.Check(task.Output(), check.DeepEquals, results)
+}
+
+func (s *apiSuite) TestInstallSnapFromURL(c *check.C) {
+	orig := snappyInstallURL
+	defer func() { snappyInstallURL = orig }()
+
+	d := newTestDaemon()
+
+	snappyInstallURL = func(snapURL, sha512 string, flags snappy.InstallFlags, meter progress.Meter) (string, error) {
+		c.Check(snapURL, check.Equals, "https://example.com/foo.snap")
+		c.Check(sha512, check.Equals, "abcd")
+		c.Check(flags, check.Equals, snappy.AllowUnauthenticated)
+		return "foo", nil
+	}
+
+	buf := bytes.NewBufferString(`{"action": "install", "url": "https://example.com/foo.snap", "sha512": "abcd", "allow_unsigned": true, "leave_old": true}`)
+	req, err := http.NewRequest("POST", "/2.0/install", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := installSnaps(installCmd, req).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	m := rsp.Result.(map[string]interface{})
+	task := d.GetTask(m["resource"].(string)[16:])
+	c.Assert(task, check.NotNil)
+
+	task.tomb.Wait()
+	c.Check(task.State(), check.Equals, TaskSucceeded)
+	c.Check(task.Output(), check.Equals, "foo")
+}
+
+func (s *apiSuite) TestInstallSnapsBadRequest(c *check.C) {
+	newTestDaemon()
+
+	for _, body := range []string{
+		`garbage`,
+		`{"action": "install"}`,
+		`{"action": "remove", "snaps": ["foo"]}`,
+		`{"action": "install", "snaps": ["foo"], "url": "https://example.com/foo.snap"}`,
+		`{"action": "install", "snaps": ["foo", "bar", "foo.baz"]}`,
+		`{"action": "install", "url": "ftp://example.com/foo.snap"}`,
+	} {
+		req, err := http.NewRequest("POST", "/2.0/install", bytes.NewBufferString(body))
+		c.Assert(err, check.IsNil)
+
+		rsp := installSnaps(installCmd, req).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError, check.Commentf(body))
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest, check.Commentf(body))
+	}
+}
+
 func (s *apiSuite) sideloadCheck(c *check.C, content string, unsignedExpected bool, head map[string]string) {
 	ch := make(chan struct{})
 	tmpfile, err := ioutil.TempFile("", "test-")
@@ -1132,7 +1473,7 @@ func (s *apiSuite) TestInstall(c *check.C) {
 
 	calledFlags := snappy.InstallFlags(42)
 
-	snappyInstall = func(name string, flags snappy.InstallFlags, meter progress.Meter) (string, error) {
+	snappyInstall = func(name, channel string, flags snappy.InstallFlags, meter progress.Meter) (string, error) {
 		calledFlags = flags
 
 		return "", nil
@@ -1148,13 +1489,84 @@ func (s *apiSuite) TestInstall(c *check.C) {
 	c.Check(err, check.IsNil)
 }
 
+func (s *apiSuite) TestInstallChannel(c *check.C) {
+	orig := snappyInstall
+	defer func() { snappyInstall = orig }()
+
+	calledChannel := ""
+
+	snappyInstall = func(name, channel string, flags snappy.InstallFlags, meter progress.Meter) (string, error) {
+		calledChannel = channel
+
+		return "", nil
+	}
+
+	inst := &snapInstruction{
+		Action:  "install",
+		Channel: "beta",
+	}
+
+	err := inst.dispatch()()
+
+	c.Check(calledChannel, check.Equals, "beta")
+	c.Check(err, check.IsNil)
+}
+
+func (s *apiSuite) TestUpdateChannel(c *check.C) {
+	orig := snappyUpdate
+	defer func() { snappyUpdate = orig }()
+
+	calledName := ""
+	calledChannel := ""
+
+	snappyUpdate = func(name, channel string, flags snappy.InstallFlags, meter progress.Meter) ([]snappy.Part, error) {
+		calledName = name
+		calledChannel = channel
+
+		return nil, nil
+	}
+
+	d := newTestDaemon()
+	req, err := http.NewRequest("POST", "/2.0/snaps/foo.bar", strings.NewReader(`{"action": "update", "channel": "edge"}`))
+	c.Assert(err, check.IsNil)
+	s.vars = map[string]string{"name": "foo", "origin": "bar"}
+
+	res := postSnap(snapCmd, req).(*resp).Result.(map[string]interface{})
+	task := d.tasks[res["resource"].(string)[16:]]
+	c.Assert(task, check.NotNil)
+
+	task.tomb.Wait()
+	c.Check(task.State(), check.Equals, TaskSucceeded)
+	c.Check(calledName, check.Equals, "foo.bar")
+	c.Check(calledChannel, check.Equals, "edge")
+}
+
+func (s *apiSuite) TestPurgeNoSnapshot(c *check.C) {
+	orig := snappyPurge
+	defer func() { snappyPurge = orig }()
+
+	var calledFlags []snappy.PurgeFlags
+	snappyPurge = func(partSpec string, flags snappy.PurgeFlags, meter progress.Meter) error {
+		calledFlags = append(calledFlags, flags)
+		return nil
+	}
+
+	inst := &snapInstruction{Action: "purge", pkg: "foo.bar"}
+	c.Check(inst.dispatch()(), check.IsNil)
+
+	inst = &snapInstruction{Action: "purge", NoSnapshot: true, pkg: "foo.bar"}
+	c.Check(inst.dispatch()(), check.IsNil)
+
+	c.Check(calledFlags, check.DeepEquals, []snappy.PurgeFlags{0, snappy.DoPurgeNoSnapshot})
+}
+
 func (s *apiSuite) TestInstallLeaveOld(c *check.C) {
 	orig := snappyInstall
 	defer func() { snappyInstall = orig }()
 
 	calledFlags := snappy.InstallFlags(42)
 
-	snappyInstall = func(name string, flags snappy.InstallFlags, meter progress.Meter) (string, error) {
+	snappyInstall = func(name, channel string, flags snappy.InstallFlags, meter progress.Meter) (string, error) {
 		calledFlags = flags
 
 		return "", nil
@@ -1175,7 +1587,7 @@ func (s *apiSuite) TestInstallLicensed(c *check.C) {
 	orig := snappyInstall
 	defer func() { snappyInstall = orig }()
 
-	snappyInstall = func(name string, flags snappy.InstallFlags, meter progress.Meter) (string, error) {
+	snappyInstall = func(name, channel string, flags snappy.InstallFlags, meter progress.Meter) (string, error) {
 		if meter.Agreed("hi", "yak yak") {
 			return "", nil
 		}
@@ -1208,7 +1620,7 @@ func (s *apiSuite) TestInstallLicensedIntegration(c *check.C) {
 	orig := snappyInstall
 	defer func() { snappyInstall = orig }()
 
-	snappyInstall = func(name string, flags snappy.InstallFlags, meter progress.Meter) (string, error) {
+	snappyInstall = func(name, channel string, flags snappy.InstallFlags, meter progress.Meter) (string, error) {
 		if meter.Agreed("hi", "yak yak") {
 			return "", nil
 		}
@@ -1250,8 +1662,8 @@ func (s *apiSuite) TestInstallLicensedIntegration(c *check.C) {
 func (s *apiSuite) TestGetSkills(c *check.C) {
 	d := newTestDaemon()
 	d.skills.AddType(&skills.TestType{TypeName: "type"})
-	d.skills.AddSkill(&skills.Skill{Snap: "producer", Name: "skill", Type: "type", Label: "label"})
-	d.skills.AddSlot(&skills.Slot{Snap: "consumer", Name: "slot", Type: "type"})
+	d.skills.AddSkill(&skills.Skill{Snap: "producer", Name: "skill", Type: "type", Label: "label", Attrs: map[string]interface{}{"attr": "value"}, Apps: []string{"daemon"}})
+	d.skills.AddSlot(&skills.Slot{Snap: "consumer", Name: "slot", Type: "type", Apps: []string{"app"}})
 	d.skills.Grant("producer", "skill", "consumer", "slot")
 	req, err := http.NewRequest("GET", "/2.0/skills", nil)
 	c.Assert(err, check.IsNil)
@@ -1268,10 +1680,13 @@ func (s *apiSuite) TestGetSkills(c *check.C) {
 				"name":  "skill",
 				"type":  "type",
 				"label": "label",
+				"attrs": map[string]interface{}{"attr": "value"},
+				"apps":  []interface{}{"daemon"},
 				"granted_to": []interface{}{
 					map[string]interface{}{
 						"snap": "consumer",
 						"name": "slot",
+						"apps": []interface{}{"app"},
 					},
 				},
 			},
@@ -1282,6 +1697,67 @@ func (s *apiSuite) TestGetSkills(c *check.C) {
 	})
 }
 
+func (s *apiSuite) TestGetSkillGraph(c *check.C) {
+	d := newTestDaemon()
+	d.skills.AddType(&skills.TestType{TypeName: "type"})
+	d.skills.AddSkill(&skills.Skill{Snap: "producer", Name: "skill", Type: "type", Label: "label", Attrs: map[string]interface{}{"attr": "value"}})
+	d.skills.AddSkill(&skills.Skill{Snap: "producer", Name: "unused", Type: "type"})
+	d.skills.AddSlot(&skills.Slot{Snap: "consumer", Name: "slot", Type: "type", Apps: []string{"app"}})
+	d.skills.Grant("producer", "skill", "consumer", "slot")
+	req, err := http.NewRequest("GET", "/2.0/skills?view=graph", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	skillsCmd.GET(skillsCmd, req).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 200)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"skills": []interface{}{
+				map[string]interface{}{
+					"snap":  "producer",
+					"name":  "skill",
+					"type":  "type",
+					"label": "label",
+					"attrs": map[string]interface{}{"attr": "value"},
+				},
+				map[string]interface{}{
+					"snap": "producer",
+					"name": "unused",
+					"type": "type",
+				},
+			},
+			"slots": []interface{}{
+				map[string]interface{}{
+					"snap": "consumer",
+					"name": "slot",
+					"type": "type",
+					"apps": []interface{}{"app"},
+				},
+			},
+			"grants": []interface{}{
+				map[string]interface{}{
+					"skill": map[string]interface{}{"snap": "producer", "name": "skill"},
+					"slot":  map[string]interface{}{"snap": "consumer", "name": "slot"},
+				},
+			},
+		},
+		"status":      "OK",
+		"status_code": 200.0,
+		"type":        "sync",
+	})
+}
+
+func (s *apiSuite) TestGetSkillsUnsupportedView(c *check.C) {
+	newTestDaemon()
+	req, err := http.NewRequest("GET", "/2.0/skills?view=potato", nil)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	skillsCmd.GET(skillsCmd, req).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+}
+
 // Test for POST /2.0/skills
 
 func (s *apiSuite) TestGrantSkillSuccess(c *check.C) {
@@ -1375,6 +1851,50 @@ func (s *apiSuite) TestGrantSkillFailureTypeMismatch(c *check.C) {
 	c.Check(d.skills.GrantedBy("producer"), check.HasLen, 0)
 }
 
+func (s *apiSuite) TestGrantSkillFailureIncompatible(c *check.C) {
+	d := newTestDaemon()
+	d.skills.AddType(&skills.TestType{
+		TypeName: "type",
+		CheckCompatibilityCallback: func(skill *skills.Skill, slot *skills.Slot) error {
+			return fmt.Errorf("slot requires version 2")
+		},
+	})
+	d.skills.AddSkill(&skills.Skill{Snap: "producer", Name: "skill", Type: "type"})
+	d.skills.AddSlot(&skills.Slot{Snap: "consumer", Name: "slot", Type: "type"})
+	action := &skillAction{
+		Action: "grant",
+		Skill: skills.Skill{
+			Snap: "producer",
+			Name: "skill",
+		},
+		Slot: skills.Slot{
+			Snap: "consumer",
+			Name: "slot",
+		},
+	}
+	text, err := json.Marshal(action)
+	c.Assert(err, check.IsNil)
+	buf := bytes.NewBuffer(text)
+	req, err := http.NewRequest("POST", "/2.0/skills", buf)
+	c.Assert(err, check.IsNil)
+	rec := httptest.NewRecorder()
+	skillsCmd.POST(skillsCmd, req).ServeHTTP(rec, req)
+	c.Check(rec.Code, check.Equals, 400)
+	var body map[string]interface{}
+	err = json.Unmarshal(rec.Body.Bytes(), &body)
+	c.Check(err, check.IsNil)
+	c.Check(body, check.DeepEquals, map[string]interface{}{
+		"result": map[string]interface{}{
+			"message": `cannot grant skill "producer:skill" to "consumer:slot": slot requires version 2`,
+		},
+		"status":      "Bad Request",
+		"status_code": 400.0,
+		"type":        "error",
+	})
+	c.Check(d.skills.GrantedTo("consumer"), check.HasLen, 0)
+	c.Check(d.skills.GrantedBy("producer"), check.HasLen, 0)
+}
+
 func (s *apiSuite) TestGrantSkillFailureNoSuchSkill(c *check.C) {
 	d := newTestDaemon()
 	d.skills.AddType(&skills.TestType{TypeName: "type"})
@@ -2310,3 +2830,219 @@ func (s *apiSuite) TestAssertsInvalidType(c *check.C) {
 	c.Check(rec.Code, check.Equals, 400)
 	c.Check(rec.Body.String(), testutil.Contains, "invalid assert type")
 }
+
+func (s *apiSuite) TestPostGC(c *check.C) {
+	d := newTestDaemon()
+
+	orig := snappyCollectGarbage
+	defer func() { snappyCollectGarbage = orig }()
+
+	ch := make(chan struct{})
+	report := &snappy.GCReport{DryRun: true, Reclaimed: 42}
+	snappyCollectGarbage = func(names []string, flags snappy.GCFlags, meter progress.Meter) (*snappy.GCReport, error) {
+		c.Check(names, check.DeepEquals, []string{"foo.bar"})
+		c.Check(flags, check.Equals, snappy.DoGCDryRun)
+		ch <- struct{}{}
+		return report, nil
+	}
+
+	buf := bytes.NewBufferString(`{"dry-run": true, "snaps": ["foo.bar"]}`)
+	req, err := http.NewRequest("POST", "/2.0/gc", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postGC(gcCmd, req).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeAsync)
+	m := rsp.Result.(map[string]interface{})
+	c.Assert(m["resource"], check.Matches, "/2.0/operations/.*")
+
+	<-ch
+	time.Sleep(time.Millisecond)
+
+	task := d.GetTask(m["resource"].(string)[16:])
+	c.Assert(task, check.NotNil)
+	c.Check(task.State(), check.Equals, TaskSucceeded)
+	c.Check(task.Output(), check.Equals, report)
+}
+
+func (s *apiSuite) TestPostGCBadRequest(c *check.C) {
+	newTestDaemon()
+
+	buf := bytes.NewBufferString(`garbage`)
+	req, err := http.NewRequest("POST", "/2.0/gc", buf)
+	c.Assert(err, check.IsNil)
+
+	rsp := postGC(gcCmd, req).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+}
+
+func (s *apiSuite) TestSysInfoRefresh(c *check.C) {
+	d := newTestDaemon()
+	// the commands keep pointing to the daemon after the test
+	defer func() { d.refresher = newRefresher() }()
+	d.refresher.last = time.Date(2016, 5, 2, 22, 30, 0, 0, time.UTC)
+	d.refresher.next = time.Date(2016, 5, 3, 22, 30, 0, 0, time.UTC)
+
+	rec := httptest.NewRecorder()
+	s.mkrelease()
+
+	sysInfoCmd.GET(sysInfoCmd, nil).ServeHTTP(rec, nil)
+	c.Check(rec.Code, check.Equals, 200)
+
+	expected := map[string]interface{}{
+		"flavor":          "flavor",
+		"release":         "release",
+		"default_channel": "channel",
+		"api_compat":      apiCompatLevel,
+		"last_refresh":    "2016-05-02T22:30:00Z",
+		"next_refresh":    "2016-05-03T22:30:00Z",
+	}
+	var rsp resp
+	c.Assert(json.Unmarshal(rec.Body.Bytes(), &rsp), check.IsNil)
+	c.Check(rsp.Result, check.DeepEquals, expected)
+}
+
+func (s *apiSuite) TestGetSnapshotsNone(c *check.C) {
+	req, err := http.NewRequest("GET", "/2.0/snapshots", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getSnapshots(snapshotsCmd, req).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []*snappy.Snapshot{})
+}
+
+func (s *apiSuite) TestGetSnapshotNotFound(c *check.C) {
+	s.vars = map[string]string{"id": "42"}
+	rsp := getSnapshot(snapshotCmd, nil).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeError)
+	c.Check(rsp.Status, check.Equals, http.StatusNotFound)
+
+	s.vars = map[string]string{"id": "x"}
+	rsp = getSnapshot(snapshotCmd, nil).(*resp)
+	c.Check(rsp.Status, check.Equals, http.StatusBadRequest)
+}
+
+func (s *apiSuite) testPostSnapshots(c *check.C, req *http.Request, ch chan struct{}) *Task {
+	d := newTestDaemon()
+
+	rsp := postSnapshots(snapshotsCmd, req).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeAsync)
+	m := rsp.Result.(map[string]interface{})
+	c.Assert(m["resource"], check.Matches, "/2.0/operations/.*")
+
+	<-ch
+	time.Sleep(time.Millisecond)
+
+	task := d.GetTask(m["resource"].(string)[16:])
+	c.Assert(task, check.NotNil)
+
+	return task
+}
+
+func (s *apiSuite) TestPostSnapshotsSave(c *check.C) {
+	orig := snappySaveSnapshot
+	defer func() { snappySaveSnapshot = orig }()
+
+	ch := make(chan struct{})
+	snapshot := &snappy.Snapshot{ID: 1, Name: "foo", Origin: "bar", Version: "1.0"}
+	snappySaveSnapshot = func(partSpec string) (*snappy.Snapshot, error) {
+		c.Check(partSpec, check.Equals, "foo.bar")
+		ch <- struct{}{}
+		return snapshot, nil
+	}
+
+	buf := bytes.NewBufferString(`{"action": "save", "snap": "foo.bar"}`)
+	req, err := http.NewRequest("POST", "/2.0/snapshots", buf)
+	c.Assert(err, check.IsNil)
+
+	task := s.testPostSnapshots(c, req, ch)
+	c.Check(task.State(), check.Equals, TaskSucceeded)
+	c.Check(task.Output(), check.Equals, snapshot)
+}
+
+func (s *apiSuite) TestPostSnapshotsRestore(c *check.C) {
+	orig := snappyRestoreSnapshot
+	defer func() { snappyRestoreSnapshot = orig }()
+
+	ch := make(chan struct{})
+	snappyRestoreSnapshot = func(id int, version string) error {
+		c.Check(id, check.Equals, 3)
+		c.Check(version, check.Equals, "2.0")
+		ch <- struct{}{}
+		return snappy.ErrSnapshotNotFound
+	}
+
+	buf := bytes.NewBufferString(`{"action": "restore", "id": 3, "version": "2.0"}`)
+	req, err := http.NewRequest("POST", "/2.0/snapshots", buf)
+	c.Assert(err, check.IsNil)
+
+	task := s.testPostSnapshots(c, req, ch)
+	c.Check(task.State(), check.Equals, TaskFailed)
+}
+
+func (s *apiSuite) TestPostSnapshotsImport(c *check.C) {
+	orig := snappyImportSnapshot
+	defer func() { snappyImportSnapshot = orig }()
+
+	ch := make(chan struct{})
+	snapshot := &snappy.Snapshot{ID: 2}
+	snappyImportSnapshot = func(path string) (*snappy.Snapshot, error) {
+		content, err := ioutil.ReadFile(path)
+		c.Check(err, check.IsNil)
+		c.Check(string(content), check.Equals, "exported")
+		ch <- struct{}{}
+		return snapshot, nil
+	}
+
+	req, err := http.NewRequest("POST", "/2.0/snapshots", bytes.NewBufferString("exported"))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/x-tar")
+
+	task := s.testPostSnapshots(c, req, ch)
+	c.Check(task.State(), check.Equals, TaskSucceeded)
+	c.Check(task.Output(), check.Equals, snapshot)
+}
+
+func (s *apiSuite) TestPostSnapshotsBadRequest(c *check.C) {
+	newTestDaemon()
+
+	for _, body := range []string{
+		`garbage`,
+		`{"action": "save"}`,
+		`{"action": "forget"}`,
+		`{"action": "restore", "id": 1, "version": "../../.."}`,
+	} {
+		req, err := http.NewRequest("POST", "/2.0/snapshots", bytes.NewBufferString(body))
+		c.Assert(err, check.IsNil)
+
+		rsp := postSnapshots(snapshotsCmd, req).(*resp)
+		c.Check(rsp.Type, check.Equals, ResponseTypeError, check.Commentf(body))
+		c.Check(rsp.Status, check.Equals, http.StatusBadRequest, check.Commentf(body))
+	}
+}
+
+func (s *apiSuite) TestGetHistory(c *check.C) {
+	req, err := http.NewRequest("GET", "/2.0/history", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := getHistory(historyCmd, req).(*resp)
+	c.Check(rsp.Type, check.Equals, ResponseTypeSync)
+	c.Check(rsp.Result, check.DeepEquals, []snappy.AuditEntry{})
+
+	for _, name := range []string{"foo", "bar"} {
+		err := snappy.Audited(snappy.AuditSourceAPI, 1000, "install", name, func() error { return nil })
+		c.Assert(err, check.IsNil)
+	}
+
+	req, err = http.NewRequest("GET", "/2.0/history?snaps=bar", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp = getHistory(historyCmd, req).(*resp)
+	c.Assert(rsp.Type, check.Equals, ResponseTypeSync)
+	entries := rsp.Result.([]snappy.AuditEntry)
+	c.Assert(entries, check.HasLen, 1)
+	c.Check(entries[0].Snap, check.Equals, "bar")
+	c.Check(entries[0].Action, check.Equals, "install")
+	c.Check(entries[0].UID, check.Equals, 1000)
+	c.Check(entries[0].Source, check.Equals, snappy.AuditSourceAPI)
+}
@@ -48,6 +48,7 @@ type Daemon struct {
 	router       *mux.Router
 	asserts      *asserts.Database
 	skills       *skills.Repository
+	refresher    *refresher
 	// enableInternalSkillActions controls if adding and removing skills and slots is allowed.
 	enableInternalSkillActions bool
 }
@@ -193,6 +194,12 @@ func (d *Daemon) Start() {
 	d.tomb.Go(func() error {
 		return http.Serve(d.listener, logit(d.router))
 	})
+	d.tomb.Go(func() error {
+		return d.refresher.loop(d.tomb.Dying())
+	})
+	d.tomb.Go(func() error {
+		return quotaLoop(d.tomb.Dying())
+	})
 }
 
 // Stop shuts down the Daemon
@@ -208,7 +215,13 @@ func (d *Daemon) Dying() <-chan struct{} {
 
 // AddTask runs the given function as a task
 func (d *Daemon) AddTask(f func() interface{}) *Task {
-	t := RunTask(f)
+	return d.AddTaskWithProgress(f, nil)
+}
+
+// AddTaskWithProgress runs the given function as a task that reports
+// its progress, see RunTaskWithProgress
+func (d *Daemon) AddTaskWithProgress(f func() interface{}, progress func() interface{}) *Task {
+	t := RunTaskWithProgress(f, progress)
 	d.Lock()
 	defer d.Unlock()
 	d.tasks[t.UUID()] = t
@@ -266,9 +279,10 @@ func New() *Daemon {
 		panic(err.Error())
 	}
 	return &Daemon{
-		tasks:   make(map[string]*Task),
-		asserts: db,
-		skills:  skillRepo,
+		tasks:     make(map[string]*Task),
+		asserts:   db,
+		skills:    skillRepo,
+		refresher: newRefresher(),
 		// TODO: Decide when this should be disabled by default.
 		enableInternalSkillActions: true,
 	}
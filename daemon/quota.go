@@ -0,0 +1,75 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"time"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/lockfile"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/snappy"
+)
+
+// quotaCheckInterval is how often the usage of the snap quotas is checked
+var quotaCheckInterval = time.Hour
+
+var snappyQuotaUsages = snappy.QuotaUsages
+
+// checkQuotas warns about the snaps whose data is close to, or over,
+// their quota, and returns their usage
+func checkQuotas() []*snappy.QuotaUsage {
+	lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+	if err != nil {
+		logger.Noticef("Cannot check the snap quotas: %v", err)
+		return nil
+	}
+	defer lock.Unlock()
+
+	usages, err := snappyQuotaUsages()
+	if err != nil {
+		logger.Noticef("Cannot check the snap quotas: %v", err)
+		return nil
+	}
+
+	var warned []*snappy.QuotaUsage
+	for _, usage := range usages {
+		if !usage.Warn() {
+			continue
+		}
+		logger.Noticef("The data of %s %s uses %d%% of its quota (%d of %d bytes)", usage.Snap, usage.Version, usage.Percent(), usage.Usage, usage.Limit)
+		warned = append(warned, usage)
+	}
+
+	return warned
+}
+
+// quotaLoop checks the snap quotas until dying is closed
+func quotaLoop(dying <-chan struct{}) error {
+	for {
+		checkQuotas()
+
+		select {
+		case <-dying:
+			return nil
+		case <-time.After(quotaCheckInterval):
+		}
+	}
+}
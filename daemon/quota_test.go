@@ -0,0 +1,66 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/snappy"
+)
+
+type quotaSuite struct{}
+
+var _ = check.Suite(&quotaSuite{})
+
+func (s *quotaSuite) SetUpTest(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapLockFile), 0755), check.IsNil)
+}
+
+func (s *quotaSuite) TearDownTest(c *check.C) {
+	snappyQuotaUsages = snappy.QuotaUsages
+}
+
+func (s *quotaSuite) TestCheckQuotas(c *check.C) {
+	near := &snappy.QuotaUsage{Snap: "foo.bar", Version: "1", Limit: 100, Usage: 95}
+	over := &snappy.QuotaUsage{Snap: "baz.bar", Version: "2", Limit: 100, Usage: 120}
+	snappyQuotaUsages = func() ([]*snappy.QuotaUsage, error) {
+		return []*snappy.QuotaUsage{
+			near,
+			{Snap: "quux.bar", Version: "1", Limit: 100, Usage: 10},
+			over,
+		}, nil
+	}
+
+	c.Check(checkQuotas(), check.DeepEquals, []*snappy.QuotaUsage{near, over})
+}
+
+func (s *quotaSuite) TestCheckQuotasError(c *check.C) {
+	snappyQuotaUsages = func() ([]*snappy.QuotaUsage, error) {
+		return nil, errors.New("boom")
+	}
+
+	c.Check(checkQuotas(), check.IsNil)
+}
@@ -0,0 +1,247 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ubuntu-core/snappy/coreconfig"
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/lockfile"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/progress"
+	"github.com/ubuntu-core/snappy/snappy"
+)
+
+// refreshRetry is how long a refresh is put off on a metered network, or
+// after it failed
+var refreshRetry = time.Hour
+
+// refreshRecheck is the longest the scheduler sleeps before looking at
+// the refresh configuration again
+var refreshRecheck = 10 * time.Minute
+
+var (
+	getRefreshSchedule = coreconfig.GetRefreshSchedule
+	getAutoUpdate      = coreconfig.AutoUpdate
+	timeNow            = time.Now
+)
+
+// randDuration returns a random duration in [0, max)
+var randDuration = func(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	return time.Duration(rand.Int63n(int64(max)))
+}
+
+// refreshAll updates all the installed snaps
+var refreshAll = func() error {
+	lock, err := lockfile.Lock(dirs.SnapLockFile, true)
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	return snappy.Audited(snappy.AuditSourceAutopilot, 0, "update", "", func() error {
+		_, err := snappy.UpdateAll(snappy.DoInstallGC, &progress.NullProgress{})
+		return err
+	})
+}
+
+// isMetered asks NetworkManager whether the network connection is metered
+var isMetered = func() (bool, error) {
+	out, err := exec.Command("busctl", "get-property", "org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager", "org.freedesktop.NetworkManager", "Metered").Output()
+	if err != nil {
+		return false, err
+	}
+
+	// the output is like "u 1", with values as in NMMetered
+	var metered uint
+	if _, err := fmt.Sscanf(string(out), "u %d", &metered); err != nil {
+		return false, fmt.Errorf("cannot parse metered state %q", out)
+	}
+
+	// NM_METERED_YES or NM_METERED_GUESS_YES
+	return metered == 1 || metered == 3, nil
+}
+
+func lastRefreshPath() string {
+	return filepath.Join(dirs.SnapMetaDir, "last-refresh")
+}
+
+// A refresher refreshes the installed snaps as scheduled by the refresh
+// configuration of ubuntu-core.
+type refresher struct {
+	mu   sync.Mutex
+	last time.Time
+	next time.Time
+	// windowEnd is the end of the window next was picked in, the jitter
+	// is only drawn once per window
+	windowEnd time.Time
+	// deferredUntil is set when a refresh was put off
+	deferredUntil time.Time
+}
+
+func newRefresher() *refresher {
+	r := &refresher{}
+	if content, err := ioutil.ReadFile(lastRefreshPath()); err == nil {
+		r.last, _ = time.Parse(time.RFC3339, strings.TrimSpace(string(content)))
+	}
+
+	return r
+}
+
+// Last returns when the snaps were last refreshed
+func (r *refresher) Last() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.last
+}
+
+// Next returns when the snaps are going to be refreshed next, the zero
+// time if refreshes are not scheduled
+func (r *refresher) Next() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.next
+}
+
+// schedule works out when the next refresh happens and whether it has to
+// be deferred on metered networks. Nothing is scheduled while autoupdate
+// is off.
+func (r *refresher) schedule() (next time.Time, deferOnMetered bool, err error) {
+	schedule, err := getRefreshSchedule()
+	if err == nil && schedule != nil {
+		var enabled bool
+		enabled, err = getAutoUpdate()
+		if !enabled {
+			schedule = nil
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil || schedule == nil {
+		r.next = time.Time{}
+		return r.next, false, err
+	}
+
+	from := timeNow()
+	if r.deferredUntil.After(from) {
+		from = r.deferredUntil
+	}
+	start, end := schedule.Next(r.last, from)
+	if start.IsZero() {
+		r.next = time.Time{}
+		return r.next, false, nil
+	}
+
+	if !end.Equal(r.windowEnd) || r.next.Before(start) {
+		jitter := schedule.Jitter
+		if length := end.Sub(start); jitter > length {
+			jitter = length
+		}
+		r.next = start.Add(randDuration(jitter))
+		r.windowEnd = end
+	}
+
+	return r.next, schedule.DeferOnMetered, nil
+}
+
+// refresh refreshes the installed snaps, unless it has to be deferred
+// because the network is metered. Only successful refreshes are recorded,
+// failed ones are retried later.
+func (r *refresher) refresh(deferOnMetered bool) {
+	if deferOnMetered {
+		metered, err := isMetered()
+		if err != nil {
+			logger.Noticef("Cannot tell if the network is metered: %v", err)
+		}
+		if metered {
+			logger.Noticef("Deferring refresh on a metered network")
+			r.mu.Lock()
+			r.deferredUntil = timeNow().Add(refreshRetry)
+			r.mu.Unlock()
+			return
+		}
+	}
+
+	if err := refreshAll(); err != nil {
+		logger.Noticef("Cannot refresh snaps: %v", err)
+		r.mu.Lock()
+		r.deferredUntil = timeNow().Add(refreshRetry)
+		r.mu.Unlock()
+		return
+	}
+
+	now := timeNow()
+	r.mu.Lock()
+	r.last = now
+	r.deferredUntil = time.Time{}
+	r.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(lastRefreshPath()), 0755); err != nil {
+		logger.Noticef("Cannot record refresh: %v", err)
+		return
+	}
+	if err := helpers.AtomicWriteFile(lastRefreshPath(), []byte(now.Format(time.RFC3339)), 0644, 0); err != nil {
+		logger.Noticef("Cannot record refresh: %v", err)
+	}
+}
+
+// loop refreshes the snaps when they are scheduled to, until dying is
+// closed
+func (r *refresher) loop(dying <-chan struct{}) error {
+	for {
+		next, deferOnMetered, err := r.schedule()
+		if err != nil {
+			logger.Noticef("Cannot schedule refresh: %v", err)
+		}
+
+		wait := refreshRecheck
+		if !next.IsZero() {
+			if d := next.Sub(timeNow()); d < wait {
+				wait = d
+			}
+		}
+
+		select {
+		case <-dying:
+			return nil
+		case <-time.After(wait):
+		}
+
+		if !next.IsZero() && !timeNow().Before(next) {
+			r.refresh(deferOnMetered)
+		}
+	}
+}
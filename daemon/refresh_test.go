@@ -0,0 +1,199 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"errors"
+	"io/ioutil"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/coreconfig"
+	"github.com/ubuntu-core/snappy/dirs"
+)
+
+type refreshSuite struct {
+	now        time.Time
+	schedule   *coreconfig.RefreshSchedule
+	refreshes  int
+	metered    bool
+	autoUpdate bool
+}
+
+var _ = check.Suite(&refreshSuite{})
+
+func (s *refreshSuite) SetUpTest(c *check.C) {
+	dirs.SetRootDir(c.MkDir())
+
+	s.now = time.Date(2016, 5, 2, 12, 0, 0, 0, time.Local)
+	s.schedule = &coreconfig.RefreshSchedule{
+		Start:  22 * time.Hour,
+		End:    24 * time.Hour,
+		Jitter: time.Hour,
+	}
+	s.refreshes = 0
+	s.metered = false
+	s.autoUpdate = true
+
+	timeNow = func() time.Time { return s.now }
+	getRefreshSchedule = func() (*coreconfig.RefreshSchedule, error) { return s.schedule, nil }
+	getAutoUpdate = func() (bool, error) { return s.autoUpdate, nil }
+	randDuration = func(max time.Duration) time.Duration { return max / 2 }
+	refreshAll = func() error {
+		s.refreshes++
+		return nil
+	}
+	isMetered = func() (bool, error) { return s.metered, nil }
+}
+
+func (s *refreshSuite) TearDownTest(c *check.C) {
+	timeNow = time.Now
+	getRefreshSchedule = coreconfig.GetRefreshSchedule
+	getAutoUpdate = coreconfig.AutoUpdate
+	randDuration = origRandDuration
+	refreshAll = origRefreshAll
+	isMetered = origIsMetered
+}
+
+var (
+	origRandDuration = randDuration
+	origRefreshAll   = refreshAll
+	origIsMetered    = isMetered
+)
+
+func (s *refreshSuite) TestScheduleNotConfigured(c *check.C) {
+	s.schedule = nil
+
+	r := newRefresher()
+	next, _, err := r.schedule()
+	c.Assert(err, check.IsNil)
+	c.Check(next.IsZero(), check.Equals, true)
+	c.Check(r.Next().IsZero(), check.Equals, true)
+}
+
+func (s *refreshSuite) TestScheduleAutoUpdateOff(c *check.C) {
+	s.autoUpdate = false
+
+	r := newRefresher()
+	next, _, err := r.schedule()
+	c.Assert(err, check.IsNil)
+	c.Check(next.IsZero(), check.Equals, true)
+	c.Check(r.Next().IsZero(), check.Equals, true)
+}
+
+func (s *refreshSuite) TestScheduleJitter(c *check.C) {
+	r := newRefresher()
+	next, deferOnMetered, err := r.schedule()
+	c.Assert(err, check.IsNil)
+	c.Check(deferOnMetered, check.Equals, false)
+	c.Check(next, check.Equals, time.Date(2016, 5, 2, 22, 30, 0, 0, time.Local))
+
+	// the jitter is only drawn once per window
+	randDuration = func(max time.Duration) time.Duration { return 0 }
+	s.now = s.now.Add(time.Hour)
+	next, _, err = r.schedule()
+	c.Assert(err, check.IsNil)
+	c.Check(next, check.Equals, time.Date(2016, 5, 2, 22, 30, 0, 0, time.Local))
+	c.Check(r.Next(), check.Equals, next)
+}
+
+func (s *refreshSuite) TestRefresh(c *check.C) {
+	r := newRefresher()
+	s.now = time.Date(2016, 5, 2, 22, 30, 0, 0, time.Local)
+	r.refresh(false)
+	c.Check(s.refreshes, check.Equals, 1)
+	c.Check(r.Last(), check.Equals, s.now)
+
+	// the refresh is recorded
+	content, err := ioutil.ReadFile(lastRefreshPath())
+	c.Assert(err, check.IsNil)
+	c.Check(string(content), check.Equals, s.now.Format(time.RFC3339))
+	c.Check(newRefresher().Last().Equal(s.now), check.Equals, true)
+
+	// and the next one is in the next window
+	next, _, err := r.schedule()
+	c.Assert(err, check.IsNil)
+	c.Check(next, check.Equals, time.Date(2016, 5, 3, 22, 30, 0, 0, time.Local))
+}
+
+func (s *refreshSuite) TestRefreshFailed(c *check.C) {
+	refreshAll = func() error {
+		s.refreshes++
+		return errors.New("no store")
+	}
+	s.now = time.Date(2016, 5, 2, 22, 30, 0, 0, time.Local)
+
+	r := newRefresher()
+	r.refresh(false)
+	c.Check(s.refreshes, check.Equals, 1)
+
+	// the failed refresh is not recorded
+	c.Check(r.Last().IsZero(), check.Equals, true)
+	_, err := ioutil.ReadFile(lastRefreshPath())
+	c.Check(err, check.NotNil)
+
+	// and it is retried later on in the same window
+	next, _, err := r.schedule()
+	c.Assert(err, check.IsNil)
+	c.Check(next.Before(s.now.Add(refreshRetry)), check.Equals, false)
+	c.Check(next.Before(time.Date(2016, 5, 3, 0, 0, 0, 0, time.Local)), check.Equals, true)
+}
+
+func (s *refreshSuite) TestRefreshDeferredOnMetered(c *check.C) {
+	s.metered = true
+	s.schedule.DeferOnMetered = true
+	s.now = time.Date(2016, 5, 2, 22, 30, 0, 0, time.Local)
+
+	r := newRefresher()
+	r.refresh(true)
+	c.Check(s.refreshes, check.Equals, 0)
+	c.Check(r.Last().IsZero(), check.Equals, true)
+
+	next, deferOnMetered, err := r.schedule()
+	c.Assert(err, check.IsNil)
+	c.Check(deferOnMetered, check.Equals, true)
+	c.Check(next.Before(s.now.Add(refreshRetry)), check.Equals, false)
+
+	// metered networks are fine if the schedule allows them
+	r.refresh(false)
+	c.Check(s.refreshes, check.Equals, 1)
+}
+
+func (s *refreshSuite) TestLoop(c *check.C) {
+	s.now = time.Date(2016, 5, 2, 23, 30, 0, 0, time.Local)
+	randDuration = func(max time.Duration) time.Duration { return 0 }
+
+	dying := make(chan struct{})
+	done := make(chan error)
+	r := newRefresher()
+	go func() {
+		done <- r.loop(dying)
+	}()
+
+	for i := 0; i < 100 && r.Last().IsZero(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(dying)
+	c.Check(<-done, check.IsNil)
+
+	c.Check(s.refreshes, check.Equals, 1)
+	c.Check(r.Last(), check.Equals, s.now)
+}
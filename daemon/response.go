@@ -96,7 +96,11 @@ func (r *resp) Self(*Command, *http.Request) Response {
 type errorKind string
 
 const (
-	errorKindLicenseRequired = errorKind("license-required")
+	errorKindLicenseRequired   = errorKind("license-required")
+	errorKindHealthCheckFailed = errorKind("health-check-failed")
+	errorKindInsufficientSpace = errorKind("insufficient-space")
+	errorKindInstallManyFailed = errorKind("install-many-failed")
+	errorKindSnapHeld          = errorKind("snap-held")
 )
 
 type errorValue interface{}
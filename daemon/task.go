@@ -23,6 +23,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/ubuntu-core/snappy/snappy"
 	"gopkg.in/tomb.v2"
 )
 
@@ -33,6 +34,8 @@ type Task struct {
 	t0     time.Time
 	tf     time.Time
 	output interface{}
+	// progress returns the output while the task is running, if set
+	progress func() interface{}
 }
 
 // A task can be in one of three states
@@ -52,11 +55,13 @@ func (t *Task) UpdatedAt() time.Time {
 	return t.tf
 }
 
-// Output of this task. If the task is still running this will be nil.
-//
-// TODO: output can and should go changing as the task progresses
+// Output of this task. If the task is still running this will be nil,
+// unless the task reports its progress.
 func (t *Task) Output() interface{} {
 	if t.tomb.Alive() {
+		if t.progress != nil {
+			return t.progress()
+		}
 		return nil
 	}
 
@@ -115,12 +120,20 @@ func (t *Task) Map(route *mux.Route) map[string]interface{} {
 
 // RunTask creates a Task for the given function and runs it.
 func RunTask(f func() interface{}) *Task {
+	return RunTaskWithProgress(f, nil)
+}
+
+// RunTaskWithProgress creates a Task for the given function and runs it.
+// While the function runs, the output of the task is what progress
+// returns.
+func RunTaskWithProgress(f func() interface{}, progress func() interface{}) *Task {
 	id := UUID4()
 	t0 := time.Now()
 	t := &Task{
-		id: id,
-		t0: t0,
-		tf: t0,
+		id:       id,
+		t0:       t0,
+		tf:       t0,
+		progress: progress,
 	}
 
 	t.tomb.Go(func() error {
@@ -140,9 +153,7 @@ func RunTask(f func() interface{}) *Task {
 
 			return error(out)
 		case error:
-			t.output = errorResult{
-				Message: out.Error(),
-			}
+			t.output = errorResultFor(out)
 
 			return out
 		}
@@ -152,3 +163,33 @@ func RunTask(f func() interface{}) *Task {
 
 	return t
 }
+
+// errorResultFor returns the errorResult for a task that failed with the
+// given error, with a kind for the errors clients can act upon
+func errorResultFor(err error) errorResult {
+	res := errorResult{
+		Message: err.Error(),
+	}
+
+	orig := err
+	if e, ok := err.(*snappy.ErrInstallFailed); ok {
+		orig = e.OrigErr
+	}
+
+	switch e := orig.(type) {
+	case *snappy.ErrHealthCheckFailed:
+		res.Kind = errorKindHealthCheckFailed
+		res.Value = e
+	case *snappy.ErrInsufficientSpace:
+		res.Kind = errorKindInsufficientSpace
+		res.Value = e
+	case *snappy.ErrInstallManyFailed:
+		res.Kind = errorKindInstallManyFailed
+		res.Value = e
+	case *snappy.ErrSnapHeld:
+		res.Kind = errorKindSnapHeld
+		res.Value = e.Hold
+	}
+
+	return res
+}
@@ -25,6 +25,8 @@ import (
 
 	"github.com/gorilla/mux"
 	"gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/snappy"
 )
 
 type taskSuite struct{}
@@ -56,6 +58,26 @@ func (s *taskSuite) TestTask(c *check.C) {
 	c.Check(t.Output(), check.Equals, 42)
 }
 
+func (s *taskSuite) TestTaskProgress(c *check.C) {
+	ch := make(chan struct{})
+
+	t := RunTaskWithProgress(func() interface{} {
+		<-ch
+		return 42
+	}, func() interface{} {
+		return "halfway"
+	})
+
+	c.Check(t.State(), check.Equals, TaskRunning)
+	c.Check(t.Output(), check.Equals, "halfway")
+
+	close(ch)
+	t.tomb.Wait()
+
+	c.Check(t.State(), check.Equals, TaskSucceeded)
+	c.Check(t.Output(), check.Equals, 42)
+}
+
 func (s *taskSuite) TestFails(c *check.C) {
 	router := mux.NewRouter()
 	route := router.Handle("/xyzzy/{uuid}", nil)
@@ -83,3 +105,82 @@ func (s *taskSuite) TestFails(c *check.C) {
 		Message: err.Error(),
 	})
 }
+
+func (s *taskSuite) TestFailedHealthCheckTask(c *check.C) {
+	herr := &snappy.ErrHealthCheckFailed{
+		Snap:         "foo.bar",
+		Version:      "2.0",
+		Reason:       "service foo_svc_2.0.service is failed (failed)",
+		RolledBackTo: "1.0",
+	}
+	err := &snappy.ErrInstallFailed{Snap: "foo.bar", OrigErr: herr}
+
+	t := RunTask(func() interface{} {
+		return err
+	})
+	t.tomb.Wait()
+
+	c.Check(t.State(), check.Equals, TaskFailed)
+	c.Check(t.Output(), check.DeepEquals, errorResult{
+		Message: err.Error(),
+		Kind:    errorKindHealthCheckFailed,
+		Value:   herr,
+	})
+}
+
+func (s *taskSuite) TestInsufficientSpaceTask(c *check.C) {
+	serr := &snappy.ErrInsufficientSpace{
+		Snap:   "foo",
+		Path:   "/var/lib/snappy/snaps",
+		Needed: 2048,
+		Free:   1024,
+	}
+
+	t := RunTask(func() interface{} {
+		return serr
+	})
+	t.tomb.Wait()
+
+	c.Check(t.State(), check.Equals, TaskFailed)
+	c.Check(t.Output(), check.DeepEquals, errorResult{
+		Message: serr.Error(),
+		Kind:    errorKindInsufficientSpace,
+		Value:   serr,
+	})
+}
+
+func (s *taskSuite) TestInstallManyFailedTask(c *check.C) {
+	ierr := &snappy.ErrInstallManyFailed{
+		Results: []snappy.InstallManyResult{
+			{Name: "foo", Status: snappy.InstallManyFailed, Error: "boom"},
+		},
+	}
+
+	t := RunTask(func() interface{} {
+		return ierr
+	})
+	t.tomb.Wait()
+
+	c.Check(t.State(), check.Equals, TaskFailed)
+	c.Check(t.Output(), check.DeepEquals, errorResult{
+		Message: ierr.Error(),
+		Kind:    errorKindInstallManyFailed,
+		Value:   ierr,
+	})
+}
+
+func (s *taskSuite) TestSnapHeldTask(c *check.C) {
+	herr := &snappy.ErrSnapHeld{Hold: &snappy.Hold{Snap: "foo.bar"}}
+
+	t := RunTask(func() interface{} {
+		return herr
+	})
+	t.tomb.Wait()
+
+	c.Check(t.State(), check.Equals, TaskFailed)
+	c.Check(t.Output(), check.DeepEquals, errorResult{
+		Message: herr.Error(),
+		Kind:    errorKindSnapHeld,
+		Value:   herr.Hold,
+	})
+}
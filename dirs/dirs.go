@@ -30,6 +30,7 @@ var (
 
 	SnapSnapsDir              string
 	SnapBlobDir               string
+	SnapDownloadCacheDir      string
 	SnapDataDir               string
 	SnapDataHomeGlob          string
 	SnapAppArmorDir           string
@@ -39,6 +40,9 @@ var (
 	LocaleDir                 string
 	SnapMetaDir               string
 	SnapLockFile              string
+	SnapGCPolicyFile          string
+	SnapSnapshotsDir          string
+	SnapAuditLogFile          string
 	SnapdSocket               string
 
 	SnapAssertsDBDir      string
@@ -81,7 +85,11 @@ func SetRootDir(rootdir string) {
 	SnapSeccompDir = filepath.Join(rootdir, snappyDir, "seccomp", "profiles")
 	SnapMetaDir = filepath.Join(rootdir, snappyDir, "meta")
 	SnapLockFile = filepath.Join(rootdir, "/run/snappy.lock")
+	SnapGCPolicyFile = filepath.Join(rootdir, "/etc/snappy/gc.yaml")
 	SnapBlobDir = filepath.Join(rootdir, snappyDir, "snaps")
+	SnapDownloadCacheDir = filepath.Join(rootdir, snappyDir, "downloads")
+	SnapSnapshotsDir = filepath.Join(rootdir, snappyDir, "snapshots")
+	SnapAuditLogFile = filepath.Join(rootdir, "/var/log/snappy/audit.log")
 	// keep in sync with the debian/ubuntu-snappy.snapd.socket file:
 	SnapdSocket = filepath.Join(rootdir, "/run/snapd.socket")
 
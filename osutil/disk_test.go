@@ -0,0 +1,39 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package osutil
+
+import (
+	. "gopkg.in/check.v1"
+)
+
+type diskTestSuite struct{}
+
+var _ = Suite(&diskTestSuite{})
+
+func (s *diskTestSuite) TestFreeSpace(c *C) {
+	free, err := FreeSpace(c.MkDir())
+	c.Assert(err, IsNil)
+	c.Check(free > 0, Equals, true)
+}
+
+func (s *diskTestSuite) TestFreeSpaceNoPath(c *C) {
+	_, err := FreeSpace("/no/such/path")
+	c.Check(err, NotNil)
+}
@@ -0,0 +1,157 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package skills
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AttrKind is the kind of value an attribute can hold.
+type AttrKind string
+
+const (
+	// AttrString identifies string attributes.
+	AttrString AttrKind = "string"
+	// AttrBool identifies boolean attributes.
+	AttrBool AttrKind = "bool"
+	// AttrInt identifies integer attributes.
+	AttrInt AttrKind = "int"
+)
+
+// AttrSpec describes a single attribute of a skill or a slot.
+type AttrSpec struct {
+	// Kind is the kind of value the attribute holds.
+	Kind AttrKind
+	// Required attributes must always be present.
+	Required bool
+	// Pattern, if set, must match the value of string attributes.
+	Pattern *regexp.Regexp
+	// Enum, if set, lists all the allowed values of string attributes.
+	Enum []string
+	// Default is the value used when an optional attribute is absent.
+	Default interface{}
+}
+
+// AttrSchema describes all the attributes of skills or slots of a given type.
+// Attributes that are not described by the schema are not allowed.
+type AttrSchema map[string]*AttrSpec
+
+// AttrSchemaType is implemented by types that declare the attributes their
+// skills and slots may have. The repository validates attributes against
+// the schema before the type-specific sanitization takes place.
+//
+// A nil schema disables validation of the respective attributes.
+type AttrSchemaType interface {
+	// SkillAttrSchema returns the schema of the attributes of skills.
+	SkillAttrSchema() AttrSchema
+
+	// SlotAttrSchema returns the schema of the attributes of slots.
+	SlotAttrSchema() AttrSchema
+}
+
+// Validate checks that attributes conform to the schema.
+//
+// Defaults of absent optional attributes are applied and numeric values of
+// integer attributes are normalized to int. The possibly updated attribute
+// map is returned.
+func (schema AttrSchema) Validate(attrs map[string]interface{}) (map[string]interface{}, error) {
+	var names []string
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := schema[name]; !ok {
+			return nil, fmt.Errorf("unknown attribute %q", name)
+		}
+	}
+	names = names[:0]
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec := schema[name]
+		value, ok := attrs[name]
+		if !ok {
+			if spec.Required {
+				return nil, fmt.Errorf("attribute %q is required", name)
+			}
+			if spec.Default == nil {
+				continue
+			}
+			value = spec.Default
+		}
+		value, err := spec.check(name, value)
+		if err != nil {
+			return nil, err
+		}
+		if attrs == nil {
+			attrs = make(map[string]interface{})
+		}
+		attrs[name] = value
+	}
+	return attrs, nil
+}
+
+func (spec *AttrSpec) check(name string, value interface{}) (interface{}, error) {
+	switch spec.Kind {
+	case AttrString:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("attribute %q must be a string", name)
+		}
+		if spec.Pattern != nil && !spec.Pattern.MatchString(s) {
+			return nil, fmt.Errorf("attribute %q has invalid value %q", name, s)
+		}
+		if len(spec.Enum) > 0 {
+			for _, allowed := range spec.Enum {
+				if s == allowed {
+					return s, nil
+				}
+			}
+			return nil, fmt.Errorf("attribute %q must be one of %s, not %q", name, strings.Join(spec.Enum, ", "), s)
+		}
+		return s, nil
+	case AttrBool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("attribute %q must be a boolean", name)
+	case AttrInt:
+		switch n := value.(type) {
+		case int:
+			return n, nil
+		case int64:
+			return int(n), nil
+		case float64:
+			// Numbers decoded from JSON are always float64.
+			if n == math.Trunc(n) {
+				return int(n), nil
+			}
+		}
+		return nil, fmt.Errorf("attribute %q must be an integer", name)
+	}
+	panic(fmt.Sprintf("unknown kind of attribute %q: %q", name, spec.Kind))
+}
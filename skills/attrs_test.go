@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package skills_test
+
+import (
+	"regexp"
+
+	. "gopkg.in/check.v1"
+
+	. "github.com/ubuntu-core/snappy/skills"
+)
+
+type AttrSchemaSuite struct {
+	schema AttrSchema
+}
+
+var _ = Suite(&AttrSchemaSuite{
+	schema: AttrSchema{
+		"path":    {Kind: AttrString, Required: true, Pattern: regexp.MustCompile("^/")},
+		"mode":    {Kind: AttrString, Enum: []string{"in", "out"}, Default: "in"},
+		"enabled": {Kind: AttrBool},
+		"version": {Kind: AttrInt, Default: 1},
+	},
+})
+
+func (s *AttrSchemaSuite) TestValidateAppliesDefaults(c *C) {
+	attrs, err := s.schema.Validate(map[string]interface{}{"path": "/foo"})
+	c.Assert(err, IsNil)
+	c.Assert(attrs, DeepEquals, map[string]interface{}{
+		"path":    "/foo",
+		"mode":    "in",
+		"version": 1,
+	})
+}
+
+func (s *AttrSchemaSuite) TestValidateKeepsValues(c *C) {
+	attrs, err := s.schema.Validate(map[string]interface{}{
+		"path":    "/foo",
+		"mode":    "out",
+		"enabled": true,
+		"version": 2,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(attrs, DeepEquals, map[string]interface{}{
+		"path":    "/foo",
+		"mode":    "out",
+		"enabled": true,
+		"version": 2,
+	})
+}
+
+func (s *AttrSchemaSuite) TestValidateNormalizesIntegers(c *C) {
+	// Numbers decoded from JSON are float64
+	attrs, err := s.schema.Validate(map[string]interface{}{"path": "/foo", "version": 3.0})
+	c.Assert(err, IsNil)
+	c.Assert(attrs["version"], Equals, 3)
+}
+
+func (s *AttrSchemaSuite) TestValidateNilAttrs(c *C) {
+	schema := AttrSchema{"mode": {Kind: AttrString, Default: "in"}}
+	attrs, err := schema.Validate(nil)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, DeepEquals, map[string]interface{}{"mode": "in"})
+	attrs, err = AttrSchema{}.Validate(nil)
+	c.Assert(err, IsNil)
+	c.Assert(attrs, IsNil)
+}
+
+func (s *AttrSchemaSuite) TestValidateErrors(c *C) {
+	for _, t := range []struct {
+		attrs map[string]interface{}
+		err   string
+	}{
+		{map[string]interface{}{}, `attribute "path" is required`},
+		{map[string]interface{}{"path": 1}, `attribute "path" must be a string`},
+		{map[string]interface{}{"path": "foo"}, `attribute "path" has invalid value "foo"`},
+		{map[string]interface{}{"path": "/", "mode": "both"}, `attribute "mode" must be one of in, out, not "both"`},
+		{map[string]interface{}{"path": "/", "enabled": "yes"}, `attribute "enabled" must be a boolean`},
+		{map[string]interface{}{"path": "/", "version": 1.5}, `attribute "version" must be an integer`},
+		{map[string]interface{}{"path": "/", "version": "1"}, `attribute "version" must be an integer`},
+		{map[string]interface{}{"path": "/", "other": "1"}, `unknown attribute "other"`},
+	} {
+		_, err := s.schema.Validate(t.attrs)
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
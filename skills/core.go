@@ -77,6 +77,17 @@ type Type interface {
 	SlotSecuritySnippet(skill *Skill, securitySystem SecuritySystem) ([]byte, error)
 }
 
+// CompatibilityChecker is implemented by types that need to negotiate between
+// the attributes of a skill and a slot before the skill can be granted.
+//
+// The repository already ensures that the skill and the slot have the same
+// type, this is only consulted for additional, type-specific, constraints.
+type CompatibilityChecker interface {
+	// CheckCompatibility returns an error describing why the skill cannot
+	// be granted to the slot or nil if they are compatible.
+	CheckCompatibility(skill *Skill, slot *Slot) error
+}
+
 // SecuritySystem is a name of a security system.
 type SecuritySystem string
 
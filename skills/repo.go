@@ -140,6 +140,14 @@ func (r *Repository) AddSkill(skill *Skill) error {
 	if t == nil {
 		return fmt.Errorf("cannot add skill, skill type %q is not known", skill.Type)
 	}
+	// Reject skill with attributes that don't conform to the type schema
+	if st, ok := t.(AttrSchemaType); ok && st.SkillAttrSchema() != nil {
+		attrs, err := st.SkillAttrSchema().Validate(skill.Attrs)
+		if err != nil {
+			return fmt.Errorf("cannot add skill: %v", err)
+		}
+		skill.Attrs = attrs
+	}
 	// Reject skill that don't pass type-specific sanitization
 	if err := t.SanitizeSkill(skill); err != nil {
 		return fmt.Errorf("cannot add skill: %v", err)
@@ -235,6 +243,13 @@ func (r *Repository) AddSlot(slot *Slot) error {
 	if t == nil {
 		return fmt.Errorf("cannot add skill slot, skill type %q is not known", slot.Type)
 	}
+	if st, ok := t.(AttrSchemaType); ok && st.SlotAttrSchema() != nil {
+		attrs, err := st.SlotAttrSchema().Validate(slot.Attrs)
+		if err != nil {
+			return fmt.Errorf("cannot add slot: %v", err)
+		}
+		slot.Attrs = attrs
+	}
 	if err := t.SanitizeSlot(slot); err != nil {
 		return fmt.Errorf("cannot add slot: %v", err)
 	}
@@ -292,6 +307,13 @@ func (r *Repository) Grant(skillSnapName, skillName, slotSnapName, slotName stri
 		return fmt.Errorf(`cannot grant skill "%s:%s" (skill type %q) to "%s:%s" (skill type %q)`,
 			skillSnapName, skillName, skill.Type, slotSnapName, slotName, slot.Type)
 	}
+	// Ensure that the type doesn't have any objections
+	if checker, ok := r.types[skill.Type].(CompatibilityChecker); ok {
+		if err := checker.CheckCompatibility(skill, slot); err != nil {
+			return fmt.Errorf(`cannot grant skill "%s:%s" to "%s:%s": %v`,
+				skillSnapName, skillName, slotSnapName, slotName, err)
+		}
+	}
 	// Ensure that slot and skill are not connected yet
 	if r.slotSkills[slot][skill] {
 		// But if they are don't treat this as an error.
@@ -491,9 +513,20 @@ func (r *Repository) SecuritySnippetsForSnap(snapName string, securitySystem Sec
 func (r *Repository) securitySnippetsForSnap(snapName string, securitySystem SecuritySystem) (map[string][][]byte, error) {
 	var snippets = make(map[string][][]byte)
 	// Find all of the skills that affect this app because of skill consumption.
+	// Only the apps listed in a slot are affected by skills granted to it.
+	var slots []*Slot
 	for _, slot := range r.slots[snapName] {
+		slots = append(slots, slot)
+	}
+	sort.Sort(bySlotSnapAndName(slots))
+	for _, slot := range slots {
 		t := r.types[slot.Type]
+		var skills []*Skill
 		for skill := range r.slotSkills[slot] {
+			skills = append(skills, skill)
+		}
+		sort.Sort(bySkillSnapAndName(skills))
+		for _, skill := range skills {
 			snippet, err := t.SlotSecuritySnippet(skill, securitySystem)
 			if err != nil {
 				return nil, err
@@ -507,7 +540,13 @@ func (r *Repository) securitySnippetsForSnap(snapName string, securitySystem Sec
 		}
 	}
 	// Find all of the skills that affect this app because of skill offer.
+	// Only the apps listed in a skill are affected by offering it.
+	var skills []*Skill
 	for _, skill := range r.skills[snapName] {
+		skills = append(skills, skill)
+	}
+	sort.Sort(bySkillSnapAndName(skills))
+	for _, skill := range skills {
 		t := r.types[skill.Type]
 		snippet, err := t.SkillSecuritySnippet(skill, securitySystem)
 		if err != nil {
@@ -184,6 +184,36 @@ func (s *RepositorySuite) TestAddSkillFailsWithUnsanitizedSkill(c *C) {
 	c.Assert(s.emptyRepo.AllSkills(""), HasLen, 0)
 }
 
+func (s *RepositorySuite) TestAddSkillValidatesAttrs(c *C) {
+	t := &TestType{
+		TypeName:   "type",
+		SkillAttrs: AttrSchema{"attr": {Kind: AttrBool}},
+	}
+	err := s.emptyRepo.AddType(t)
+	c.Assert(err, IsNil)
+	err = s.emptyRepo.AddSkill(s.skill)
+	c.Assert(err, ErrorMatches, `cannot add skill: attribute "attr" must be a boolean`)
+	c.Assert(s.emptyRepo.AllSkills(""), HasLen, 0)
+}
+
+func (s *RepositorySuite) TestAddSkillAppliesAttrDefaults(c *C) {
+	t := &TestType{
+		TypeName: "type",
+		SkillAttrs: AttrSchema{
+			"attr":  {Kind: AttrString},
+			"other": {Kind: AttrString, Default: "default"},
+		},
+	}
+	err := s.emptyRepo.AddType(t)
+	c.Assert(err, IsNil)
+	skill := &Skill{Snap: "provider", Name: "skill", Type: "type"}
+	err = s.emptyRepo.AddSkill(skill)
+	c.Assert(err, IsNil)
+	c.Assert(s.emptyRepo.Skill("provider", "skill").Attrs, DeepEquals, map[string]interface{}{
+		"other": "default",
+	})
+}
+
 // Tests for Repository.Skill()
 
 func (s *RepositorySuite) TestSkill(c *C) {
@@ -484,6 +514,18 @@ func (s *RepositorySuite) TestAddSlotFailsWithInvalidSnapName(c *C) {
 	c.Assert(s.testRepo.AllSlots(""), HasLen, 0)
 }
 
+func (s *RepositorySuite) TestAddSlotValidatesAttrs(c *C) {
+	t := &TestType{
+		TypeName:  "type",
+		SlotAttrs: AttrSchema{"attr": {Kind: AttrString, Enum: []string{"foo"}}},
+	}
+	err := s.emptyRepo.AddType(t)
+	c.Assert(err, IsNil)
+	err = s.emptyRepo.AddSlot(s.slot)
+	c.Assert(err, ErrorMatches, `cannot add slot: attribute "attr" must be one of foo, not "value"`)
+	c.Assert(s.emptyRepo.AllSlots(""), HasLen, 0)
+}
+
 func (s *RepositorySuite) TestAddSlotFailsForDuplicates(c *C) {
 	// Adding the first slot succeeds
 	err := s.testRepo.AddSlot(s.slot)
@@ -597,6 +639,30 @@ func (s *RepositorySuite) TestGrantFailsWhenSlotAndSkillAreIncompatible(c *C) {
 	c.Assert(err, ErrorMatches, `cannot grant skill "provider:skill" \(skill type "other-type"\) to "consumer:slot" \(skill type "type"\)`)
 }
 
+func (s *RepositorySuite) TestGrantFailsWhenTypeRejectsAttributes(c *C) {
+	t := &TestType{
+		TypeName: "type",
+		CheckCompatibilityCallback: func(skill *Skill, slot *Slot) error {
+			if slot.Attrs["version"] != skill.Attrs["version"] {
+				return fmt.Errorf("slot requires version %v", slot.Attrs["version"])
+			}
+			return nil
+		},
+	}
+	err := s.emptyRepo.AddType(t)
+	c.Assert(err, IsNil)
+	err = s.emptyRepo.AddSkill(&Skill{Snap: "provider", Name: "skill", Type: "type",
+		Attrs: map[string]interface{}{"version": 1}})
+	c.Assert(err, IsNil)
+	err = s.emptyRepo.AddSlot(&Slot{Snap: "consumer", Name: "slot", Type: "type",
+		Attrs: map[string]interface{}{"version": 2}})
+	c.Assert(err, IsNil)
+	// Granting a skill to a slot rejected by the type fails with the reason given by the type
+	err = s.emptyRepo.Grant("provider", "skill", "consumer", "slot")
+	c.Assert(err, ErrorMatches, `cannot grant skill "provider:skill" to "consumer:slot": slot requires version 2`)
+	c.Assert(s.emptyRepo.GrantsOf("provider", "skill"), HasLen, 0)
+}
+
 func (s *RepositorySuite) TestGrantSucceeds(c *C) {
 	err := s.testRepo.AddSkill(s.skill)
 	c.Assert(err, IsNil)
@@ -97,7 +97,7 @@ func (sc *secComp) footerForApp(snapName, appName string) []byte {
 	return nil // seccomp doesn't require a footer
 }
 
-// uDev is a security subsystem that writes additional udev rules (one per snap).
+// uDev is a security subsystem that writes additional udev rules (one file per app).
 //
 // Each rule looks like this:
 //
@@ -112,8 +112,7 @@ func (udev *uDev) securitySystem() SecuritySystem {
 }
 
 func (udev *uDev) pathForApp(snapName, appName string) string {
-	// NOTE: we ignore appName so effectively udev rules apply to entire snap.
-	return fmt.Sprintf("/etc/udev/rules.d/70-snappy-%s.rules", snapName)
+	return fmt.Sprintf("/etc/udev/rules.d/70-snappy-%s-%s.rules", snapName, appName)
 }
 
 func (udev *uDev) headerForApp(snapName, appName string) []byte {
@@ -141,7 +140,7 @@ func (dbus *dBus) pathForApp(snapName, appName string) string {
 	// XXX: Is the name of this file relevant or can everything be contained
 	// in particular snippets?
 	// XXX: At this level we don't know the bus name.
-	return fmt.Sprintf("/etc/dbus-1/system.d/%s.conf", snapName)
+	return fmt.Sprintf("/etc/dbus-1/system.d/%s.%s.conf", snapName, appName)
 }
 
 func (dbus *dBus) headerForApp(snapName, appName string) []byte {
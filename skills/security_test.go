@@ -163,7 +163,7 @@ func (s *SecuritySuite) TestUdevSkillPermissions(c *C) {
 	blobs, err := s.repo.SecurityFilesForSnap(s.skill.Snap)
 	c.Assert(err, IsNil)
 	c.Check(blobs, DeepEquals, map[string][]byte{
-		"/etc/udev/rules.d/70-snappy-producer.rules": []byte("...\n"),
+		"/etc/udev/rules.d/70-snappy-producer-hook.rules": []byte("...\n"),
 	})
 }
 
@@ -181,7 +181,7 @@ func (s *SecuritySuite) TestUdevSlotPermissions(c *C) {
 	blobs, err := s.repo.SecurityFilesForSnap(s.slot.Snap)
 	c.Assert(err, IsNil)
 	c.Check(blobs, DeepEquals, map[string][]byte{
-		"/etc/udev/rules.d/70-snappy-consumer.rules": []byte("...\n"),
+		"/etc/udev/rules.d/70-snappy-consumer-app.rules": []byte("...\n"),
 	})
 }
 
@@ -201,7 +201,7 @@ func (s *SecuritySuite) TestDBusSkillPermissions(c *C) {
 	blobs, err := s.repo.SecurityFilesForSnap(s.skill.Snap)
 	c.Assert(err, IsNil)
 	c.Check(blobs, DeepEquals, map[string][]byte{
-		"/etc/dbus-1/system.d/producer.conf": []byte("" +
+		"/etc/dbus-1/system.d/producer.hook.conf": []byte("" +
 			"<!DOCTYPE busconfig PUBLIC\n" +
 			" \"-//freedesktop//DTD D-BUS Bus Configuration 1.0//EN\"\n" +
 			" \"http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd\">\n" +
@@ -225,7 +225,7 @@ func (s *SecuritySuite) TestDBusSlotPermissions(c *C) {
 	blobs, err := s.repo.SecurityFilesForSnap(s.slot.Snap)
 	c.Assert(err, IsNil)
 	c.Check(blobs, DeepEquals, map[string][]byte{
-		"/etc/dbus-1/system.d/consumer.conf": []byte("" +
+		"/etc/dbus-1/system.d/consumer.app.conf": []byte("" +
 			"<!DOCTYPE busconfig PUBLIC\n" +
 			" \"-//freedesktop//DTD D-BUS Bus Configuration 1.0//EN\"\n" +
 			" \"http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd\">\n" +
@@ -234,3 +234,31 @@ func (s *SecuritySuite) TestDBusSlotPermissions(c *C) {
 			"</busconfig>\n"),
 	})
 }
+
+// Tests for per-app scoping
+
+func (s *SecuritySuite) TestSlotPermissionsAreScopedToApps(c *C) {
+	err := s.repo.AddType(&TestType{
+		TypeName: "type",
+		SlotSecuritySnippetCallback: func(skill *Skill, securitySystem SecuritySystem) ([]byte, error) {
+			if securitySystem == SecurityUDev {
+				return []byte(skill.Name + "\n"), nil
+			}
+			return nil, nil
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(s.repo.AddSkill(&Skill{Snap: "producer", Name: "foo", Type: "type"}), IsNil)
+	c.Assert(s.repo.AddSkill(&Skill{Snap: "producer", Name: "bar", Type: "type"}), IsNil)
+	c.Assert(s.repo.AddSlot(&Slot{Snap: "consumer", Name: "foo", Type: "type", Apps: []string{"app1"}}), IsNil)
+	c.Assert(s.repo.AddSlot(&Slot{Snap: "consumer", Name: "bar", Type: "type", Apps: []string{"app1", "app2"}}), IsNil)
+	c.Assert(s.repo.AddSlot(&Slot{Snap: "consumer", Name: "unused", Type: "type", Apps: []string{"app3"}}), IsNil)
+	c.Assert(s.repo.Grant("producer", "foo", "consumer", "foo"), IsNil)
+	c.Assert(s.repo.Grant("producer", "bar", "consumer", "bar"), IsNil)
+	// Each app gets a separate file with just the grants of slots it is listed in.
+	blobs, err := s.repo.SecurityFilesForSnap("consumer")
+	c.Assert(err, IsNil)
+	c.Check(blobs, HasLen, 2)
+	c.Check(string(blobs["/etc/udev/rules.d/70-snappy-consumer-app1.rules"]), Equals, "bar\nfoo\n")
+	c.Check(string(blobs["/etc/udev/rules.d/70-snappy-consumer-app2.rules"]), Equals, "bar\n")
+}
@@ -36,6 +36,12 @@ type TestType struct {
 	SlotSecuritySnippetCallback func(skill *Skill, securitySystem SecuritySystem) ([]byte, error)
 	// SkillSecuritySnippetCallback is the callback invoked inside SkillSecuritySnippet()
 	SkillSecuritySnippetCallback func(skill *Skill, securitySystem SecuritySystem) ([]byte, error)
+	// CheckCompatibilityCallback is the callback invoked inside CheckCompatibility()
+	CheckCompatibilityCallback func(skill *Skill, slot *Slot) error
+	// SkillAttrs is the schema returned by SkillAttrSchema()
+	SkillAttrs AttrSchema
+	// SlotAttrs is the schema returned by SlotAttrSchema()
+	SlotAttrs AttrSchema
 }
 
 // String() returns the same value as Name().
@@ -87,3 +93,22 @@ func (t *TestType) SlotSecuritySnippet(skill *Skill, securitySystem SecuritySyst
 	}
 	return nil, nil
 }
+
+// SkillAttrSchema returns the schema of the attributes of test skills.
+func (t *TestType) SkillAttrSchema() AttrSchema {
+	return t.SkillAttrs
+}
+
+// SlotAttrSchema returns the schema of the attributes of test slots.
+func (t *TestType) SlotAttrSchema() AttrSchema {
+	return t.SlotAttrs
+}
+
+// CheckCompatibility checks if a test skill can be granted to a test slot.
+// All skills and slots are compatible by default.
+func (t *TestType) CheckCompatibility(skill *Skill, slot *Slot) error {
+	if t.CheckCompatibilityCallback != nil {
+		return t.CheckCompatibilityCallback(skill, slot)
+	}
+	return nil
+}
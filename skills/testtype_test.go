@@ -141,3 +141,21 @@ func (s *TestTypeSuite) TestSlotSecuritySnippet(c *C) {
 	c.Assert(err, IsNil)
 	c.Assert(snippet, IsNil)
 }
+
+// TestType considers all skills and slots compatible by default
+func (s *TestTypeSuite) TestCheckCompatibilityOK(c *C) {
+	err := s.t.(CompatibilityChecker).CheckCompatibility(&Skill{Type: "test"}, &Slot{Type: "test"})
+	c.Assert(err, IsNil)
+}
+
+// TestType has provisions to customize compatibility checks
+func (s *TestTypeSuite) TestCheckCompatibilityError(c *C) {
+	t := &TestType{
+		TypeName: "test",
+		CheckCompatibilityCallback: func(skill *Skill, slot *Slot) error {
+			return fmt.Errorf("incompatible")
+		},
+	}
+	err := t.CheckCompatibility(&Skill{Type: "test"}, &Slot{Type: "test"})
+	c.Assert(err, ErrorMatches, "incompatible")
+}
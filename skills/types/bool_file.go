@@ -49,6 +49,19 @@ var boolFileAllowedPathPatterns = []*regexp.Regexp{
 	boolFileGPIOValuePattern,
 }
 
+// SkillAttrSchema returns the schema of the attributes of bool-file skills.
+func (t *BoolFileType) SkillAttrSchema() skills.AttrSchema {
+	return skills.AttrSchema{
+		"path": {Kind: skills.AttrString, Required: true},
+	}
+}
+
+// SlotAttrSchema returns the schema of the attributes of bool-file slots.
+// Slots don't have any attributes.
+func (t *BoolFileType) SlotAttrSchema() skills.AttrSchema {
+	return skills.AttrSchema{}
+}
+
 // SanitizeSkill checks and possibly modifies a skill.
 // Valid "bool-file" skills must contain the attribute "path".
 func (t *BoolFileType) SanitizeSkill(skill *skills.Skill) error {
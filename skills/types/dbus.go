@@ -0,0 +1,152 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/ubuntu-core/snappy/skills"
+)
+
+// DBusType is the type of all the dbus skills.
+//
+// A dbus skill describes a well-known bus name owned by the offering snap
+// together with the interface that is exported under that name. The
+// offering snap is allowed to own the name while snaps with granted slots
+// are allowed to exchange messages with it.
+//
+// Bus policy cannot tell apart the processes of a user, so the policy of
+// the consumers lets any process send to the name; AppArmor mediation is
+// the only gate that keeps apps without a granted slot away from it.
+//
+// Only the system bus is supported: the policy files are written where
+// the system bus reads them.
+type DBusType struct{}
+
+// String returns the same value as Name().
+func (t *DBusType) String() string {
+	return t.Name()
+}
+
+// Name returns the name of the dbus type.
+func (t *DBusType) Name() string {
+	return "dbus"
+}
+
+// Pattern of both bus names and interface names.
+// See http://dbus.freedesktop.org/doc/dbus-specification.html
+var dbusNamePattern = regexp.MustCompile(
+	"^[A-Za-z0-9][A-Za-z0-9_-]*(\\.[A-Za-z0-9][A-Za-z0-9_-]*)+$")
+
+// SkillAttrSchema returns the schema of the attributes of dbus skills.
+// Valid "dbus" skills must contain the attributes "bus-name" and "interface".
+// The optional attribute "bus" can only be the system bus, the default.
+func (t *DBusType) SkillAttrSchema() skills.AttrSchema {
+	return skills.AttrSchema{
+		"bus-name":  {Kind: skills.AttrString, Required: true, Pattern: dbusNamePattern},
+		"interface": {Kind: skills.AttrString, Required: true, Pattern: dbusNamePattern},
+		"bus":       {Kind: skills.AttrString, Enum: []string{"system"}, Default: "system"},
+	}
+}
+
+// SlotAttrSchema returns the schema of the attributes of dbus slots.
+// Slots don't have any attributes.
+func (t *DBusType) SlotAttrSchema() skills.AttrSchema {
+	return skills.AttrSchema{}
+}
+
+// SanitizeSkill checks and possibly modifies a skill.
+// Attributes are checked by the repository, see SkillAttrSchema.
+func (t *DBusType) SanitizeSkill(skill *skills.Skill) error {
+	if t.Name() != skill.Type {
+		panic(fmt.Sprintf("skill is not of type %q", t))
+	}
+	return nil
+}
+
+// SanitizeSlot checks and possibly modifies a skill slot.
+func (t *DBusType) SanitizeSlot(slot *skills.Slot) error {
+	if t.Name() != slot.Type {
+		panic(fmt.Sprintf("skill slot is not of type %q", t))
+	}
+	// NOTE: currently we don't check anything on the slot side.
+	return nil
+}
+
+// SkillSecuritySnippet returns the configuration snippet required to provide a dbus skill.
+// Producers gain permission to own the bus name and to talk over the interface.
+func (t *DBusType) SkillSecuritySnippet(skill *skills.Skill, securitySystem skills.SecuritySystem) ([]byte, error) {
+	bus, busName, iface := t.attrs(skill)
+	switch securitySystem {
+	case skills.SecurityDBus:
+		return []byte(fmt.Sprintf(""+
+			"<policy user=\"root\">\n"+
+			"    <allow own=\"%s\"/>\n"+
+			"    <allow send_destination=\"%s\" send_interface=\"%s\"/>\n"+
+			"</policy>\n", busName, busName, iface)), nil
+	case skills.SecurityAppArmor:
+		return []byte(fmt.Sprintf(""+
+			"dbus (bind) bus=%s name=\"%s\",\n"+
+			"dbus (receive, send) bus=%s interface=\"%s\",\n",
+			bus, busName, bus, iface)), nil
+	case skills.SecuritySecComp, skills.SecurityUDev:
+		return nil, nil
+	default:
+		return nil, skills.ErrUnknownSecurity
+	}
+}
+
+// SlotSecuritySnippet returns the configuration snippet required to use a dbus skill.
+// Consumers gain permission to send messages to the bus name over the
+// interface and to receive replies and signals from it. The bus policy is
+// not limited to the consumers, it is the AppArmor rules that are.
+func (t *DBusType) SlotSecuritySnippet(skill *skills.Skill, securitySystem skills.SecuritySystem) ([]byte, error) {
+	bus, busName, iface := t.attrs(skill)
+	switch securitySystem {
+	case skills.SecurityDBus:
+		return []byte(fmt.Sprintf(""+
+			"<policy context=\"default\">\n"+
+			"    <allow send_destination=\"%s\" send_interface=\"%s\"/>\n"+
+			"    <allow receive_sender=\"%s\"/>\n"+
+			"</policy>\n", busName, iface, busName)), nil
+	case skills.SecurityAppArmor:
+		return []byte(fmt.Sprintf(""+
+			"dbus (send) bus=%s interface=\"%s\" peer=(name=\"%s\"),\n"+
+			"dbus (receive) bus=%s interface=\"%s\",\n",
+			bus, iface, busName, bus, iface)), nil
+	case skills.SecuritySecComp, skills.SecurityUDev:
+		return nil, nil
+	default:
+		return nil, skills.ErrUnknownSecurity
+	}
+}
+
+// attrs returns the bus, bus name and interface of a sanitized dbus skill.
+func (t *DBusType) attrs(skill *skills.Skill) (bus, busName, iface string) {
+	var ok1, ok2, ok3 bool
+	bus, ok1 = skill.Attrs["bus"].(string)
+	busName, ok2 = skill.Attrs["bus-name"].(string)
+	iface, ok3 = skill.Attrs["interface"].(string)
+	if !ok1 || !ok2 || !ok3 {
+		panic("skill is not sanitized")
+	}
+	return bus, busName, iface
+}
@@ -0,0 +1,194 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types_test
+
+import (
+	"bytes"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/skills"
+	"github.com/ubuntu-core/snappy/skills/types"
+)
+
+type DBusTypeSuite struct {
+	t skills.Type
+}
+
+var _ = Suite(&DBusTypeSuite{
+	t: &types.DBusType{},
+})
+
+func (s *DBusTypeSuite) skill() *skills.Skill {
+	return &skills.Skill{
+		Type: "dbus",
+		Attrs: map[string]interface{}{
+			"bus-name":  "com.example.Foo",
+			"interface": "com.example.Foo.Bar",
+		},
+	}
+}
+
+func (s *DBusTypeSuite) sanitizedSkill(c *C) *skills.Skill {
+	skill := s.skill()
+	attrs, err := s.t.(skills.AttrSchemaType).SkillAttrSchema().Validate(skill.Attrs)
+	c.Assert(err, IsNil)
+	skill.Attrs = attrs
+	return skill
+}
+
+func (s *DBusTypeSuite) TestName(c *C) {
+	c.Assert(s.t.Name(), Equals, "dbus")
+}
+
+func (s *DBusTypeSuite) TestSanitizeSkill(c *C) {
+	err := s.t.SanitizeSkill(s.skill())
+	c.Assert(err, IsNil)
+	// It is impossible to use "dbus" type to sanitize skills of other types.
+	c.Assert(func() { s.t.SanitizeSkill(&skills.Skill{Type: "other-type"}) }, PanicMatches,
+		`skill is not of type "dbus"`)
+}
+
+func (s *DBusTypeSuite) TestSkillAttrSchema(c *C) {
+	schema := s.t.(skills.AttrSchemaType).SkillAttrSchema()
+	attrs, err := schema.Validate(s.skill().Attrs)
+	c.Assert(err, IsNil)
+	// The system bus is used by default
+	c.Assert(attrs["bus"], Equals, "system")
+	// The system bus can be given explicitly
+	skill := s.skill()
+	skill.Attrs["bus"] = "system"
+	attrs, err = schema.Validate(skill.Attrs)
+	c.Assert(err, IsNil)
+	c.Assert(attrs["bus"], Equals, "system")
+}
+
+func (s *DBusTypeSuite) TestSkillAttrSchemaErrors(c *C) {
+	repo := skills.NewRepository()
+	c.Assert(repo.AddType(s.t), IsNil)
+	for _, t := range []struct {
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"bus-name", nil, `cannot add skill: attribute "bus-name" is required`},
+		{"bus-name", "foo", `cannot add skill: attribute "bus-name" has invalid value "foo"`},
+		{"interface", nil, `cannot add skill: attribute "interface" is required`},
+		{"interface", ".foo", `cannot add skill: attribute "interface" has invalid value ".foo"`},
+		{"bus", "user", `cannot add skill: attribute "bus" must be one of system, not "user"`},
+		// the policy is written where only the system bus reads it
+		{"bus", "session", `cannot add skill: attribute "bus" must be one of system, not "session"`},
+		{"bus", 42, `cannot add skill: attribute "bus" must be a string`},
+		{"path", "/foo", `cannot add skill: unknown attribute "path"`},
+	} {
+		skill := s.skill()
+		skill.Snap = "producer"
+		skill.Name = "skill"
+		if t.value == nil {
+			delete(skill.Attrs, t.key)
+		} else {
+			skill.Attrs[t.key] = t.value
+		}
+		err := repo.AddSkill(skill)
+		c.Check(err, ErrorMatches, t.err)
+	}
+}
+
+func (s *DBusTypeSuite) TestSanitizeSlot(c *C) {
+	err := s.t.SanitizeSlot(&skills.Slot{Type: "dbus"})
+	c.Assert(err, IsNil)
+	// It is impossible to use "dbus" type to sanitize slots of other types.
+	c.Assert(func() { s.t.SanitizeSlot(&skills.Slot{Type: "other-type"}) }, PanicMatches,
+		`skill slot is not of type "dbus"`)
+}
+
+func (s *DBusTypeSuite) TestSkillSecuritySnippet(c *C) {
+	skill := s.sanitizedSkill(c)
+	// The offering snap may own the bus name
+	snippet, err := s.t.SkillSecuritySnippet(skill, skills.SecurityDBus)
+	c.Assert(err, IsNil)
+	c.Assert(string(snippet), Equals, ""+
+		"<policy user=\"root\">\n"+
+		"    <allow own=\"com.example.Foo\"/>\n"+
+		"    <allow send_destination=\"com.example.Foo\" send_interface=\"com.example.Foo.Bar\"/>\n"+
+		"</policy>\n")
+	snippet, err = s.t.SkillSecuritySnippet(skill, skills.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Assert(string(snippet), Equals, ""+
+		"dbus (bind) bus=system name=\"com.example.Foo\",\n"+
+		"dbus (receive, send) bus=system interface=\"com.example.Foo.Bar\",\n")
+}
+
+func (s *DBusTypeSuite) TestSlotSecuritySnippet(c *C) {
+	skill := s.sanitizedSkill(c)
+	// The consuming snap may talk to the bus name
+	snippet, err := s.t.SlotSecuritySnippet(skill, skills.SecurityDBus)
+	c.Assert(err, IsNil)
+	c.Assert(string(snippet), Equals, ""+
+		"<policy context=\"default\">\n"+
+		"    <allow send_destination=\"com.example.Foo\" send_interface=\"com.example.Foo.Bar\"/>\n"+
+		"    <allow receive_sender=\"com.example.Foo\"/>\n"+
+		"</policy>\n")
+	snippet, err = s.t.SlotSecuritySnippet(skill, skills.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Assert(string(snippet), Equals, ""+
+		"dbus (send) bus=system interface=\"com.example.Foo.Bar\" peer=(name=\"com.example.Foo\"),\n"+
+		"dbus (receive) bus=system interface=\"com.example.Foo.Bar\",\n")
+}
+
+func (s *DBusTypeSuite) TestSlotSecurityDoesNotContainSkillSecurity(c *C) {
+	skill := s.sanitizedSkill(c)
+	slotSnippet, err := s.t.SlotSecuritySnippet(skill, skills.SecurityDBus)
+	c.Assert(err, IsNil)
+	// Ensure that we don't accidentally allow consumers to own the bus name.
+	c.Assert(bytes.Contains(slotSnippet, []byte("own=")), Equals, false)
+	slotSnippet, err = s.t.SlotSecuritySnippet(skill, skills.SecurityAppArmor)
+	c.Assert(err, IsNil)
+	c.Assert(bytes.Contains(slotSnippet, []byte("bind")), Equals, false)
+}
+
+func (s *DBusTypeSuite) TestSecuritySnippetPanicksOnUnsanitizedSkills(c *C) {
+	// Unsanitized skills should never be used and cause a panic.
+	c.Assert(func() {
+		s.t.SkillSecuritySnippet(s.skill(), skills.SecurityDBus)
+	}, PanicMatches, "skill is not sanitized")
+	c.Assert(func() {
+		s.t.SlotSecuritySnippet(s.skill(), skills.SecurityDBus)
+	}, PanicMatches, "skill is not sanitized")
+}
+
+func (s *DBusTypeSuite) TestSecuritySnippetUnusedSecuritySystems(c *C) {
+	skill := s.sanitizedSkill(c)
+	for _, system := range []skills.SecuritySystem{skills.SecuritySecComp, skills.SecurityUDev} {
+		snippet, err := s.t.SkillSecuritySnippet(skill, system)
+		c.Assert(err, IsNil)
+		c.Assert(snippet, IsNil)
+		snippet, err = s.t.SlotSecuritySnippet(skill, system)
+		c.Assert(err, IsNil)
+		c.Assert(snippet, IsNil)
+	}
+	// Other security types are not recognized
+	snippet, err := s.t.SkillSecuritySnippet(skill, "foo")
+	c.Assert(err, ErrorMatches, `unknown security system`)
+	c.Assert(snippet, IsNil)
+	snippet, err = s.t.SlotSecuritySnippet(skill, "foo")
+	c.Assert(err, ErrorMatches, `unknown security system`)
+	c.Assert(snippet, IsNil)
+}
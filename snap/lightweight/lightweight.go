@@ -487,5 +487,14 @@ func (bag *PartBag) Map(remotePart snappy.Part) map[string]interface{} {
 		result["update_available"] = update
 	}
 
+	if part != nil {
+		if hold := snappy.SnapHold(name + "." + origin); hold != nil {
+			result["hold"] = hold
+		}
+		if usage, err := snappy.SnapQuotaUsage(part); err == nil && usage != nil {
+			result["quota"] = usage
+		}
+	}
+
 	return result
 }
@@ -211,6 +211,14 @@ func (s *lightweightSuite) TestMapAppWithPart(c *check.C) {
 	})
 }
 
+func (s *lightweightSuite) TestMapAppHeld(c *check.C) {
+	_, err := snappy.SetHold("foo.bar", nil, "1.1")
+	c.Assert(err, check.IsNil)
+
+	m := PartBagByName("foo", "bar").Map(nil)
+	c.Check(m["hold"], check.DeepEquals, &snappy.Hold{Snap: "foo.bar", Version: "1.1"})
+}
+
 func (s *lightweightSuite) TestMapAppNoPartBag(c *check.C) {
 	snap := remote.Snap{
 		Name:         "foo",
@@ -31,6 +31,7 @@ type Snap struct {
 	Alias           string             `json:"alias,omitempty"`
 	AnonDownloadURL string             `json:"anon_download_url,omitempty"`
 	Channel         string             `json:"channel,omitempty"`
+	Deltas          []Delta            `json:"deltas,omitempty"`
 	DownloadSha512  string             `json:"download_sha512,omitempty"`
 	Description     string             `json:"description,omitempty"`
 	DownloadSize    int64              `json:"binary_filesize,omitempty"`
@@ -48,3 +49,15 @@ type Snap struct {
 	Type            snap.Type          `json:"content,omitempty"`
 	Version         string             `json:"version"`
 }
+
+// A Delta describes a binary delta the store offers to go from an older
+// revision of a snap to the revision it was sent with.
+type Delta struct {
+	FromRevision    int64  `json:"from_revision"`
+	ToRevision      int64  `json:"to_revision"`
+	Format          string `json:"format"`
+	AnonDownloadURL string `json:"anon_download_url,omitempty"`
+	DownloadURL     string `json:"download_url,omitempty"`
+	DownloadSha512  string `json:"download_sha512,omitempty"`
+	DownloadSize    int64  `json:"binary_filesize,omitempty"`
+}
@@ -0,0 +1,294 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/logger"
+)
+
+// The sources of the changes recorded in the audit log
+const (
+	AuditSourceCLI       = "cli"
+	AuditSourceAPI       = "api"
+	AuditSourceAutopilot = "autopilot"
+	AuditSourceFirstBoot = "firstboot"
+)
+
+// The results of the changes recorded in the audit log
+const (
+	AuditSucceeded = "succeeded"
+	AuditFailed    = "failed"
+)
+
+// AuditUnknownUID is the uid of changes whose requester is not known
+const AuditUnknownUID = -1
+
+var (
+	// auditLogMaxSize is the size at which the audit log is rotated
+	auditLogMaxSize int64 = 1024 * 1024
+	// auditLogKeep is how many rotated audit logs are kept
+	auditLogKeep = 4
+)
+
+// auditLogMu serializes the writes to the audit log of this process, the
+// processes themselves are serialized by the snappy lock
+var auditLogMu sync.Mutex
+
+// AuditEntry is a change of a snap recorded in the audit log
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Snap   string    `json:"snap"`
+	// From and To are the active versions before and after the change
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	UID    int    `json:"uid"`
+	Source string `json:"source"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// activeVersions returns the active version of every snap by qualified
+// name
+func activeVersions() map[string]string {
+	versions := make(map[string]string)
+	installed, err := NewLocalSnapRepository().Installed()
+	if err != nil {
+		return versions
+	}
+	for _, part := range installed {
+		if part.IsActive() {
+			versions[QualifiedName(part)] = part.Version()
+		}
+	}
+
+	return versions
+}
+
+// Audited makes the given change and records it in the audit log as the
+// given action on the given snap, on behalf of the given uid and source.
+//
+// Every snap whose active version the change altered gets an entry, so
+// that e.g. installing a local file or updating all snaps records the
+// snaps that were affected. If none was the entry is for the given snap,
+// and nothing is recorded if no snap is given. Failing to write the audit
+// log does not fail the change.
+func Audited(source string, uid int, action, snap string, change func() error) error {
+	before := activeVersions()
+	err := change()
+	after := activeVersions()
+
+	entry := AuditEntry{
+		Time:   time.Now().UTC(),
+		Action: action,
+		UID:    uid,
+		Source: source,
+		Result: AuditSucceeded,
+	}
+	if err != nil {
+		entry.Result = AuditFailed
+		entry.Error = err.Error()
+	}
+
+	var entries []AuditEntry
+	for _, name := range changedSnaps(before, after) {
+		entry.Snap, entry.From, entry.To = name, before[name], after[name]
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		if snap == "" {
+			return err
+		}
+		entry.Snap = auditQualifiedName(snap, after)
+		entry.From, entry.To = before[entry.Snap], after[entry.Snap]
+		entries = append(entries, entry)
+	}
+
+	if werr := writeAuditLog(entries); werr != nil {
+		logger.Noticef("Cannot write the audit log: %v", werr)
+	}
+
+	return err
+}
+
+// changedSnaps returns the sorted names of the snaps whose version
+// differs between before and after
+func changedSnaps(before, after map[string]string) []string {
+	var changed []string
+	for name, version := range before {
+		if after[name] != version {
+			changed = append(changed, name)
+		}
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+
+	return changed
+}
+
+// auditQualifiedName returns the qualified name of the snap with the
+// given name in the given active versions, or the name itself if there
+// is none
+func auditQualifiedName(snap string, versions map[string]string) string {
+	name, origin := SplitOrigin(snap)
+	for qn := range versions {
+		n, o := SplitOrigin(qn)
+		if qn == snap || (n == name && origin == "" && o != "") {
+			return qn
+		}
+	}
+
+	return snap
+}
+
+// rotatedAuditLog returns the path of the given rotated audit log, the
+// current one is 0
+func rotatedAuditLog(n int) string {
+	if n == 0 {
+		return dirs.SnapAuditLogFile
+	}
+
+	return fmt.Sprintf("%s.%d", dirs.SnapAuditLogFile, n)
+}
+
+// rotateAuditLog rotates the audit log if it has grown too big
+func rotateAuditLog() error {
+	fi, err := os.Stat(dirs.SnapAuditLogFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Size() < auditLogMaxSize {
+		return nil
+	}
+
+	os.Remove(rotatedAuditLog(auditLogKeep))
+	for n := auditLogKeep - 1; n >= 0; n-- {
+		if err := os.Rename(rotatedAuditLog(n), rotatedAuditLog(n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeAuditLog appends the given entries to the audit log
+func writeAuditLog(entries []AuditEntry) (err error) {
+	auditLogMu.Lock()
+	defer auditLogMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(dirs.SnapAuditLogFile), 0755); err != nil {
+		return err
+	}
+	if err := rotateAuditLog(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(dirs.SnapAuditLogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(&entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AuditLog returns the entries of the audit log, including the rotated
+// ones, oldest first. If snaps are given only their entries are returned.
+func AuditLog(snaps []string) ([]AuditEntry, error) {
+	var entries []AuditEntry
+	for n := auditLogKeep; n >= 0; n-- {
+		more, err := readAuditLog(rotatedAuditLog(n), snaps)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, more...)
+	}
+
+	return entries, nil
+}
+
+// readAuditLog reads the entries of the given audit log file for the
+// given snaps, skipping the lines it cannot parse
+func readAuditLog(path string, snaps []string) ([]AuditEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if len(snaps) > 0 && !auditEntryMatches(&entry, snaps) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// auditEntryMatches returns whether the entry is about one of the given
+// snaps, given by name or qualified name
+func auditEntryMatches(entry *AuditEntry, snaps []string) bool {
+	name, origin := SplitOrigin(entry.Snap)
+	for _, snap := range snaps {
+		if snap == entry.Snap || (snap == name && origin != "") {
+			return true
+		}
+	}
+
+	return false
+}
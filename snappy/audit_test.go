@@ -0,0 +1,134 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/dirs"
+)
+
+func (s *SnapTestSuite) TestAuditedRecordsChangedSnaps(c *C) {
+	err := Audited(AuditSourceCLI, 1000, "install", "foo", func() error {
+		s.makeInstalledFoo(c)
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	entries, err := AuditLog(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	entry := entries[0]
+	c.Check(entry.Time.IsZero(), Equals, false)
+	c.Check(entry, DeepEquals, AuditEntry{
+		Time:   entry.Time,
+		Action: "install",
+		Snap:   "foo." + testOrigin,
+		To:     "1",
+		UID:    1000,
+		Source: AuditSourceCLI,
+		Result: AuditSucceeded,
+	})
+}
+
+func (s *SnapTestSuite) TestAuditedRecordsFailures(c *C) {
+	s.makeInstalledFoo(c)
+
+	err := Audited(AuditSourceAPI, AuditUnknownUID, "remove", "foo", func() error {
+		return errors.New("boom")
+	})
+	c.Assert(err, ErrorMatches, "boom")
+
+	entries, err := AuditLog(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].Snap, Equals, "foo."+testOrigin)
+	c.Check(entries[0].From, Equals, "1")
+	c.Check(entries[0].To, Equals, "1")
+	c.Check(entries[0].UID, Equals, AuditUnknownUID)
+	c.Check(entries[0].Source, Equals, AuditSourceAPI)
+	c.Check(entries[0].Result, Equals, AuditFailed)
+	c.Check(entries[0].Error, Equals, "boom")
+}
+
+func (s *SnapTestSuite) TestAuditedSkipsNoopWithoutSnap(c *C) {
+	s.makeInstalledFoo(c)
+
+	err := Audited(AuditSourceAutopilot, 0, "update", "", func() error {
+		return nil
+	})
+	c.Assert(err, IsNil)
+
+	entries, err := AuditLog(nil)
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 0)
+}
+
+func (s *SnapTestSuite) TestAuditLogFilters(c *C) {
+	c.Assert(writeAuditLog([]AuditEntry{
+		{Action: "install", Snap: "foo." + testOrigin},
+		{Action: "install", Snap: "bar.other"},
+		{Action: "update", Snap: "foo." + testOrigin},
+	}), IsNil)
+
+	entries, err := AuditLog([]string{"foo"})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 2)
+	c.Check(entries[0].Action, Equals, "install")
+	c.Check(entries[1].Action, Equals, "update")
+
+	entries, err = AuditLog([]string{"bar.other"})
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].Snap, Equals, "bar.other")
+
+	entries, err = AuditLog([]string{"baz"})
+	c.Assert(err, IsNil)
+	c.Check(entries, HasLen, 0)
+}
+
+func (s *SnapTestSuite) TestAuditLogRotates(c *C) {
+	oldMaxSize, oldKeep := auditLogMaxSize, auditLogKeep
+	auditLogMaxSize, auditLogKeep = 1, 2
+	defer func() { auditLogMaxSize, auditLogKeep = oldMaxSize, oldKeep }()
+
+	for _, action := range []string{"install", "update", "rollback", "remove"} {
+		c.Assert(writeAuditLog([]AuditEntry{{Action: action, Snap: "foo"}}), IsNil)
+	}
+
+	// the oldest entry was rotated away
+	_, err := os.Stat(dirs.SnapAuditLogFile + ".3")
+	c.Check(os.IsNotExist(err), Equals, true)
+	content, err := ioutil.ReadFile(dirs.SnapAuditLogFile + ".2")
+	c.Assert(err, IsNil)
+	c.Check(strings.Contains(string(content), `"update"`), Equals, true)
+
+	entries, err := AuditLog(nil)
+	c.Assert(err, IsNil)
+	c.Assert(entries, HasLen, 3)
+	c.Check(entries[0].Action, Equals, "update")
+	c.Check(entries[1].Action, Equals, "rollback")
+	c.Check(entries[2].Action, Equals, "remove")
+}
@@ -0,0 +1,57 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+)
+
+// trackedChannelPath returns the path of the file that records the channel
+// the given snap tracks
+func trackedChannelPath(qualifiedName string) string {
+	return filepath.Join(dirs.SnapMetaDir, qualifiedName+".channel")
+}
+
+// TrackedChannel returns the channel the snap with the given qualified
+// name tracks, or an empty string if it tracks no particular channel.
+func TrackedChannel(qualifiedName string) string {
+	content, err := ioutil.ReadFile(trackedChannelPath(qualifiedName))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(content))
+}
+
+// setTrackedChannel records the channel the snap with the given qualified
+// name tracks from now on
+func setTrackedChannel(qualifiedName, channel string) error {
+	if err := os.MkdirAll(dirs.SnapMetaDir, 0755); err != nil {
+		return err
+	}
+
+	return helpers.AtomicWriteFile(trackedChannelPath(qualifiedName), []byte(channel+"\n"), 0644, 0)
+}
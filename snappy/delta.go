@@ -0,0 +1,118 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/snap/remote"
+	"github.com/ubuntu-core/snappy/snap/squashfs"
+)
+
+// deltaFormat is the binary delta format snappy asks the store for
+const deltaFormat = "xdelta3"
+
+// haveDeltaTool returns true if the tool to apply deltas is installed
+var haveDeltaTool = func() bool {
+	_, err := exec.LookPath("xdelta3")
+	return err == nil
+}
+
+// errNoDelta is returned when there is no delta that can be used to
+// get to a snap revision
+var errNoDelta = errors.New("no usable delta")
+
+// activeStorePart returns the active version of the snap with the given
+// name if it was installed from the store with the given origin, nil
+// otherwise
+func activeStorePart(name, origin string) *SnapPart {
+	part, ok := ActiveSnapByName(name).(*SnapPart)
+	if !ok || part.Origin() != origin || part.Revision() == 0 {
+		return nil
+	}
+
+	return part
+}
+
+// deltaFromActive returns the active version of the given snap and the
+// delta the store offers from its revision. Deltas are only used if the
+// reconstructed snap can be verified against the full sha512.
+func deltaFromActive(remoteSnap *RemoteSnapPart) (*SnapPart, *remote.Delta) {
+	if remoteSnap.pkg.DownloadSha512 == "" {
+		return nil, nil
+	}
+	active := activeStorePart(remoteSnap.Name(), remoteSnap.Origin())
+	if active == nil {
+		return nil, nil
+	}
+	for i, delta := range remoteSnap.pkg.Deltas {
+		if delta.Format == deltaFormat && delta.FromRevision == active.Revision() && delta.ToRevision == remoteSnap.pkg.Revision {
+			return active, &remoteSnap.pkg.Deltas[i]
+		}
+	}
+
+	return active, nil
+}
+
+// deltaName returns the file name of the delta from the given revision
+// to the revision of the given snap
+func deltaName(remoteSnap *RemoteSnapPart, fromRevision int64) string {
+	return fmt.Sprintf("%s.%s_%d_%d.delta", remoteSnap.pkg.Name, remoteSnap.pkg.Origin, fromRevision, remoteSnap.pkg.Revision)
+}
+
+// applyDelta reconstructs the target file from the source file and the
+// delta between them
+var applyDelta = func(source, delta, target string) error {
+	cmd := exec.Command("xdelta3", "-d", "-f", "-s", source, delta, target)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot apply delta %q: %s (%q)", delta, err, output)
+	}
+
+	return nil
+}
+
+// reconstructFromDelta applies the delta to the blob of the active
+// version of the snap and stores the result in the download cache once
+// its sha512 matches the one of the full snap.
+func reconstructFromDelta(remoteSnap *RemoteSnapPart, active *SnapPart, delta string) (string, error) {
+	if err := os.MkdirAll(dirs.SnapDownloadCacheDir, 0755); err != nil {
+		return "", err
+	}
+
+	target := downloadCachePath(remoteSnap)
+	reconstructed := target + ".delta"
+	if err := applyDelta(squashfs.BlobPath(active.basedir), delta, reconstructed); err != nil {
+		os.Remove(reconstructed)
+		return "", err
+	}
+	if err := verifyDownload(reconstructed, remoteSnap.pkg.DownloadSha512); err != nil {
+		os.Remove(reconstructed)
+		return "", err
+	}
+	if err := os.Rename(reconstructed, target); err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/ubuntu-core/snappy/arch"
@@ -138,6 +139,14 @@ var (
 	ErrInvalidSeccompPolicy = errors.New("policy-version and policy-vendor must be specified together")
 	// ErrNoSeccompPolicy is returned when an expected seccomp policy is not provided.
 	ErrNoSeccompPolicy = errors.New("no seccomp policy provided")
+
+	// ErrHashMismatch is returned when a downloaded snap does not have the
+	// sha512 announced by the store
+	ErrHashMismatch = errors.New("downloaded snap does not match the expected sha512")
+
+	// ErrSnapshotNotFound is returned when there is no snapshot with the
+	// given id
+	ErrSnapshotNotFound = errors.New("snapshot not found")
 )
 
 // ErrDownload represents a download error
@@ -171,6 +180,46 @@ func (e *ErrInstallFailed) Error() string {
 	return fmt.Sprintf("%s failed to install: %s", e.Snap, e.OrigErr)
 }
 
+// ErrUpdateAllFailed is returned by a transactional UpdateAll if one of
+// the updates failed to apply. It reports which snaps were rolled back to
+// their previous version and which could not be.
+type ErrUpdateAllFailed struct {
+	Snap       string
+	OrigErr    error
+	RolledBack []string
+	// RollbackErrs maps the snaps that could not be rolled back to the
+	// reason why
+	RollbackErrs map[string]error
+}
+
+func (e *ErrUpdateAllFailed) Error() string {
+	msg := fmt.Sprintf("%s failed to update: %s", e.Snap, e.OrigErr)
+	if len(e.RolledBack) > 0 {
+		msg += fmt.Sprintf(" (rolled back: %s)", strings.Join(e.RolledBack, ", "))
+	}
+	if len(e.RollbackErrs) > 0 {
+		names := make([]string, 0, len(e.RollbackErrs))
+		for name := range e.RollbackErrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			msg += fmt.Sprintf("; cannot roll back %s: %s", name, e.RollbackErrs[name])
+		}
+	}
+
+	return msg
+}
+
+// ErrSnapHeld is returned when updating a snap that is held
+type ErrSnapHeld struct {
+	Hold *Hold
+}
+
+func (e *ErrSnapHeld) Error() string {
+	return fmt.Sprintf("cannot update %s: it is %s", e.Hold.Snap, e.Hold)
+}
+
 // ErrHookFailed is returned if a hook command fails
 type ErrHookFailed struct {
 	Cmd      string
@@ -182,6 +231,38 @@ func (e *ErrHookFailed) Error() string {
 	return fmt.Sprintf("hook command %v failed with exit status %d (output: %q)", e.Cmd, e.ExitCode, e.Output)
 }
 
+// ErrHealthCheckFailed is returned if the new version of a snap failed
+// its health check after an update. The previous version is made
+// active again, unless that failed too (see RollbackErr).
+type ErrHealthCheckFailed struct {
+	Snap         string `json:"snap"`
+	Version      string `json:"version"`
+	Reason       string `json:"reason"`
+	RolledBackTo string `json:"rolled-back-to"`
+	RollbackErr  error  `json:"-"`
+}
+
+func (e *ErrHealthCheckFailed) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("%s %s failed its health check (%s) and cannot be rolled back to %s: %s", e.Snap, e.Version, e.Reason, e.RolledBackTo, e.RollbackErr)
+	}
+
+	return fmt.Sprintf("%s %s failed its health check (%s), rolled back to %s", e.Snap, e.Version, e.Reason, e.RolledBackTo)
+}
+
+// ErrInsufficientSpace is returned if there is not enough free disk
+// space on the filesystem that holds Path to install a snap
+type ErrInsufficientSpace struct {
+	Snap   string `json:"snap"`
+	Path   string `json:"path"`
+	Needed uint64 `json:"needed"`
+	Free   uint64 `json:"free"`
+}
+
+func (e *ErrInsufficientSpace) Error() string {
+	return fmt.Sprintf("cannot install %s: it needs %d bytes on the filesystem of %s but only %d are free", e.Snap, e.Needed, e.Path, e.Free)
+}
+
 // ErrDataCopyFailed is returned if copying the snap data fialed
 type ErrDataCopyFailed struct {
 	OldPath  string
@@ -98,7 +98,10 @@ func gadgetConfig() error {
 		if !ok {
 			return errNoSnapToActivate
 		}
-		if err := snap.activate(false, pb); err != nil {
+		err := Audited(AuditSourceFirstBoot, 0, "activate", FullName(part), func() error {
+			return snap.activate(false, pb)
+		})
+		if err != nil {
 			logger.Noticef("failed to acitvate %s: %s", FullName(part), err)
 		}
 	}
@@ -117,7 +120,11 @@ func gadgetConfig() error {
 		}
 
 		overlord := newOverlord()
-		if _, err := overlord.Configure(snap.(*SnapPart), configData); err != nil {
+		err = Audited(AuditSourceFirstBoot, 0, "config", FullName(snap), func() error {
+			_, err := overlord.Configure(snap.(*SnapPart), configData)
+			return err
+		})
+		if err != nil {
 			return err
 		}
 	}
@@ -148,7 +155,10 @@ func enableSystemSnaps() error {
 		switch part.Type() {
 		case snap.TypeGadget, snap.TypeKernel, snap.TypeOS:
 			logger.Noticef("Acitvating %s", FullName(part))
-			if err := activator.SetActive(part.(*SnapPart), true, pb); err != nil {
+			err := Audited(AuditSourceFirstBoot, 0, "activate", FullName(part), func() error {
+				return activator.SetActive(part.(*SnapPart), true, pb)
+			})
+			if err != nil {
 				// we don't want this to fail for now
 				logger.Noticef("failed to acitvate %s: %s", FullName(part), err)
 			}
@@ -0,0 +1,315 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/osutil"
+	"github.com/ubuntu-core/snappy/progress"
+	"github.com/ubuntu-core/snappy/snap/squashfs"
+)
+
+// defaultGCKeep is the number of versions of a snap kept by default: the
+// active one and the one before it, to be able to roll back
+const defaultGCKeep = 2
+
+// defaultSnapshotExpiry is how long the data of purged snaps is kept
+const defaultSnapshotExpiry = 30 * 24 * time.Hour
+
+// GCRetention says how many versions of a snap the garbage collection
+// keeps around
+type GCRetention struct {
+	// Keep is the number of versions kept, counting the active one
+	Keep int `yaml:"keep,omitempty"`
+	// MinFreeSpace is the free disk space, in bytes, below which only
+	// the active version is kept
+	MinFreeSpace uint64 `yaml:"min-free-space,omitempty"`
+}
+
+// GCPolicy is the garbage collection policy of the system, as read from
+// dirs.SnapGCPolicyFile, with optional overrides per snap name.
+type GCPolicy struct {
+	GCRetention `yaml:",inline"`
+	Snaps       map[string]GCRetention `yaml:"snaps,omitempty"`
+	// SnapshotExpiry is how long the snapshot of the data of a purged
+	// snap is kept, as a duration like "72h"
+	SnapshotExpiry string `yaml:"snapshot-expiry,omitempty"`
+
+	snapshotExpiry time.Duration
+}
+
+// ReadGCPolicy reads the garbage collection policy of the system. Without
+// a policy file the active version and the previous one are kept.
+func ReadGCPolicy() (*GCPolicy, error) {
+	policy := &GCPolicy{}
+	content, err := ioutil.ReadFile(dirs.SnapGCPolicyFile)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, policy); err != nil {
+		return nil, fmt.Errorf("cannot read gc policy from %q: %v", dirs.SnapGCPolicyFile, err)
+	}
+
+	if policy.Keep == 0 {
+		policy.Keep = defaultGCKeep
+	}
+	if policy.Keep < 1 {
+		return nil, fmt.Errorf("cannot use gc policy from %q: keep must be at least 1", dirs.SnapGCPolicyFile)
+	}
+	for name, retention := range policy.Snaps {
+		if retention.Keep < 0 {
+			return nil, fmt.Errorf("cannot use gc policy from %q: keep of %s must be at least 1", dirs.SnapGCPolicyFile, name)
+		}
+	}
+
+	policy.snapshotExpiry = defaultSnapshotExpiry
+	if policy.SnapshotExpiry != "" {
+		expiry, err := time.ParseDuration(policy.SnapshotExpiry)
+		if err != nil || expiry <= 0 {
+			return nil, fmt.Errorf("cannot use gc policy from %q: invalid snapshot-expiry %q", dirs.SnapGCPolicyFile, policy.SnapshotExpiry)
+		}
+		policy.snapshotExpiry = expiry
+	}
+
+	return policy, nil
+}
+
+// retention returns the retention of the given snap, taking the overrides
+// into account
+func (p *GCPolicy) retention(name string) GCRetention {
+	retention := p.GCRetention
+	if override, ok := p.Snaps[name]; ok {
+		if override.Keep != 0 {
+			retention.Keep = override.Keep
+		}
+		if override.MinFreeSpace != 0 {
+			retention.MinFreeSpace = override.MinFreeSpace
+		}
+	}
+
+	return retention
+}
+
+var freeSpace = osutil.FreeSpace
+
+// keep returns the number of versions of the given snap to keep right now
+func (p *GCPolicy) keep(name string) (int, error) {
+	retention := p.retention(name)
+	if retention.MinFreeSpace == 0 {
+		return retention.Keep, nil
+	}
+
+	free, err := freeSpace(dirs.SnapSnapsDir)
+	if err != nil {
+		return 0, err
+	}
+	if free < retention.MinFreeSpace {
+		return 1, nil
+	}
+
+	return retention.Keep, nil
+}
+
+// GCFlags can be used to pass additional flags to CollectGarbage
+type GCFlags uint
+
+const (
+	// DoGCDryRun only reports the versions that would be removed
+	DoGCDryRun GCFlags = 1 << iota
+)
+
+// GCRemoval is a version of a snap removed by the garbage collection
+type GCRemoval struct {
+	Name    string `json:"name"`
+	Origin  string `json:"origin"`
+	Version string `json:"version"`
+	Size    int64  `json:"size"`
+}
+
+// GCReport reports what the garbage collection removed, or would have
+// removed on a dry run
+type GCReport struct {
+	DryRun    bool        `json:"dry-run"`
+	Removed   []GCRemoval `json:"removed"`
+	Reclaimed int64       `json:"reclaimed"`
+	// ExpiredSnapshots are the ids of the expired snapshots of the
+	// data of purged snaps that were removed
+	ExpiredSnapshots []int `json:"expired-snapshots,omitempty"`
+	// CachedDownloads are the file names of the downloaded snaps that
+	// were removed from the download cache
+	CachedDownloads []string `json:"cached-downloads,omitempty"`
+}
+
+// CollectGarbage removes the old versions of the given snaps, or of all
+// installed snaps if none is given, that the GC policy does not keep.
+func CollectGarbage(names []string, flags GCFlags, pb progress.Meter) (*GCReport, error) {
+	policy, err := ReadGCPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	installed, err := NewLocalSnapRepository().Installed()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		seen := make(map[string]bool)
+		for _, part := range installed {
+			name := part.Name() + "." + part.Origin()
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+	}
+
+	report := &GCReport{
+		DryRun:  flags&DoGCDryRun != 0,
+		Removed: []GCRemoval{},
+	}
+	for _, name := range names {
+		if err := collectGarbage(name, installed, policy, report, pb); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, name := range names {
+		if err := collectDownloadCache(name, report); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, snapshot := range expireSnapshots(report.DryRun) {
+		report.ExpiredSnapshots = append(report.ExpiredSnapshots, snapshot.ID)
+		report.Reclaimed += snapshot.Size
+	}
+
+	return report, nil
+}
+
+// collectGarbage removes the versions of the named snap older than the
+// ones the policy keeps, as long as NeedsReboot() is false on all of them,
+// and adds them to the report.
+func collectGarbage(name string, installed []Part, policy *GCPolicy, report *GCReport, pb progress.Meter) error {
+	parts := BySnapVersion(FindSnapsByName(name, installed))
+	if len(parts) < 2 {
+		// nothing to collect
+		return nil
+	}
+
+	sort.Sort(parts)
+	active := -1 // active is the index of the active part in parts (-1 if no active part)
+
+	for i, part := range parts {
+		if part.IsActive() {
+			if active > -1 {
+				return ErrGarbageCollectImpossible("more than one active (should not happen).")
+			}
+			active = i
+		}
+		if part.NeedsReboot() {
+			return nil // don't do gc on parts that need reboot.
+		}
+	}
+
+	if active < 1 {
+		// how was this an install?
+		return nil
+	}
+
+	keep, err := policy.keep(parts[active].Name())
+	if err != nil {
+		return err
+	}
+	first := active - keep + 1
+	if first <= 0 {
+		return nil
+	}
+
+	for _, part := range parts[:first] {
+		removal := GCRemoval{
+			Name:    part.Name(),
+			Origin:  part.Origin(),
+			Version: part.Version(),
+			Size:    gcSize(part.(*SnapPart)),
+		}
+		if !report.DryRun {
+			if err := (&Overlord{}).Uninstall(part.(*SnapPart), pb); err != nil {
+				return ErrGarbageCollectImpossible(err.Error())
+			}
+		}
+		report.Removed = append(report.Removed, removal)
+		report.Reclaimed += removal.Size
+	}
+
+	return nil
+}
+
+// gcSize returns the disk space freed by removing the given snap version
+func gcSize(part *SnapPart) int64 {
+	if fi, err := os.Stat(squashfs.BlobPath(part.basedir)); err == nil {
+		return fi.Size()
+	}
+
+	return part.InstalledSize()
+}
+
+// collectDownloadCache removes the downloaded revisions of the snap with
+// the given name, that may be qualified with an origin, from the download
+// cache, and adds them to the report. Once installed a snap does not need
+// them, and they are only kept to not download them again if the install
+// fails. Partial downloads are left alone to be resumed.
+func collectDownloadCache(name string, report *GCReport) error {
+	entries, err := downloadCacheEntries(name)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry, ".snap") {
+			continue
+		}
+		st, err := os.Stat(entry)
+		if err != nil {
+			continue
+		}
+		if !report.DryRun {
+			if err := os.Remove(entry); err != nil {
+				return err
+			}
+		}
+		report.CachedDownloads = append(report.CachedDownloads, filepath.Base(entry))
+		report.Reclaimed += st.Size()
+	}
+
+	return nil
+}
@@ -0,0 +1,170 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/progress"
+)
+
+func writeGCPolicy(c *C, policy string) {
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapGCPolicyFile), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(dirs.SnapGCPolicyFile, []byte(policy), 0644), IsNil)
+}
+
+// installedFoo returns how many versions of foo are installed
+func installedFoo(c *C) int {
+	installed, err := NewLocalSnapRepository().Installed()
+	c.Assert(err, IsNil)
+	return len(FindSnapsByName("foo", installed))
+}
+
+func (s *SnapTestSuite) TestReadGCPolicyDefault(c *C) {
+	policy, err := ReadGCPolicy()
+	c.Assert(err, IsNil)
+	c.Check(policy.Keep, Equals, 2)
+	c.Check(policy.MinFreeSpace, Equals, uint64(0))
+	c.Check(policy.snapshotExpiry, Equals, 30*24*time.Hour)
+}
+
+func (s *SnapTestSuite) TestReadGCPolicySnapshotExpiry(c *C) {
+	writeGCPolicy(c, "snapshot-expiry: 72h\n")
+	policy, err := ReadGCPolicy()
+	c.Assert(err, IsNil)
+	c.Check(policy.snapshotExpiry, Equals, 72*time.Hour)
+
+	writeGCPolicy(c, "snapshot-expiry: 3 days\n")
+	_, err = ReadGCPolicy()
+	c.Check(err, ErrorMatches, `cannot use gc policy from ".*": invalid snapshot-expiry "3 days"`)
+}
+
+func (s *SnapTestSuite) TestReadGCPolicyOverrides(c *C) {
+	writeGCPolicy(c, "keep: 3\nmin-free-space: 1000\nsnaps:\n  foo:\n    keep: 1\n")
+
+	policy, err := ReadGCPolicy()
+	c.Assert(err, IsNil)
+	c.Check(policy.retention("foo"), Equals, GCRetention{Keep: 1, MinFreeSpace: 1000})
+	c.Check(policy.retention("bar"), Equals, GCRetention{Keep: 3, MinFreeSpace: 1000})
+}
+
+func (s *SnapTestSuite) TestReadGCPolicyInvalid(c *C) {
+	writeGCPolicy(c, "keep: -1\n")
+	_, err := ReadGCPolicy()
+	c.Check(err, ErrorMatches, `cannot use gc policy from ".*": keep must be at least 1`)
+
+	writeGCPolicy(c, "snaps:\n  foo:\n    keep: -1\n")
+	_, err = ReadGCPolicy()
+	c.Check(err, ErrorMatches, `cannot use gc policy from ".*": keep of foo must be at least 1`)
+
+	writeGCPolicy(c, "keep: [\n")
+	_, err = ReadGCPolicy()
+	c.Check(err, ErrorMatches, `cannot read gc policy from ".*": .*`)
+}
+
+func (s *SnapTestSuite) TestCollectGarbageDryRun(c *C) {
+	s.installThree(c, AllowUnauthenticated)
+
+	report, err := CollectGarbage(nil, DoGCDryRun, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(report.DryRun, Equals, true)
+	c.Assert(report.Removed, HasLen, 1)
+	c.Check(report.Removed[0].Name, Equals, "foo")
+	c.Check(report.Removed[0].Version, Not(Equals), ActiveSnapByName("foo").Version())
+	c.Check(report.Removed[0].Size > 0, Equals, true)
+	c.Check(report.Reclaimed, Equals, report.Removed[0].Size)
+
+	// nothing got removed
+	c.Check(installedFoo(c), Equals, 3)
+}
+
+func (s *SnapTestSuite) TestCollectGarbage(c *C) {
+	s.installThree(c, AllowUnauthenticated)
+
+	report, err := CollectGarbage([]string{"foo"}, 0, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(report.DryRun, Equals, false)
+	c.Check(report.Removed, HasLen, 1)
+	c.Check(installedFoo(c), Equals, 2)
+}
+
+func (s *SnapTestSuite) TestCollectGarbagePerSnapKeep(c *C) {
+	s.installThree(c, AllowUnauthenticated)
+	writeGCPolicy(c, "keep: 1\nsnaps:\n  foo:\n    keep: 3\n")
+
+	report, err := CollectGarbage(nil, 0, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(report.Removed, HasLen, 0)
+	c.Check(report.Reclaimed, Equals, int64(0))
+	c.Check(installedFoo(c), Equals, 3)
+}
+
+func (s *SnapTestSuite) TestCollectGarbageLowSpace(c *C) {
+	s.installThree(c, AllowUnauthenticated)
+	writeGCPolicy(c, "min-free-space: 1000\n")
+
+	origFreeSpace := freeSpace
+	defer func() { freeSpace = origFreeSpace }()
+	freeSpace = func(path string) (uint64, error) {
+		c.Check(path, Equals, dirs.SnapSnapsDir)
+		return 999, nil
+	}
+
+	report, err := CollectGarbage(nil, 0, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(report.Removed, HasLen, 2)
+	c.Check(installedFoo(c), Equals, 1)
+}
+
+func (s *SnapTestSuite) TestClickInstallGCPolicy(c *C) {
+	writeGCPolicy(c, "keep: 3\n")
+	s.installThree(c, AllowUnauthenticated|DoInstallGC)
+
+	c.Check(installedFoo(c), Equals, 3)
+}
+
+func (s *SnapTestSuite) TestCollectGarbageDownloadCache(c *C) {
+	s.installThree(c, AllowUnauthenticated)
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0755), IsNil)
+	cached := filepath.Join(dirs.SnapDownloadCacheDir, "foo.canonical_3.snap")
+	c.Assert(ioutil.WriteFile(cached, []byte("blob"), 0644), IsNil)
+	partial := filepath.Join(dirs.SnapDownloadCacheDir, "foo.canonical_4.snap.partial")
+	c.Assert(ioutil.WriteFile(partial, []byte("bl"), 0644), IsNil)
+
+	report, err := CollectGarbage([]string{"foo"}, DoGCDryRun, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(report.CachedDownloads, DeepEquals, []string{"foo.canonical_3.snap"})
+	c.Check(helpers.FileExists(cached), Equals, true)
+
+	report, err = CollectGarbage([]string{"foo"}, 0, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(report.CachedDownloads, DeepEquals, []string{"foo.canonical_3.snap"})
+	c.Check(report.Reclaimed, Equals, report.Removed[0].Size+4)
+	c.Check(helpers.FileExists(cached), Equals, false)
+	// partial downloads are kept to be resumed
+	c.Check(helpers.FileExists(partial), Equals, true)
+}
@@ -0,0 +1,176 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/progress"
+	"github.com/ubuntu-core/snappy/systemd"
+)
+
+// the name of the security profile the health check command runs under
+const healthCheckProfile = "snappy-health-check"
+
+// defaultHealthCheckGracePeriod is used if a snap has a health-check
+// without a grace-period
+const defaultHealthCheckGracePeriod = 30 * time.Second
+
+// how often the services are looked at during the grace period; can be
+// overridden by tests
+var healthCheckInterval = 2 * time.Second
+
+func verifyHealthCheckYaml(hc *HealthCheckYaml) error {
+	if err := verifyStructStringsAgainstWhitelist(*hc, servicesBinariesStringsWhitelist); err != nil {
+		return err
+	}
+	if err := verifyHealthCheckCommand(hc.Command); err != nil {
+		return err
+	}
+
+	_, err := hc.gracePeriod()
+	return err
+}
+
+// verifyHealthCheckCommand checks that the command is a file inside of
+// the snap; it is run without arguments
+func verifyHealthCheckCommand(command string) error {
+	if command == "" {
+		return nil
+	}
+
+	invalid := filepath.IsAbs(command) || strings.ContainsAny(command, " \t")
+	for _, elem := range strings.Split(command, "/") {
+		if elem == ".." {
+			invalid = true
+		}
+	}
+	if invalid {
+		return fmt.Errorf("invalid health-check command %q", command)
+	}
+
+	return nil
+}
+
+func (hc *HealthCheckYaml) gracePeriod() (time.Duration, error) {
+	if hc.GracePeriod == "" {
+		return defaultHealthCheckGracePeriod, nil
+	}
+
+	d, err := time.ParseDuration(hc.GracePeriod)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid health-check grace-period %q", hc.GracePeriod)
+	}
+
+	return d, nil
+}
+
+func hasHealthCheckCommand(m *snapYaml) bool {
+	return m.HealthCheck != nil && m.HealthCheck.Command != ""
+}
+
+// isServiceFailing tells whether systemd gave up on the service, or is
+// about to restart it after it died
+func isServiceFailing(status *systemd.ServiceStatus) bool {
+	return status.ActiveState == "failed" || status.SubState == "auto-restart"
+}
+
+// checkHealth checks the health of the given (just activated) part: its
+// services must keep running for the grace period of its health check,
+// and after that its health check command (if any) must succeed.
+func checkHealth(part *SnapPart, meter progress.Meter) error {
+	hc := part.m.HealthCheck
+	if hc == nil {
+		return nil
+	}
+
+	grace, err := hc.gracePeriod()
+	if err != nil {
+		return err
+	}
+
+	var services []string
+	for _, app := range part.Apps() {
+		if app.Daemon != "" {
+			services = append(services, filepath.Base(generateServiceFileName(part.m, app)))
+		}
+	}
+
+	sysd := systemd.New(dirs.GlobalRootDir, meter)
+	deadline := time.Now().Add(grace)
+	meter.Notify(fmt.Sprintf("Checking the health of %s for %s.", part.Name(), grace))
+	for {
+		for _, service := range services {
+			status, err := sysd.ServiceStatus(service)
+			if err != nil {
+				return err
+			}
+			if isServiceFailing(status) {
+				return fmt.Errorf("service %s is %s (%s)", service, status.ActiveState, status.SubState)
+			}
+		}
+
+		left := deadline.Sub(time.Now())
+		if left <= 0 {
+			break
+		}
+		if left > healthCheckInterval {
+			left = healthCheckInterval
+		}
+		time.Sleep(left)
+	}
+
+	if hc.Command == "" {
+		return nil
+	}
+
+	appArmorProfile := fmt.Sprintf("%s_%s_%s", QualifiedName(part), healthCheckProfile, part.Version())
+	return runHookScript(filepath.Join(part.basedir, hc.Command), appArmorProfile, makeSnapHookEnv(part))
+}
+
+// checkHealthOrRollback checks the health of the freshly installed part
+// and makes oldPart active again if that fails.
+func checkHealthOrRollback(part, oldPart *SnapPart, meter progress.Meter) error {
+	herr := checkHealth(part, meter)
+	if herr == nil {
+		return nil
+	}
+
+	fullName := QualifiedName(part)
+	logger.Noticef("%s %s failed its health check, rolling back to %s: %v", fullName, part.Version(), oldPart.Version(), herr)
+
+	err := &ErrHealthCheckFailed{
+		Snap:         fullName,
+		Version:      part.Version(),
+		Reason:       herr.Error(),
+		RolledBackTo: oldPart.Version(),
+	}
+	if _, rerr := Rollback(fullName, oldPart.Version(), meter); rerr != nil {
+		logger.Noticef("Failed to roll %s back to %s: %v", fullName, oldPart.Version(), rerr)
+		err.RollbackErr = rerr
+	}
+
+	return err
+}
@@ -0,0 +1,154 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/systemd"
+)
+
+const healthSnapYaml = `name: foo
+apps:
+ svc:
+   command: bin/hello
+   daemon: simple
+`
+
+// mockFailingService makes systemd report the given service as failed
+// once it has been asked about it "after" times
+func mockFailingService(service string, after int) *int {
+	asked := 0
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		if cmd[0] == "show" && cmd[len(cmd)-1] == service && strings.HasPrefix(cmd[1], "--property=Id,") {
+			asked++
+			if asked > after {
+				return []byte("ActiveState=failed\nSubState=failed\n"), nil
+			}
+			return []byte("ActiveState=active\nSubState=running\n"), nil
+		}
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	return &asked
+}
+
+func (s *SnapTestSuite) installHealthSnap(c *C, version, healthCheck string) error {
+	snapFile := makeTestSnapPackage(c, healthSnapYaml+"version: "+version+"\n"+healthCheck)
+	_, err := installClick(snapFile, AllowUnauthenticated, &MockProgressMeter{}, testOrigin)
+	return err
+}
+
+func (s *SnapTestSuite) currentVersion(c *C) string {
+	current, err := os.Readlink(filepath.Join(dirs.SnapSnapsDir, "foo."+testOrigin, "current"))
+	c.Assert(err, IsNil)
+	return current
+}
+
+func (s *SnapTestSuite) TestInstallHealthCheckServiceFailsRollsBack(c *C) {
+	c.Assert(s.installHealthSnap(c, "1.0", ""), IsNil)
+
+	mockFailingService("foo_svc_2.0.service", 0)
+	err := s.installHealthSnap(c, "2.0", "health-check:\n grace-period: 0s\n")
+	c.Assert(err, DeepEquals, &ErrHealthCheckFailed{
+		Snap:         "foo." + testOrigin,
+		Version:      "2.0",
+		Reason:       "service foo_svc_2.0.service is failed (failed)",
+		RolledBackTo: "1.0",
+	})
+	c.Check(err, ErrorMatches, `foo.* 2.0 failed its health check \(service foo_svc_2.0.service is failed \(failed\)\), rolled back to 1.0`)
+
+	// the old version is active again, the new one is kept around
+	c.Check(s.currentVersion(c), Equals, "1.0")
+	c.Check(helpers.IsDirectory(filepath.Join(dirs.SnapSnapsDir, "foo."+testOrigin, "2.0")), Equals, true)
+}
+
+func (s *SnapTestSuite) TestInstallHealthCheckServiceFailsDuringGracePeriod(c *C) {
+	healthCheckInterval = time.Millisecond
+	c.Assert(s.installHealthSnap(c, "1.0", ""), IsNil)
+
+	asked := mockFailingService("foo_svc_2.0.service", 3)
+	err := s.installHealthSnap(c, "2.0", "health-check:\n grace-period: 10s\n")
+	c.Assert(err, FitsTypeOf, &ErrHealthCheckFailed{})
+	c.Check(*asked, Equals, 4)
+	c.Check(s.currentVersion(c), Equals, "1.0")
+}
+
+func (s *SnapTestSuite) TestInstallHealthCheckPasses(c *C) {
+	healthCheckInterval = time.Millisecond
+	c.Assert(s.installHealthSnap(c, "1.0", ""), IsNil)
+
+	asked := mockFailingService("foo_svc_2.0.service", 1000)
+	var calls []mockHookRun
+	s.mockRunHookScript(c, &calls, nil)
+
+	err := s.installHealthSnap(c, "2.0", "health-check:\n command: bin/check\n grace-period: 10ms\n")
+	c.Assert(err, IsNil)
+	c.Check(*asked > 1, Equals, true)
+	c.Check(s.currentVersion(c), Equals, "2.0")
+
+	c.Assert(calls, HasLen, 1)
+	c.Check(calls[0].hookScript, Equals, filepath.Join(dirs.SnapSnapsDir, "foo."+testOrigin, "2.0", "bin", "check"))
+	c.Check(calls[0].appArmorProfile, Equals, "foo."+testOrigin+"_snappy-health-check_2.0")
+	c.Check(helpers.FileExists(filepath.Join(dirs.SnapAppArmorDir, "foo."+testOrigin+"_snappy-health-check_2.0")), Equals, true)
+}
+
+func (s *SnapTestSuite) TestInstallHealthCheckCommandFailsRollsBack(c *C) {
+	c.Assert(s.installHealthSnap(c, "1.0", ""), IsNil)
+
+	var calls []mockHookRun
+	s.mockRunHookScript(c, &calls, &ErrHookFailed{Cmd: "bin/check", Output: "not ready", ExitCode: 1})
+
+	err := s.installHealthSnap(c, "2.0", "health-check:\n command: bin/check\n grace-period: 0s\n")
+	c.Assert(err, FitsTypeOf, &ErrHealthCheckFailed{})
+	c.Check(err.(*ErrHealthCheckFailed).Reason, Equals, `hook command bin/check failed with exit status 1 (output: "not ready")`)
+	c.Check(calls, HasLen, 1)
+	c.Check(s.currentVersion(c), Equals, "1.0")
+}
+
+func (s *SnapTestSuite) TestInstallHealthCheckNotOnFirstInstall(c *C) {
+	mockFailingService("foo_svc_1.0.service", 0)
+
+	err := s.installHealthSnap(c, "1.0", "health-check:\n grace-period: 0s\n")
+	c.Assert(err, IsNil)
+	c.Check(s.currentVersion(c), Equals, "1.0")
+}
+
+func (s *SnapTestSuite) TestHealthCheckInvalidGracePeriod(c *C) {
+	_, err := parseSnapYamlData([]byte(healthSnapYaml+"version: 1.0\nhealth-check:\n grace-period: forever\n"), false)
+	c.Assert(err, ErrorMatches, `invalid health-check grace-period "forever"`)
+}
+
+func (s *SnapTestSuite) TestHealthCheckCommandInvalid(c *C) {
+	for _, command := range []string{"/bin/sh", "../../../bin/sh", "bin/../../x", "bin/check --quick"} {
+		_, err := parseSnapYamlData([]byte(healthSnapYaml+"version: 1.0\nhealth-check:\n command: "+command+"\n"), false)
+		c.Check(err, ErrorMatches, `invalid health-check command ".*"`, Commentf(command))
+	}
+
+	_, err := parseSnapYamlData([]byte(healthSnapYaml+"version: 1.0\nhealth-check:\n command: bin/check..sh\n"), false)
+	c.Check(err, IsNil)
+}
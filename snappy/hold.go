@@ -0,0 +1,205 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/logger"
+)
+
+// Hold keeps a snap from being updated, until the given time if there is
+// one. If it has a Version the snap is pinned to that version instead:
+// only updates to it are allowed.
+type Hold struct {
+	Snap    string     `json:"snap"`
+	Until   *time.Time `json:"until,omitempty"`
+	Version string     `json:"version,omitempty"`
+}
+
+// Blocks returns whether the hold keeps the snap from being updated to
+// the given version
+func (h *Hold) Blocks(version string) bool {
+	return h.Version == "" || h.Version != version
+}
+
+// Expired returns whether the hold no longer applies
+func (h *Hold) Expired() bool {
+	return h.Until != nil && !time.Now().Before(*h.Until)
+}
+
+func (h *Hold) String() string {
+	s := "held"
+	if h.Version != "" {
+		s = fmt.Sprintf("pinned to %s", h.Version)
+	}
+	if h.Until != nil {
+		s += fmt.Sprintf(" until %s", h.Until.Format(time.RFC3339))
+	}
+
+	return s
+}
+
+// holdPath returns the path of the file that records the hold of the
+// given snap
+func holdPath(qualifiedName string) string {
+	return filepath.Join(dirs.SnapMetaDir, qualifiedName+".hold")
+}
+
+// readHold reads the hold at the given path
+func readHold(path string) (*Hold, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var hold Hold
+	if err := json.Unmarshal(content, &hold); err != nil {
+		return nil, fmt.Errorf("cannot read hold from %q: %v", path, err)
+	}
+
+	return &hold, nil
+}
+
+// SnapHold returns the hold of the snap with the given qualified name, or
+// nil if it is not held. An expired hold is removed.
+func SnapHold(qualifiedName string) *Hold {
+	hold, err := readHold(holdPath(qualifiedName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Noticef("Ignoring the hold of %s: %v", qualifiedName, err)
+		}
+		return nil
+	}
+	if hold.Expired() {
+		if err := removeHold(qualifiedName); err != nil {
+			logger.Noticef("Cannot remove the expired hold of %s: %v", qualifiedName, err)
+		}
+		return nil
+	}
+
+	return hold
+}
+
+// Holds returns the holds of all the snaps that are held
+func Holds() ([]*Hold, error) {
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapMetaDir, "*.hold"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	holds := make([]*Hold, 0, len(matches))
+	for _, match := range matches {
+		hold := SnapHold(strings.TrimSuffix(filepath.Base(match), ".hold"))
+		if hold != nil {
+			holds = append(holds, hold)
+		}
+	}
+
+	return holds, nil
+}
+
+// installedQualifiedName returns the qualified name of the installed snap
+// with the given name
+func installedQualifiedName(name string) (string, error) {
+	installed, err := NewLocalSnapRepository().Installed()
+	if err != nil {
+		return "", err
+	}
+	parts := FindSnapsByName(name, installed)
+	if len(parts) == 0 {
+		return "", ErrNotInstalled
+	}
+
+	return QualifiedName(parts[0]), nil
+}
+
+// SetHold holds the installed snap with the given name, until the given
+// time if it is not nil. If version is not empty the snap is pinned to
+// that version instead. An existing hold of the snap is replaced.
+func SetHold(name string, until *time.Time, version string) (*Hold, error) {
+	qn, err := installedQualifiedName(name)
+	if err != nil {
+		return nil, err
+	}
+	if until != nil && !until.After(time.Now()) {
+		return nil, fmt.Errorf("cannot hold %s until %s: that is in the past", qn, until.Format(time.RFC3339))
+	}
+
+	hold := &Hold{Snap: qn, Until: until, Version: version}
+	content, err := json.Marshal(hold)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dirs.SnapMetaDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := helpers.AtomicWriteFile(holdPath(qn), content, 0644, 0); err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// RemoveHold removes the hold of the installed snap with the given name
+func RemoveHold(name string) error {
+	qn, err := installedQualifiedName(name)
+	if err != nil {
+		return err
+	}
+
+	return removeHold(qn)
+}
+
+// removeHold removes the hold of the snap with the given qualified name,
+// if it has one
+func removeHold(qualifiedName string) error {
+	if err := os.Remove(holdPath(qualifiedName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// withoutHeldUpdates returns the given updates except the ones that are
+// held
+func withoutHeldUpdates(updates []Part) []Part {
+	allowed := make([]Part, 0, len(updates))
+	for _, upd := range updates {
+		if hold := SnapHold(QualifiedName(upd)); hold != nil && hold.Blocks(upd.Version()) {
+			logger.Noticef("Not updating %s to %s: it is %s", QualifiedName(upd), upd.Version(), hold)
+			continue
+		}
+		allowed = append(allowed, upd)
+	}
+
+	return allowed
+}
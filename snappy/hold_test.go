@@ -0,0 +1,227 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/progress"
+	"github.com/ubuntu-core/snappy/snap"
+)
+
+func (s *SnapTestSuite) makeInstalledFoo(c *C) {
+	yamlPath, err := s.makeInstalledMockSnap("name: foo\nversion: 1")
+	c.Assert(err, IsNil)
+	makeSnapActive(yamlPath)
+}
+
+func (s *SnapTestSuite) TestSetHold(c *C) {
+	s.makeInstalledFoo(c)
+
+	c.Check(SnapHold("foo."+testOrigin), IsNil)
+
+	hold, err := SetHold("foo", nil, "")
+	c.Assert(err, IsNil)
+	c.Check(hold, DeepEquals, &Hold{Snap: "foo." + testOrigin})
+	c.Check(SnapHold("foo."+testOrigin), DeepEquals, hold)
+
+	holds, err := Holds()
+	c.Assert(err, IsNil)
+	c.Check(holds, DeepEquals, []*Hold{hold})
+
+	// a new hold replaces the old one
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	hold, err = SetHold("foo."+testOrigin, &until, "2")
+	c.Assert(err, IsNil)
+	c.Check(SnapHold("foo."+testOrigin), DeepEquals, hold)
+
+	c.Assert(RemoveHold("foo"), IsNil)
+	c.Check(SnapHold("foo."+testOrigin), IsNil)
+	holds, err = Holds()
+	c.Assert(err, IsNil)
+	c.Check(holds, HasLen, 0)
+
+	// removing it again is fine
+	c.Check(RemoveHold("foo"), IsNil)
+}
+
+func (s *SnapTestSuite) TestSetHoldErrors(c *C) {
+	_, err := SetHold("foo", nil, "")
+	c.Check(err, Equals, ErrNotInstalled)
+	c.Check(RemoveHold("foo"), Equals, ErrNotInstalled)
+
+	s.makeInstalledFoo(c)
+	past := time.Now().Add(-time.Hour)
+	_, err = SetHold("foo", &past, "")
+	c.Check(err, ErrorMatches, `cannot hold foo\..* until .*: that is in the past`)
+}
+
+func (s *SnapTestSuite) TestHoldExpires(c *C) {
+	s.makeInstalledFoo(c)
+
+	until := time.Now().Add(time.Hour)
+	_, err := SetHold("foo", &until, "")
+	c.Assert(err, IsNil)
+
+	// an expired hold is ignored, and removed
+	c.Assert(ioutil.WriteFile(holdPath("foo."+testOrigin), []byte(`{"snap": "foo.`+testOrigin+`", "until": "2016-01-01T00:00:00Z"}`), 0644), IsNil)
+	c.Check(SnapHold("foo."+testOrigin), IsNil)
+	c.Check(helpers.FileExists(holdPath("foo."+testOrigin)), Equals, false)
+
+	// a broken hold file is ignored
+	c.Assert(ioutil.WriteFile(holdPath("foo."+testOrigin), []byte(`{`), 0644), IsNil)
+	c.Check(SnapHold("foo."+testOrigin), IsNil)
+}
+
+func (s *SnapTestSuite) TestSnapRemoveDropsHold(c *C) {
+	makeTwoTestSnaps(c, snap.TypeApp)
+	_, err := SetHold("foo", nil, "")
+	c.Assert(err, IsNil)
+
+	// a version is left, so is the hold
+	c.Assert(Remove("foo=1.0", 0, &progress.NullProgress{}), IsNil)
+	c.Check(SnapHold("foo."+testOrigin), NotNil)
+
+	c.Assert(Remove("foo", 0, &progress.NullProgress{}), IsNil)
+	c.Check(helpers.FileExists(holdPath("foo."+testOrigin)), Equals, false)
+}
+
+func (s *SnapTestSuite) TestHoldBlocks(c *C) {
+	hold := &Hold{Snap: "foo.bar"}
+	c.Check(hold.Blocks("2"), Equals, true)
+	c.Check(hold.String(), Equals, "held")
+
+	until := time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC)
+	hold = &Hold{Snap: "foo.bar", Version: "2", Until: &until}
+	c.Check(hold.Blocks("2"), Equals, false)
+	c.Check(hold.Blocks("3"), Equals, true)
+	c.Check(hold.String(), Equals, "pinned to 2 until 2016-05-01T00:00:00Z")
+}
+
+func (s *SnapTestSuite) TestErrSnapHeld(c *C) {
+	err := &ErrSnapHeld{Hold: &Hold{Snap: "foo.bar", Version: "1"}}
+	c.Check(err, ErrorMatches, "cannot update foo.bar: it is pinned to 1")
+}
+
+// mockUpdatesServer makes the store offer version 2 of the installed
+// foo and bar
+func (s *SnapTestSuite) mockUpdatesServer(c *C) *httptest.Server {
+	for _, name := range []string{"foo", "bar"} {
+		yamlPath, err := s.makeInstalledMockSnap("name: " + name + "\nversion: 1")
+		c.Assert(err, IsNil)
+		makeSnapActive(yamlPath)
+	}
+
+	snaps := map[string]string{
+		"foo": makeTestSnapPackage(c, "name: foo\nversion: 2"),
+		"bar": makeTestSnapPackage(c, "name: bar\nversion: 2"),
+	}
+
+	var dlURL string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/updates/":
+			io.WriteString(w, `[{
+	"package_name": "foo",
+	"version": "2",
+	"origin": "`+testOrigin+`",
+	"anon_download_url": "`+dlURL+`/foo"
+}, {
+	"package_name": "bar",
+	"version": "2",
+	"origin": "`+testOrigin+`",
+	"anon_download_url": "`+dlURL+`/bar"
+}]`)
+		case "/dl/foo", "/dl/bar":
+			f, err := os.Open(snaps[r.URL.Path[len("/dl/"):]])
+			c.Assert(err, IsNil)
+			defer f.Close()
+			io.Copy(w, f)
+		default:
+			panic("unexpected url path: " + r.URL.Path)
+		}
+	}))
+	c.Assert(mockServer, NotNil)
+
+	dlURL = mockServer.URL + "/dl"
+	var err error
+	storeBulkURI, err = url.Parse(mockServer.URL + "/updates/")
+	c.Assert(err, IsNil)
+
+	return mockServer
+}
+
+func (s *SnapTestSuite) TestUpdateAllSkipsHeld(c *C) {
+	mockServer := s.mockUpdatesServer(c)
+	defer mockServer.Close()
+
+	_, err := SetHold("bar", nil, "")
+	c.Assert(err, IsNil)
+
+	updates, err := UpdateAll(0, &MockProgressMeter{})
+	c.Assert(err, IsNil)
+	c.Assert(updates, HasLen, 1)
+	c.Check(updates[0].Name(), Equals, "foo")
+
+	c.Check(ActiveSnapByName("foo").Version(), Equals, "2")
+	c.Check(ActiveSnapByName("bar").Version(), Equals, "1")
+}
+
+func (s *SnapTestSuite) TestUpdateAllPinned(c *C) {
+	mockServer := s.mockUpdatesServer(c)
+	defer mockServer.Close()
+
+	_, err := SetHold("foo", nil, "2")
+	c.Assert(err, IsNil)
+	_, err = SetHold("bar", nil, "3")
+	c.Assert(err, IsNil)
+
+	updates, err := UpdateAll(0, &MockProgressMeter{})
+	c.Assert(err, IsNil)
+	c.Assert(updates, HasLen, 1)
+	c.Check(updates[0].Name(), Equals, "foo")
+	c.Check(ActiveSnapByName("bar").Version(), Equals, "1")
+}
+
+func (s *SnapTestSuite) TestUpdateHeld(c *C) {
+	mockServer := s.mockUpdatesServer(c)
+	defer mockServer.Close()
+
+	_, err := SetHold("foo", nil, "")
+	c.Assert(err, IsNil)
+
+	_, err = Update("foo", "", 0, &progress.NullProgress{})
+	c.Assert(err, FitsTypeOf, &ErrSnapHeld{})
+	c.Check(ActiveSnapByName("foo").Version(), Equals, "1")
+
+	c.Assert(RemoveHold("foo"), IsNil)
+	_, err = Update("foo", "", 0, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(ActiveSnapByName("foo").Version(), Equals, "2")
+}
@@ -0,0 +1,105 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/logger"
+)
+
+// the name of the security profile the post-refresh hook runs under
+const postRefreshHookProfile = "snappy-post-refresh"
+
+func postRefreshHookPath(baseDir string) string {
+	return filepath.Join(baseDir, "meta", "hooks", "post-refresh")
+}
+
+func hasPostRefreshHook(baseDir string) bool {
+	return helpers.FileExists(postRefreshHookPath(baseDir))
+}
+
+// hookProfiles returns the names of the profiles the hooks and the
+// health check command of the snap in baseDir run under
+func hookProfiles(m *snapYaml, baseDir string) []string {
+	var profiles []string
+	if hasPostRefreshHook(baseDir) {
+		profiles = append(profiles, postRefreshHookProfile)
+	}
+	if hasHealthCheckCommand(m) {
+		profiles = append(profiles, healthCheckProfile)
+	}
+
+	return profiles
+}
+
+// runPostRefreshHook runs the "meta/hooks/post-refresh" hook of the
+// given (not yet active) part, if it has one. The data of oldVersion
+// has already been copied to the data dirs of the part at this point
+// so the hook can migrate it.
+func runPostRefreshHook(part *SnapPart, oldVersion string) (err error) {
+	if !hasPostRefreshHook(part.basedir) {
+		return nil
+	}
+
+	// the profile is normally generated on activation, but the hook
+	// needs to run confined before that
+	if err := snappyConfig.generatePolicyForServiceBinary(part.m, postRefreshHookProfile, part.basedir); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if e := removeOneSecurityPolicy(part.m, postRefreshHookProfile, part.basedir); e != nil {
+				logger.Noticef("Failed to remove the %s policy of %s: %v", postRefreshHookProfile, part.Name(), e)
+			}
+		}
+	}()
+
+	appArmorProfile := fmt.Sprintf("%s_%s_%s", QualifiedName(part), postRefreshHookProfile, part.Version())
+	env := append(makeSnapHookEnv(part), "SNAP_PREVIOUS_VERSION="+oldVersion)
+
+	return runHookScript(postRefreshHookPath(part.basedir), appArmorProfile, env)
+}
+
+var runHookScript = runHookScriptImpl
+
+// runHookScript runs the given hook script confined by appArmorProfile
+// and turns a failure into an ErrHookFailed carrying the hook output
+func runHookScriptImpl(hookScript, appArmorProfile string, env []string) error {
+	cmd := exec.Command(aaExec, "-p", appArmorProfile, hookScript)
+	cmd.Env = env
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if exitCode, e := helpers.ExitCode(err); e == nil {
+			return &ErrHookFailed{
+				Cmd:      hookScript,
+				Output:   string(output),
+				ExitCode: exitCode,
+			}
+		}
+		return err
+	}
+
+	return nil
+}
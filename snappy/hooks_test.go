@@ -0,0 +1,155 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/testutil"
+)
+
+var postRefreshHookFiles = [][]string{
+	{"meta/hooks/post-refresh", "#!/bin/sh\nexit 0\n"},
+}
+
+type mockHookRun struct {
+	hookScript      string
+	appArmorProfile string
+	env             []string
+}
+
+func (s *SnapTestSuite) mockRunHookScript(c *C, calls *[]mockHookRun, hookErr error) {
+	runHookScript = func(hookScript, appArmorProfile string, env []string) error {
+		*calls = append(*calls, mockHookRun{hookScript, appArmorProfile, env})
+		return hookErr
+	}
+}
+
+func (s *SnapTestSuite) installFooWithData(c *C) string {
+	snapFile := makeTestSnapPackage(c, "name: foo\nversion: 1.0\n")
+	_, err := installClick(snapFile, AllowUnauthenticated, &MockProgressMeter{}, testOrigin)
+	c.Assert(err, IsNil)
+
+	canary := filepath.Join(dirs.SnapDataDir, "foo."+testOrigin, "1.0", "canary")
+	c.Assert(ioutil.WriteFile(canary, []byte("ni ni ni"), 0644), IsNil)
+
+	return canary
+}
+
+func (s *SnapTestSuite) TestInstallRunsPostRefreshHook(c *C) {
+	s.installFooWithData(c)
+
+	var calls []mockHookRun
+	runHookScript = func(hookScript, appArmorProfile string, env []string) error {
+		// the data is already copied when the hook runs
+		newCanary := filepath.Join(dirs.SnapDataDir, "foo."+testOrigin, "2.0", "canary")
+		c.Check(helpers.FileExists(newCanary), Equals, true)
+		calls = append(calls, mockHookRun{hookScript, appArmorProfile, env})
+		return nil
+	}
+
+	snapFile := makeTestSnapPackageWithFiles(c, "name: foo\nversion: 2.0\n", postRefreshHookFiles)
+	_, err := installClick(snapFile, AllowUnauthenticated, &MockProgressMeter{}, testOrigin)
+	c.Assert(err, IsNil)
+
+	c.Assert(calls, HasLen, 1)
+	c.Check(calls[0].hookScript, Equals, filepath.Join(dirs.SnapSnapsDir, "foo."+testOrigin, "2.0", "meta", "hooks", "post-refresh"))
+	c.Check(calls[0].appArmorProfile, Equals, "foo."+testOrigin+"_snappy-post-refresh_2.0")
+	c.Check(calls[0].env, testutil.Contains, "SNAP_PREVIOUS_VERSION=1.0")
+
+	// the hook profile stays around with the active version
+	c.Check(helpers.FileExists(filepath.Join(dirs.SnapAppArmorDir, "foo."+testOrigin+"_snappy-post-refresh_2.0")), Equals, true)
+}
+
+func (s *SnapTestSuite) TestInstallNoPostRefreshHookOnFirstInstall(c *C) {
+	var calls []mockHookRun
+	s.mockRunHookScript(c, &calls, nil)
+
+	snapFile := makeTestSnapPackageWithFiles(c, "name: foo\nversion: 1.0\n", postRefreshHookFiles)
+	_, err := installClick(snapFile, AllowUnauthenticated, &MockProgressMeter{}, testOrigin)
+	c.Assert(err, IsNil)
+
+	c.Check(calls, HasLen, 0)
+}
+
+func (s *SnapTestSuite) TestInstallPostRefreshHookFailureRollsBack(c *C) {
+	canary := s.installFooWithData(c)
+
+	var calls []mockHookRun
+	hookErr := &ErrHookFailed{Cmd: "post-refresh", Output: "boom", ExitCode: 1}
+	s.mockRunHookScript(c, &calls, hookErr)
+
+	snapFile := makeTestSnapPackageWithFiles(c, "name: foo\nversion: 2.0\n", postRefreshHookFiles)
+	_, err := installClick(snapFile, AllowUnauthenticated, &MockProgressMeter{}, testOrigin)
+	c.Assert(err, Equals, hookErr)
+	c.Check(calls, HasLen, 1)
+
+	// the old version is still active and its data untouched
+	current, err := os.Readlink(filepath.Join(dirs.SnapSnapsDir, "foo."+testOrigin, "current"))
+	c.Assert(err, IsNil)
+	c.Check(current, Equals, "1.0")
+	c.Check(helpers.FileExists(canary), Equals, true)
+
+	// and the new version is gone again
+	c.Check(helpers.FileExists(filepath.Join(dirs.SnapSnapsDir, "foo."+testOrigin, "2.0")), Equals, false)
+	c.Check(helpers.FileExists(filepath.Join(dirs.SnapDataDir, "foo."+testOrigin, "2.0")), Equals, false)
+	c.Check(helpers.FileExists(filepath.Join(dirs.SnapAppArmorDir, "foo."+testOrigin+"_snappy-post-refresh_2.0")), Equals, false)
+}
+
+func (s *SnapTestSuite) TestInstallPostRefreshHookInhibited(c *C) {
+	s.installFooWithData(c)
+
+	var calls []mockHookRun
+	s.mockRunHookScript(c, &calls, errors.New("must not run"))
+
+	snapFile := makeTestSnapPackageWithFiles(c, "name: foo\nversion: 2.0\n", postRefreshHookFiles)
+	_, err := installClick(snapFile, AllowUnauthenticated|InhibitHooks, &MockProgressMeter{}, testOrigin)
+	c.Assert(err, IsNil)
+	c.Check(calls, HasLen, 0)
+}
+
+func (s *SnapTestSuite) TestRunHookScriptFails(c *C) {
+	hook := filepath.Join(c.MkDir(), "post-refresh")
+	err := ioutil.WriteFile(hook, []byte("#!/bin/sh\necho cannot migrate\nexit 3\n"), 0755)
+	c.Assert(err, IsNil)
+
+	err = runHookScriptImpl(hook, "some-profile", os.Environ())
+	c.Assert(err, DeepEquals, &ErrHookFailed{
+		Cmd:      hook,
+		Output:   "cannot migrate\n",
+		ExitCode: 3,
+	})
+}
+
+func (s *SnapTestSuite) TestRunHookScriptWorks(c *C) {
+	hook := filepath.Join(c.MkDir(), "post-refresh")
+	err := ioutil.WriteFile(hook, []byte("#!/bin/sh\ntest \"$SNAP_PREVIOUS_VERSION\" = 1.0\n"), 0755)
+	c.Assert(err, IsNil)
+
+	err = runHookScriptImpl(hook, "some-profile", []string{"SNAP_PREVIOUS_VERSION=1.0"})
+	c.Assert(err, IsNil)
+}
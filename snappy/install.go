@@ -22,7 +22,6 @@ package snappy
 import (
 	"fmt"
 	"os"
-	"sort"
 
 	"github.com/ubuntu-core/snappy/logger"
 	"github.com/ubuntu-core/snappy/progress"
@@ -42,14 +41,22 @@ const (
 	DoInstallGC
 	// AllowGadget allows the installation of Gadget packages, this does not affect updates.
 	AllowGadget
+	// TransactionalUpdate makes UpdateAll download all updates before
+	// installing any, and roll back every updated snap if one fails
+	TransactionalUpdate
 )
 
-func installRemote(mStore *SnapUbuntuStoreRepository, remoteSnap *RemoteSnapPart, flags InstallFlags, meter progress.Meter) (string, error) {
+func installRemote(mStore StoreRepository, remoteSnap *RemoteSnapPart, flags InstallFlags, meter progress.Meter) (string, error) {
 	downloadedSnap, err := mStore.Download(remoteSnap, meter)
 	if err != nil {
 		return "", fmt.Errorf("cannot download %s: %s", remoteSnap.Name(), err)
 	}
-	defer os.Remove(downloadedSnap)
+	// NOTE: the downloaded snap is kept in the download cache so that
+	// installing the same revision again does not download it again
+
+	if err := addStoreAssertions(mStore, remoteSnap); err != nil {
+		return "", err
+	}
 
 	if err := remoteSnap.saveStoreManifest(); err != nil {
 		return "", err
@@ -60,10 +67,16 @@ func installRemote(mStore *SnapUbuntuStoreRepository, remoteSnap *RemoteSnapPart
 		return "", err
 	}
 
+	if channel := remoteSnap.Channel(); channel != "" {
+		if err := setTrackedChannel(QualifiedName(localSnap), channel); err != nil {
+			return "", err
+		}
+	}
+
 	return localSnap.Name(), nil
 }
 
-func doUpdate(mStore *SnapUbuntuStoreRepository, part Part, flags InstallFlags, meter progress.Meter) error {
+func doUpdate(mStore StoreRepository, part Part, flags InstallFlags, meter progress.Meter) error {
 	_, err := installRemote(mStore, part.(*RemoteSnapPart), flags, meter)
 	if err == ErrSideLoaded {
 		logger.Noticef("Skipping sideloaded package: %s", part.Name())
@@ -109,8 +122,10 @@ func convertToInstalledSnaps(remoteUpdates []Part) ([]Part, error) {
 	return installedUpdates, nil
 }
 
-// Update updates the selected name
-func Update(name string, flags InstallFlags, meter progress.Meter) ([]Part, error) {
+// Update updates the selected name. If a channel is given the snap is
+// switched to that channel and tracks it from now on. If the snap is held
+// an *ErrSnapHeld is returned.
+func Update(name, channel string, flags InstallFlags, meter progress.Meter) ([]Part, error) {
 	installed, err := NewLocalSnapRepository().Installed()
 	if err != nil {
 		return nil, err
@@ -120,16 +135,31 @@ func Update(name string, flags InstallFlags, meter progress.Meter) ([]Part, erro
 		return nil, ErrNotInstalled
 	}
 
-	mStore := NewUbuntuStoreSnapRepository()
-	// zomg :-(
-	// TODO: query the store for just this package, instead of this
-	updates, err := mStore.Updates()
-	if err != nil {
-		return nil, fmt.Errorf("cannot get updates: %s", err)
+	mStore := NewStoreRepository()
+	var upd []Part
+	if channel != "" && channel != cur[0].Channel() {
+		part, err := mStore.Snap(QualifiedName(cur[0]), channel)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find %q in channel %q: %s", name, channel, err)
+		}
+		if part.Version() == cur[0].Version() {
+			// nothing to install, just follow the new channel
+			return nil, setTrackedChannel(QualifiedName(cur[0]), channel)
+		}
+		upd = []Part{part}
+	} else {
+		part, err := mStore.SnapUpdate(cur[0])
+		if err != nil {
+			return nil, fmt.Errorf("cannot get updates: %s", err)
+		}
+		if part == nil {
+			return nil, fmt.Errorf("cannot find any update for %q", name)
+		}
+		upd = []Part{part}
 	}
-	upd := FindSnapsByName(QualifiedName(cur[0]), updates)
-	if len(upd) < 1 {
-		return nil, fmt.Errorf("cannot find any update for %q", name)
+
+	if hold := SnapHold(QualifiedName(cur[0])); hold != nil && hold.Blocks(upd[0].Version()) {
+		return nil, &ErrSnapHeld{Hold: hold}
 	}
 
 	if err := doUpdate(mStore, upd[0], flags, meter); err != nil {
@@ -146,19 +176,31 @@ func Update(name string, flags InstallFlags, meter progress.Meter) ([]Part, erro
 
 // UpdateAll the installed snappy packages, it returns the updated Parts
 // if updates where available and an error and nil if any of the updates
-// fail to apply.
+// fail to apply. Snaps that are held (see SetHold) are not updated.
+//
+// With TransactionalUpdate all updates are downloaded first and either all
+// of them are applied or none is: if one fails the snaps already updated
+// are rolled back to their previous version and an *ErrUpdateAllFailed
+// is returned.
 func UpdateAll(flags InstallFlags, meter progress.Meter) ([]Part, error) {
-	mStore := NewUbuntuStoreSnapRepository()
+	mStore := NewStoreRepository()
 	updates, err := mStore.Updates()
 	if err != nil {
 		return nil, err
 	}
+	updates = withoutHeldUpdates(updates)
 
-	for _, part := range updates {
-		meter.Notify(fmt.Sprintf("Updating %s (%s)", part.Name(), part.Version()))
-		if err := doUpdate(mStore, part, flags, meter); err != nil {
+	if flags&TransactionalUpdate != 0 {
+		if err := updateAllTransactional(mStore, updates, flags, meter); err != nil {
 			return nil, err
 		}
+	} else {
+		for _, part := range updates {
+			meter.Notify(fmt.Sprintf("Updating %s (%s)", part.Name(), part.Version()))
+			if err := doUpdate(mStore, part, flags, meter); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	installedUpdates, err := convertToInstalledSnaps(updates)
@@ -169,10 +211,82 @@ func UpdateAll(flags InstallFlags, meter progress.Meter) ([]Part, error) {
 	return installedUpdates, nil
 }
 
+// updatedSnap records the version a snap had before it got updated
+type updatedSnap struct {
+	name    string
+	version string
+}
+
+func updateAllTransactional(mStore StoreRepository, updates []Part, flags InstallFlags, meter progress.Meter) error {
+	// download everything first so that a failing download does not
+	// leave the system half updated; the blobs end up in the download
+	// cache and are reused by the install below
+	for _, part := range updates {
+		meter.Notify(fmt.Sprintf("Downloading %s (%s)", part.Name(), part.Version()))
+		if _, err := mStore.Download(part.(*RemoteSnapPart), meter); err != nil {
+			return fmt.Errorf("cannot download %s: %s", part.Name(), err)
+		}
+	}
+
+	var done []updatedSnap
+	for _, part := range updates {
+		prev := ActiveSnapByName(part.Name())
+		meter.Notify(fmt.Sprintf("Updating %s (%s)", part.Name(), part.Version()))
+		_, err := installRemote(mStore, part.(*RemoteSnapPart), flags, meter)
+		if err == ErrSideLoaded {
+			logger.Noticef("Skipping sideloaded package: %s", part.Name())
+			continue
+		}
+		if err != nil {
+			return rollbackUpdates(done, part.Name(), err, meter)
+		}
+		if prev != nil {
+			done = append(done, updatedSnap{name: part.Name(), version: prev.Version()})
+		}
+	}
+
+	// only clean up once all updates went through, the previous
+	// versions are needed for the rollback
+	for _, upd := range done {
+		if err := GarbageCollect(upd.name, flags, meter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollbackUpdates rolls the given updated snaps back to their previous
+// version, in the reverse order of their update
+func rollbackUpdates(done []updatedSnap, failed string, origErr error, meter progress.Meter) error {
+	report := &ErrUpdateAllFailed{
+		Snap:    failed,
+		OrigErr: origErr,
+	}
+
+	for i := len(done) - 1; i >= 0; i-- {
+		upd := done[i]
+		meter.Notify(fmt.Sprintf("Rolling back %s to %s", upd.name, upd.version))
+		if _, err := Rollback(upd.name, upd.version, meter); err != nil {
+			if report.RollbackErrs == nil {
+				report.RollbackErrs = make(map[string]error)
+			}
+			report.RollbackErrs[upd.name] = err
+			continue
+		}
+		report.RolledBack = append(report.RolledBack, upd.name)
+	}
+
+	return report
+}
+
 // Install the givens snap names provided via args. This can be local
-// files or snaps that are queried from the store
-func Install(name string, flags InstallFlags, meter progress.Meter) (string, error) {
-	name, err := doInstall(name, flags, meter)
+// files, http or https URLs (see InstallURL) or snaps that are queried
+// from the store. Snaps from the store are
+// installed from the given channel, or the default channel if it is empty,
+// and track that channel afterwards.
+func Install(name, channel string, flags InstallFlags, meter progress.Meter) (string, error) {
+	name, err := doInstall(name, channel, flags, meter)
 	if err != nil {
 		return "", err
 	}
@@ -180,7 +294,7 @@ func Install(name string, flags InstallFlags, meter progress.Meter) (string, err
 	return name, GarbageCollect(name, flags, meter)
 }
 
-func doInstall(name string, flags InstallFlags, meter progress.Meter) (snapName string, err error) {
+func doInstall(name, channel string, flags InstallFlags, meter progress.Meter) (snapName string, err error) {
 	defer func() {
 		if err != nil {
 			err = &ErrInstallFailed{Snap: name, OrigErr: err}
@@ -198,14 +312,18 @@ func doInstall(name string, flags InstallFlags, meter progress.Meter) (snapName
 		return installClick(name, flags, meter, SideloadedOrigin)
 	}
 
+	if IsSnapURL(name) {
+		return installURL(name, "", flags, meter)
+	}
+
 	// check repos next
-	mStore := NewUbuntuStoreSnapRepository()
+	mStore := NewStoreRepository()
 	installed, err := NewLocalSnapRepository().Installed()
 	if err != nil {
 		return "", err
 	}
 
-	part, err := mStore.Snap(name)
+	part, err := mStore.Snap(name, channel)
 	if err != nil {
 		return "", err
 	}
@@ -221,52 +339,33 @@ func doInstall(name string, flags InstallFlags, meter progress.Meter) (snapName
 	return installRemote(mStore, part, flags, meter)
 }
 
-// GarbageCollect removes all versions two older than the current active
-// version, as long as NeedsReboot() is false on all the versions found, and
-// DoInstallGC is set.
+// GarbageCollect removes the versions of the given snap that the GC
+// policy does not keep, as long as NeedsReboot() is false on all the
+// versions found, and its downloads, if DoInstallGC is set.
 func GarbageCollect(name string, flags InstallFlags, pb progress.Meter) error {
-	var parts BySnapVersion
-
 	if (flags & DoInstallGC) == 0 {
 		return nil
 	}
 
-	installed, err := NewLocalSnapRepository().Installed()
+	policy, err := ReadGCPolicy()
 	if err != nil {
 		return err
 	}
 
-	parts = FindSnapsByName(name, installed)
-	if len(parts) < 3 {
-		// not enough things installed to do gc
-		return nil
+	installed, err := NewLocalSnapRepository().Installed()
+	if err != nil {
+		return err
 	}
 
-	sort.Sort(parts)
-	active := -1 // active is the index of the active part in parts (-1 if no active part)
-
-	for i, part := range parts {
-		if part.IsActive() {
-			if active > -1 {
-				return ErrGarbageCollectImpossible("more than one active (should not happen).")
-			}
-			active = i
-		}
-		if part.NeedsReboot() {
-			return nil // don't do gc on parts that need reboot.
-		}
+	report := &GCReport{}
+	if err := collectGarbage(name, installed, policy, report, pb); err != nil {
+		return err
 	}
-
-	if active < 1 {
-		// how was this an install?
-		return nil
+	if err := collectDownloadCache(name, report); err != nil {
+		logger.Noticef("Cannot clean the download cache of %s: %v", name, err)
 	}
 
-	for _, part := range parts[:active-1] {
-		if err := (&Overlord{}).Uninstall(part.(*SnapPart), pb); err != nil {
-			return ErrGarbageCollectImpossible(err.Error())
-		}
-	}
+	expireSnapshots(false)
 
 	return nil
 }
@@ -0,0 +1,290 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/ubuntu-core/snappy/progress"
+)
+
+// The states a snap goes through in InstallMany
+const (
+	InstallManyPending     = "pending"
+	InstallManyDownloading = "downloading"
+	InstallManyInstalling  = "installing"
+	InstallManyInstalled   = "installed"
+	InstallManyFailed      = "failed"
+)
+
+// maxParallelDownloads is how many snaps InstallMany downloads at the
+// same time
+var maxParallelDownloads = 4
+
+// InstallManyResult is the state of one of the snaps of InstallMany
+type InstallManyResult struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ErrInstallManyFailed is returned by InstallMany if some of the snaps
+// could not be installed. The others are installed nevertheless.
+type ErrInstallManyFailed struct {
+	Results []InstallManyResult `json:"results"`
+}
+
+func (e *ErrInstallManyFailed) Error() string {
+	var failed []string
+	for _, res := range e.Results {
+		if res.Status == InstallManyFailed {
+			failed = append(failed, fmt.Sprintf("%s (%s)", res.Name, res.Error))
+		}
+	}
+
+	return fmt.Sprintf("cannot install %s", strings.Join(failed, ", "))
+}
+
+// installManyItem is one of the snaps of InstallMany
+type installManyItem struct {
+	InstallManyResult
+
+	part       *RemoteSnapPart
+	frameworks []string
+}
+
+// InstallMany installs the given snaps from the store in the given
+// channel. The snaps are downloaded concurrently, at most
+// maxParallelDownloads at a time, and then installed one after the other,
+// frameworks before the snaps in the list that need them.
+//
+// Every change of the state of a snap is passed to report, if it is not
+// nil. A snap that fails does not stop the others from being installed,
+// unless they need it; the returned error is then an ErrInstallManyFailed.
+// Each snap can only be given once.
+func InstallMany(names []string, channel string, flags InstallFlags, meter progress.Meter, report func(InstallManyResult)) ([]InstallManyResult, error) {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		bare, _ := SplitOrigin(name)
+		if seen[bare] {
+			return nil, fmt.Errorf("cannot install %q more than once", bare)
+		}
+		seen[bare] = true
+	}
+
+	var mu sync.Mutex
+	items := make([]*installManyItem, len(names))
+	update := func(item *installManyItem, status string, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		item.Status = status
+		if err != nil {
+			item.Error = err.Error()
+		}
+		if report != nil {
+			report(item.InstallManyResult)
+		}
+	}
+
+	for i, name := range names {
+		items[i] = &installManyItem{InstallManyResult: InstallManyResult{Name: name, Status: InstallManyPending}}
+	}
+
+	mStore := NewStoreRepository()
+	installed, err := NewLocalSnapRepository().Installed()
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxParallelDownloads)
+	for _, item := range items {
+		part, err := findInstallable(mStore, item.Name, channel, installed)
+		if err != nil {
+			update(item, InstallManyFailed, err)
+			continue
+		}
+		item.part = part
+		item.Version = part.Version()
+
+		wg.Add(1)
+		go func(item *installManyItem) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			update(item, InstallManyDownloading, nil)
+			if err := item.download(mStore); err != nil {
+				update(item, InstallManyFailed, err)
+			}
+		}(item)
+	}
+	wg.Wait()
+
+	for _, item := range orderForInstall(items) {
+		if item.Status == InstallManyFailed {
+			continue
+		}
+		if dep := item.failedDependency(items); dep != "" {
+			update(item, InstallManyFailed, fmt.Errorf("it needs %s, which failed to install", dep))
+			continue
+		}
+
+		update(item, InstallManyInstalling, nil)
+		if err := installRemoteAndCollect(mStore, item.part, flags, meter); err != nil {
+			update(item, InstallManyFailed, err)
+			continue
+		}
+		update(item, InstallManyInstalled, nil)
+	}
+
+	results := make([]InstallManyResult, len(items))
+	failed := false
+	for i, item := range items {
+		results[i] = item.InstallManyResult
+		if item.Status == InstallManyFailed {
+			failed = true
+		}
+	}
+	if failed {
+		return results, &ErrInstallManyFailed{Results: results}
+	}
+
+	return results, nil
+}
+
+// findInstallable returns the given snap from the store if it can be
+// installed
+func findInstallable(mStore StoreRepository, name, channel string, installed []Part) (*RemoteSnapPart, error) {
+	part, err := mStore.Snap(name, channel)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(FindSnapsByNameAndVersion(QualifiedName(part), part.Version(), installed)) != 0 {
+		return nil, ErrAlreadyInstalled
+	}
+	if PackageNameActive(part.Name()) {
+		return nil, ErrPackageNameAlreadyInstalled
+	}
+
+	return part, nil
+}
+
+// download downloads the snap and reads the frameworks it needs
+func (item *installManyItem) download(mStore StoreRepository) error {
+	path, err := mStore.Download(item.part, &progress.NullProgress{})
+	if err != nil {
+		return fmt.Errorf("cannot download %s: %s", item.part.Name(), err)
+	}
+
+	// the snap is verified when it is installed
+	s, err := NewSnapFile(path, item.part.Origin(), true)
+	if err != nil {
+		return err
+	}
+	item.frameworks = s.m.Frameworks
+
+	return nil
+}
+
+// failedDependency returns the name of the first framework the item needs
+// that is in the given items but failed to install, or "" if there is none
+func (item *installManyItem) failedDependency(items []*installManyItem) string {
+	for _, other := range items {
+		if other.Status == InstallManyFailed && item.needs(other) {
+			return other.Name
+		}
+	}
+
+	return ""
+}
+
+// needs returns whether the item needs the other item as a framework. If
+// the other item was not found in the store its requested name is used.
+func (item *installManyItem) needs(other *installManyItem) bool {
+	if other == item {
+		return false
+	}
+	otherName, _ := SplitOrigin(other.Name)
+	if other.part != nil {
+		otherName = other.part.Name()
+	}
+	for _, fw := range item.frameworks {
+		if name, _ := SplitOrigin(fw); name == otherName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// orderForInstall returns the items so that every item comes after the
+// items it needs, keeping the given order otherwise. Items in a dependency
+// cycle are kept in the given order.
+func orderForInstall(items []*installManyItem) []*installManyItem {
+	ordered := make([]*installManyItem, 0, len(items))
+	placed := make(map[*installManyItem]bool, len(items))
+
+	ready := func(item *installManyItem) bool {
+		for _, other := range items {
+			if !placed[other] && item.needs(other) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for len(ordered) < len(items) {
+		progressed := false
+		for _, item := range items {
+			if !placed[item] && ready(item) {
+				ordered = append(ordered, item)
+				placed[item] = true
+				progressed = true
+				break
+			}
+		}
+		if !progressed {
+			for _, item := range items {
+				if !placed[item] {
+					ordered = append(ordered, item)
+					placed[item] = true
+				}
+			}
+		}
+	}
+
+	return ordered
+}
+
+// installRemoteAndCollect installs the given snap and garbage collects
+// its old versions
+func installRemoteAndCollect(mStore StoreRepository, part *RemoteSnapPart, flags InstallFlags, meter progress.Meter) error {
+	name, err := installRemote(mStore, part, flags, meter)
+	if err != nil {
+		return err
+	}
+
+	return GarbageCollect(name, flags, meter)
+}
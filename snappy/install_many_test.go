@@ -0,0 +1,169 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/progress"
+	"github.com/ubuntu-core/snappy/snap/remote"
+)
+
+// makeDirStore makes SNAPPY_LOCAL_STORE serve the given snap.yamls
+// and returns a function to unset it again
+func makeDirStore(c *C, snapYamls map[string]string) (restore func()) {
+	dir := c.MkDir()
+	for name, snapYaml := range snapYamls {
+		snapFile := makeTestSnapPackage(c, snapYaml)
+		sha, err := helpers.Sha512sum(snapFile)
+		c.Assert(err, IsNil)
+		details := fmt.Sprintf(`{"package_name": "%s", "origin": "%s", "version": "1", "revision": 1, "download_url": "%s", "download_sha512": "%s"}`, name, testOrigin, snapFile, sha)
+		err = ioutil.WriteFile(filepath.Join(dir, name+"."+testOrigin+".json"), []byte(details), 0644)
+		c.Assert(err, IsNil)
+	}
+
+	os.Setenv("SNAPPY_LOCAL_STORE", dir)
+	return func() { os.Unsetenv("SNAPPY_LOCAL_STORE") }
+}
+
+func (s *SnapTestSuite) TestInstallManyOrdersFrameworksFirst(c *C) {
+	defer makeDirStore(c, map[string]string{
+		"app":   "name: app\nversion: 1\nframeworks: [fwk]",
+		"fwk":   "name: fwk\nversion: 1\ntype: framework",
+		"other": "name: other\nversion: 1",
+	})()
+
+	var installing []string
+	report := func(res InstallManyResult) {
+		if res.Status == InstallManyInstalling {
+			installing = append(installing, res.Name)
+		}
+	}
+
+	results, err := InstallMany([]string{"app", "fwk", "other"}, "", 0, &progress.NullProgress{}, report)
+	c.Assert(err, IsNil)
+	c.Check(installing, DeepEquals, []string{"fwk", "app", "other"})
+	c.Check(results, DeepEquals, []InstallManyResult{
+		{Name: "app", Version: "1", Status: InstallManyInstalled},
+		{Name: "fwk", Version: "1", Status: InstallManyInstalled},
+		{Name: "other", Version: "1", Status: InstallManyInstalled},
+	})
+
+	for _, name := range []string{"app", "fwk", "other"} {
+		c.Check(ActiveSnapByName(name), NotNil)
+	}
+}
+
+func (s *SnapTestSuite) TestInstallManyReportsFailures(c *C) {
+	defer makeDirStore(c, map[string]string{
+		"app":   "name: app\nversion: 1\nframeworks: [fwk]",
+		"fwk":   "name: fwk\nversion: 1\ntype: framework\narchitectures: [potato]",
+		"other": "name: other\nversion: 1",
+	})()
+
+	results, err := InstallMany([]string{"missing", "app", "fwk", "other"}, "", 0, &progress.NullProgress{}, nil)
+	c.Assert(err, FitsTypeOf, &ErrInstallManyFailed{})
+	c.Check(err.(*ErrInstallManyFailed).Results, DeepEquals, results)
+	c.Assert(results, HasLen, 4)
+
+	c.Check(results[0].Status, Equals, InstallManyFailed)
+	c.Check(results[0].Error, Equals, ErrPackageNotFound.Error())
+	c.Check(results[1].Status, Equals, InstallManyFailed)
+	c.Check(results[1].Error, Equals, "it needs fwk, which failed to install")
+	c.Check(results[2].Status, Equals, InstallManyFailed)
+	c.Check(results[3].Status, Equals, InstallManyInstalled)
+
+	c.Check(ActiveSnapByName("app"), IsNil)
+	c.Check(ActiveSnapByName("fwk"), IsNil)
+	c.Check(ActiveSnapByName("other"), NotNil)
+}
+
+func (s *SnapTestSuite) TestInstallManyFrameworkNotFound(c *C) {
+	defer makeDirStore(c, map[string]string{
+		"app": "name: app\nversion: 1\nframeworks: [fwk]",
+	})()
+
+	results, err := InstallMany([]string{"app", "fwk." + testOrigin}, "", 0, &progress.NullProgress{}, nil)
+	c.Assert(err, FitsTypeOf, &ErrInstallManyFailed{})
+	c.Assert(results, HasLen, 2)
+	c.Check(results[0].Status, Equals, InstallManyFailed)
+	c.Check(results[0].Error, Equals, "it needs fwk."+testOrigin+", which failed to install")
+	c.Check(results[1].Error, Equals, ErrPackageNotFound.Error())
+}
+
+func (s *SnapTestSuite) TestInstallManyAlreadyInstalled(c *C) {
+	defer makeDirStore(c, map[string]string{"foo": "name: foo\nversion: 1"})()
+
+	_, err := InstallMany([]string{"foo"}, "", 0, &progress.NullProgress{}, nil)
+	c.Assert(err, IsNil)
+
+	results, err := InstallMany([]string{"foo"}, "", 0, &progress.NullProgress{}, nil)
+	c.Assert(err, ErrorMatches, `cannot install foo \(the given snap is already installed\)`)
+	c.Check(results[0].Status, Equals, InstallManyFailed)
+}
+
+func (s *SnapTestSuite) TestInstallManyDuplicates(c *C) {
+	defer makeDirStore(c, map[string]string{
+		"app": "name: app\nversion: 1",
+	})()
+
+	for _, names := range [][]string{
+		{"app", "app"},
+		{"app", "app." + testOrigin},
+	} {
+		_, err := InstallMany(names, "", 0, &progress.NullProgress{}, nil)
+		c.Check(err, ErrorMatches, `cannot install "app" more than once`)
+	}
+
+	// nothing got downloaded or installed
+	c.Check(ActiveSnapByName("app"), IsNil)
+}
+
+func (s *SnapTestSuite) TestOrderForInstall(c *C) {
+	item := func(name string, frameworks ...string) *installManyItem {
+		return &installManyItem{
+			InstallManyResult: InstallManyResult{Name: name},
+			part:              NewRemoteSnapPart(remote.Snap{Name: name}),
+			frameworks:        frameworks,
+		}
+	}
+	names := func(items []*installManyItem) []string {
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Name)
+		}
+		return names
+	}
+
+	c.Check(names(orderForInstall([]*installManyItem{
+		item("a", "c"), item("b"), item("c", "d.origin"), item("d"),
+	})), DeepEquals, []string{"b", "d", "c", "a"})
+
+	// cycles keep their order
+	c.Check(names(orderForInstall([]*installManyItem{
+		item("a", "b"), item("b", "a"), item("c"),
+	})), DeepEquals, []string{"c", "a", "b"})
+}
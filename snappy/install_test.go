@@ -20,6 +20,7 @@
 package snappy
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -45,7 +46,7 @@ func makeCloudInitMetaData(c *C, content string) string {
 
 func (s *SnapTestSuite) TestInstallInstall(c *C) {
 	snapFile := makeTestSnapPackage(c, "")
-	name, err := Install(snapFile, AllowUnauthenticated|DoInstallGC, &progress.NullProgress{})
+	name, err := Install(snapFile, "", AllowUnauthenticated|DoInstallGC, &progress.NullProgress{})
 	c.Assert(err, IsNil)
 	c.Check(name, Equals, "foo")
 
@@ -60,7 +61,7 @@ func (s *SnapTestSuite) TestInstallInstall(c *C) {
 
 func (s *SnapTestSuite) TestInstallNoHook(c *C) {
 	snapFile := makeTestSnapPackage(c, "")
-	name, err := Install(snapFile, AllowUnauthenticated|DoInstallGC|InhibitHooks, &progress.NullProgress{})
+	name, err := Install(snapFile, "", AllowUnauthenticated|DoInstallGC|InhibitHooks, &progress.NullProgress{})
 	c.Assert(err, IsNil)
 	c.Check(name, Equals, "foo")
 
@@ -81,7 +82,7 @@ vendor: Foo Bar <foo@example.com>
 license-agreement: explicit
 `)
 	ag := &MockProgressMeter{y: true}
-	name, err := Install(snapFile, AllowUnauthenticated|DoInstallGC, ag)
+	name, err := Install(snapFile, "", AllowUnauthenticated|DoInstallGC, ag)
 	c.Assert(err, IsNil)
 	c.Check(name, Equals, "foo")
 	c.Check(ag.license, Equals, "WTFPL")
@@ -95,7 +96,7 @@ vendor: Foo Bar <foo@example.com>
 license-agreement: explicit
 `)
 	ag := &MockProgressMeter{y: false}
-	_, err := Install(snapFile, AllowUnauthenticated|DoInstallGC, ag)
+	_, err := Install(snapFile, "", AllowUnauthenticated|DoInstallGC, ag)
 	c.Assert(IsLicenseNotAccepted(err), Equals, true)
 	c.Check(ag.license, Equals, "WTFPL")
 }
@@ -110,15 +111,15 @@ func (s *SnapTestSuite) installThree(c *C, flags InstallFlags) {
 	snapYamlContent := `name: foo
 `
 	snapFile := makeTestSnapPackage(c, snapYamlContent+"version: 1.0")
-	_, err = Install(snapFile, flags, &progress.NullProgress{})
+	_, err = Install(snapFile, "", flags, &progress.NullProgress{})
 	c.Assert(err, IsNil)
 
 	snapFile = makeTestSnapPackage(c, snapYamlContent+"version: 2.0")
-	_, err = Install(snapFile, flags, &progress.NullProgress{})
+	_, err = Install(snapFile, "", flags, &progress.NullProgress{})
 	c.Assert(err, IsNil)
 
 	snapFile = makeTestSnapPackage(c, snapYamlContent+"version: 3.0")
-	_, err = Install(snapFile, flags, &progress.NullProgress{})
+	_, err = Install(snapFile, "", flags, &progress.NullProgress{})
 	c.Assert(err, IsNil)
 }
 
@@ -184,11 +185,11 @@ func (s *SnapTestSuite) TestInstallAppTwiceFails(c *C) {
 	storeDetailsURI, err = url.Parse(mockServer.URL + "/details/")
 	c.Assert(err, IsNil)
 
-	name, err := Install("foo", 0, &progress.NullProgress{})
+	name, err := Install("foo", "", 0, &progress.NullProgress{})
 	c.Assert(err, IsNil)
 	c.Check(name, Equals, "foo")
 
-	_, err = Install("foo", 0, &progress.NullProgress{})
+	_, err = Install("foo", "", 0, &progress.NullProgress{})
 	c.Assert(err, ErrorMatches, ".*"+ErrAlreadyInstalled.Error())
 }
 
@@ -227,7 +228,7 @@ func (s *SnapTestSuite) TestInstallAppPackageNameFails(c *C) {
 	c.Assert(mockServer, NotNil)
 	defer mockServer.Close()
 
-	_, err = Install("hello-app.potato", 0, ag)
+	_, err = Install("hello-app.potato", "", 0, ag)
 	c.Assert(err, ErrorMatches, ".*"+ErrPackageNameAlreadyInstalled.Error())
 }
 
@@ -302,3 +303,201 @@ func (s *SnapTestSuite) TestUpdate(c *C) {
 	// ensure that we get a "local" snap back - not a remote one
 	c.Check(updates[0], FitsTypeOf, &SnapPart{})
 }
+
+func (s *SnapTestSuite) TestInstallTracksChannel(c *C) {
+	snapPackage := makeTestSnapPackage(c, "name: foo\nversion: 2")
+	snapR, err := os.Open(snapPackage)
+	c.Assert(err, IsNil)
+	defer snapR.Close()
+
+	var dlURL string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/details/foo/beta":
+			io.WriteString(w, `{
+"package_name": "foo",
+"version": "2",
+"origin": "test",
+"anon_download_url": "`+dlURL+`"
+}`)
+		case "/dl":
+			snapR.Seek(0, 0)
+			io.Copy(w, snapR)
+		default:
+			panic("unexpected url path: " + r.URL.Path)
+		}
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	dlURL = mockServer.URL + "/dl"
+	storeDetailsURI, err = url.Parse(mockServer.URL + "/details/")
+	c.Assert(err, IsNil)
+
+	_, err = Install("foo", "beta", 0, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(TrackedChannel("foo.test"), Equals, "beta")
+	c.Check(ActiveSnapByName("foo").Channel(), Equals, "beta")
+}
+
+func (s *SnapTestSuite) TestUpdateSwitchesChannel(c *C) {
+	yamlPath, err := s.makeInstalledMockSnap("name: foo\nversion: 1")
+	c.Assert(err, IsNil)
+	makeSnapActive(yamlPath)
+	c.Assert(ActiveSnapByName("foo").Channel(), Equals, "remote-channel")
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/details/foo." + testOrigin + "/edge":
+			io.WriteString(w, `{
+"package_name": "foo",
+"version": "1",
+"origin": "`+testOrigin+`"
+}`)
+		default:
+			panic("unexpected url path: " + r.URL.Path)
+		}
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	storeDetailsURI, err = url.Parse(mockServer.URL + "/details/")
+	c.Assert(err, IsNil)
+
+	// the same version is in edge, so only the channel changes
+	updates, err := Update("foo", "edge", 0, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(updates, HasLen, 0)
+	c.Check(ActiveSnapByName("foo").Channel(), Equals, "edge")
+}
+
+func (s *SnapTestSuite) TestUpdateUnknownChannel(c *C) {
+	yamlPath, err := s.makeInstalledMockSnap("name: foo\nversion: 1")
+	c.Assert(err, IsNil)
+	makeSnapActive(yamlPath)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	storeDetailsURI, err = url.Parse(mockServer.URL + "/details/")
+	c.Assert(err, IsNil)
+
+	_, err = Update("foo", "potato", 0, &progress.NullProgress{})
+	c.Assert(err, ErrorMatches, `cannot find "foo" in channel "potato": .*`)
+	c.Check(ActiveSnapByName("foo").Channel(), Equals, "remote-channel")
+}
+
+func (s *SnapTestSuite) TestUpdateOnlyQueriesTheSnap(c *C) {
+	yamlPath, err := s.makeInstalledMockSnap("name: foo\nversion: 1")
+	c.Assert(err, IsNil)
+	makeSnapActive(yamlPath)
+
+	snapPackagev2 := makeTestSnapPackage(c, "name: foo\nversion: 2")
+	snapR, err := os.Open(snapPackagev2)
+	c.Assert(err, IsNil)
+	defer snapR.Close()
+
+	var dlURL string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/updates/":
+			jsonReq, err := ioutil.ReadAll(r.Body)
+			c.Assert(err, IsNil)
+			c.Check(string(jsonReq), Equals, `{"name":["foo.`+testOrigin+`/remote-channel"]}`)
+			io.WriteString(w, `[{
+	"package_name": "foo",
+	"version": "2",
+	"origin": "`+testOrigin+`",
+	"anon_download_url": "`+dlURL+`"
+}]`)
+		case "/dl":
+			snapR.Seek(0, 0)
+			io.Copy(w, snapR)
+		default:
+			panic("unexpected url path: " + r.URL.Path)
+		}
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	dlURL = mockServer.URL + "/dl"
+	storeBulkURI, err = url.Parse(mockServer.URL + "/updates/")
+	c.Assert(err, IsNil)
+
+	// other installed snaps are not sent to the store
+	mockActiveSnapIterByType([]string{"bar." + testOrigin})
+
+	updates, err := Update("foo", "", 0, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Assert(updates, HasLen, 1)
+	c.Check(updates[0].Name(), Equals, "foo")
+	c.Check(updates[0].Version(), Equals, "2")
+}
+
+func (s *SnapTestSuite) TestUpdateAllTransactionalRollsBack(c *C) {
+	for _, name := range []string{"foo", "bar"} {
+		yamlPath, err := s.makeInstalledMockSnap("name: " + name + "\nversion: 1")
+		c.Assert(err, IsNil)
+		makeSnapActive(yamlPath)
+	}
+
+	snapPackagev2 := makeTestSnapPackage(c, "name: foo\nversion: 2")
+	snapR, err := os.Open(snapPackagev2)
+	c.Assert(err, IsNil)
+	defer snapR.Close()
+
+	var dlURL string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/updates/":
+			io.WriteString(w, `[{
+	"package_name": "foo",
+	"version": "2",
+	"origin": "`+testOrigin+`",
+	"anon_download_url": "`+dlURL+`/foo"
+}, {
+	"package_name": "bar",
+	"version": "2",
+	"origin": "`+testOrigin+`",
+	"anon_download_url": "`+dlURL+`/bar"
+}]`)
+		case "/dl/foo":
+			snapR.Seek(0, 0)
+			io.Copy(w, snapR)
+		case "/dl/bar":
+			io.WriteString(w, "not a snap")
+		default:
+			panic("unexpected url path: " + r.URL.Path)
+		}
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	dlURL = mockServer.URL + "/dl"
+	storeBulkURI, err = url.Parse(mockServer.URL + "/updates/")
+	c.Assert(err, IsNil)
+
+	updates, err := UpdateAll(TransactionalUpdate, &progress.NullProgress{})
+	c.Assert(updates, IsNil)
+	c.Assert(err, FitsTypeOf, &ErrUpdateAllFailed{})
+	report := err.(*ErrUpdateAllFailed)
+	c.Check(report.Snap, Equals, "bar")
+	c.Check(report.RolledBack, DeepEquals, []string{"foo"})
+	c.Check(report.RollbackErrs, HasLen, 0)
+
+	c.Check(ActiveSnapByName("foo").Version(), Equals, "1")
+	c.Check(ActiveSnapByName("bar").Version(), Equals, "1")
+}
+
+func (s *SnapTestSuite) TestErrUpdateAllFailed(c *C) {
+	err := &ErrUpdateAllFailed{
+		Snap:         "bar",
+		OrigErr:      errors.New("boom"),
+		RolledBack:   []string{"foo"},
+		RollbackErrs: map[string]error{"baz": errors.New("meh")},
+	}
+	c.Check(err, ErrorMatches, `bar failed to update: boom \(rolled back: foo\); cannot roll back baz: meh`)
+}
@@ -0,0 +1,107 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/progress"
+	"github.com/ubuntu-core/snappy/provisioning"
+)
+
+// IsSnapURL returns whether the given name is an http or https URL
+// that Install downloads the snap from
+func IsSnapURL(name string) bool {
+	u, err := url.Parse(name)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// InstallURL downloads the snap at the given http or https URL and
+// installs it like a local snap file. If sha512 is not empty the
+// download must have that sha512.
+func InstallURL(snapURL, sha512 string, flags InstallFlags, meter progress.Meter) (string, error) {
+	name, err := installURL(snapURL, sha512, flags, meter)
+	if err != nil {
+		return "", &ErrInstallFailed{Snap: snapURL, OrigErr: err}
+	}
+
+	return name, GarbageCollect(name, flags, meter)
+}
+
+func installURL(snapURL, sha512 string, flags InstallFlags, meter progress.Meter) (string, error) {
+	path, err := downloadURL(snapURL, sha512, meter)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+
+	// we allow unauthenticated package when in developer
+	// mode, as for local snaps
+	if provisioning.InDeveloperMode() {
+		flags |= AllowUnauthenticated
+	}
+
+	return installClick(path, flags, meter, SideloadedOrigin)
+}
+
+// downloadURL downloads the snap at the given URL into a temporary file
+// in the download cache and verifies its sha512, if one is given
+func downloadURL(snapURL, sha512 string, meter progress.Meter) (string, error) {
+	if !IsSnapURL(snapURL) {
+		return "", fmt.Errorf("cannot download %q: not an http or https URL", snapURL)
+	}
+
+	req, err := http.NewRequest("GET", snapURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(dirs.SnapDownloadCacheDir, 0755); err != nil {
+		return "", err
+	}
+	w, err := ioutil.TempFile(dirs.SnapDownloadCacheDir, "url-")
+	if err != nil {
+		return "", err
+	}
+
+	err = download(path.Base(req.URL.Path), w, req, meter)
+	if cerr := w.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = verifyDownload(w.Name(), sha512)
+	}
+	if err != nil {
+		os.Remove(w.Name())
+		return "", err
+	}
+
+	return w.Name(), nil
+}
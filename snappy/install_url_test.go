@@ -0,0 +1,116 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/progress"
+)
+
+func serveSnap(c *C, snapFile string) *httptest.Server {
+	content, err := ioutil.ReadFile(snapFile)
+	c.Assert(err, IsNil)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/foo.snap" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	c.Assert(mockServer, NotNil)
+
+	return mockServer
+}
+
+func (s *SnapTestSuite) TestIsSnapURL(c *C) {
+	c.Check(IsSnapURL("https://example.com/foo.snap"), Equals, true)
+	c.Check(IsSnapURL("http://example.com/foo.snap"), Equals, true)
+	c.Check(IsSnapURL("ftp://example.com/foo.snap"), Equals, false)
+	c.Check(IsSnapURL("https:foo.snap"), Equals, false)
+	c.Check(IsSnapURL("foo.snap"), Equals, false)
+	c.Check(IsSnapURL("foo"), Equals, false)
+}
+
+func (s *SnapTestSuite) TestInstallURL(c *C) {
+	snapFile := makeTestSnapPackage(c, "")
+	sha, err := helpers.Sha512sum(snapFile)
+	c.Assert(err, IsNil)
+	mockServer := serveSnap(c, snapFile)
+	defer mockServer.Close()
+
+	name, err := InstallURL(mockServer.URL+"/foo.snap", sha, AllowUnauthenticated, &MockProgressMeter{})
+	c.Assert(err, IsNil)
+	c.Check(name, Equals, "foo")
+
+	part := ActiveSnapByName("foo")
+	c.Assert(part, NotNil)
+	c.Check(part.Origin(), Equals, SideloadedOrigin)
+
+	// the download is not kept around
+	downloads, err := ioutil.ReadDir(dirs.SnapDownloadCacheDir)
+	c.Assert(err, IsNil)
+	c.Check(downloads, HasLen, 0)
+}
+
+func (s *SnapTestSuite) TestInstallURLViaInstall(c *C) {
+	mockServer := serveSnap(c, makeTestSnapPackage(c, ""))
+	defer mockServer.Close()
+
+	name, err := Install(mockServer.URL+"/foo.snap", "", AllowUnauthenticated, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(name, Equals, "foo")
+	c.Check(ActiveSnapByName("foo"), NotNil)
+}
+
+func (s *SnapTestSuite) TestInstallURLHashMismatch(c *C) {
+	mockServer := serveSnap(c, makeTestSnapPackage(c, ""))
+	defer mockServer.Close()
+
+	_, err := InstallURL(mockServer.URL+"/foo.snap", sha512sum("something else"), AllowUnauthenticated, &progress.NullProgress{})
+	c.Assert(err, FitsTypeOf, &ErrInstallFailed{})
+	c.Check(err.(*ErrInstallFailed).OrigErr, Equals, ErrHashMismatch)
+	c.Check(ActiveSnapByName("foo"), IsNil)
+
+	downloads, err := ioutil.ReadDir(dirs.SnapDownloadCacheDir)
+	c.Assert(err, IsNil)
+	c.Check(downloads, HasLen, 0)
+}
+
+func (s *SnapTestSuite) TestInstallURLNotFound(c *C) {
+	mockServer := serveSnap(c, makeTestSnapPackage(c, ""))
+	defer mockServer.Close()
+
+	_, err := InstallURL(mockServer.URL+"/bar.snap", "", AllowUnauthenticated, &progress.NullProgress{})
+	c.Assert(err, FitsTypeOf, &ErrInstallFailed{})
+	c.Check(err.(*ErrInstallFailed).OrigErr, FitsTypeOf, &ErrDownload{})
+}
+
+func (s *SnapTestSuite) TestInstallURLNotHTTP(c *C) {
+	_, err := InstallURL("ftp://example.com/foo.snap", "", 0, &progress.NullProgress{})
+	c.Check(err, ErrorMatches, `.*cannot download "ftp://example.com/foo.snap": not an http or https URL`)
+}
@@ -21,6 +21,7 @@ package snappy
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -64,6 +65,42 @@ func removeKernelAssets(s *SnapPart, inter interacter) error {
 	return nil
 }
 
+// isGrubSystem returns true if the gadget says we boot with grub, which
+// loads the kernel straight from the snap
+func isGrubSystem() bool {
+	oem, err := getGadget()
+	return err == nil && oem != nil && oem.Gadget.Hardware.Bootloader == "grub"
+}
+
+// kernelAssetsSize returns the space the kernel assets of the given
+// kernel snap take once extracted to the bootloader directory
+func kernelAssetsSize(s *SnapFile) (uint64, error) {
+	if isGrubSystem() {
+		return 0, nil
+	}
+
+	tmpdir, err := ioutil.TempDir("", "kernel-assets")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpdir)
+
+	srcs := []string{s.m.Kernel, s.m.Initrd}
+	if s.m.Dtbs != "" {
+		srcs = append(srcs, filepath.Join(s.m.Dtbs, "*"))
+	}
+	for _, src := range srcs {
+		if src == "" {
+			continue
+		}
+		if err := s.deb.Unpack(src, tmpdir); err != nil {
+			return 0, err
+		}
+	}
+
+	return treeSize(tmpdir)
+}
+
 // extractKernelAssets extracts kernel/initrd/dtb data from the given
 // SnapPart to a versionized bootloader directory so that the bootloader
 // can use it.
@@ -79,10 +116,8 @@ func extractKernelAssets(s *SnapFile, inter progress.Meter, flags InstallFlags)
 
 	// check if we are on a "grub" system. if so, no need to unpack
 	// the kernel
-	if oem, err := getGadget(); err == nil {
-		if oem.Gadget.Hardware.Bootloader == "grub" {
-			return nil
-		}
+	if isGrubSystem() {
+		return nil
 	}
 
 	// FIXME: feels wrong to use the instdir here, need something better
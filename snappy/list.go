@@ -26,5 +26,5 @@ func ListInstalled() ([]Part, error) {
 
 // ListUpdates returns all snaps with updates
 func ListUpdates() ([]Part, error) {
-	return NewUbuntuStoreSnapRepository().Updates()
+	return NewStoreRepository().Updates()
 }
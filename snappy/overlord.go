@@ -27,6 +27,7 @@ import (
 
 	"github.com/ubuntu-core/snappy/arch"
 	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
 	"github.com/ubuntu-core/snappy/logger"
 	"github.com/ubuntu-core/snappy/progress"
 	"github.com/ubuntu-core/snappy/snap"
@@ -40,28 +41,49 @@ type Overlord struct {
 
 // Install installs the given snap file to the system.
 //
+// If it replaces an active version and the new version declares a
+// health check, the previous version is made active again when the
+// new one fails it.
+//
 // It returns the local snap file or an error
-func (o *Overlord) Install(snapFilePath string, origin string, flags InstallFlags, meter progress.Meter) (sp *SnapPart, err error) {
+func (o *Overlord) Install(snapFilePath string, origin string, flags InstallFlags, meter progress.Meter) (*SnapPart, error) {
+	sp, oldPart, err := o.install(snapFilePath, origin, flags, meter)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldPart != nil && (flags&InhibitHooks) == 0 {
+		if err := checkHealthOrRollback(sp, oldPart, meter); err != nil {
+			return nil, err
+		}
+	}
+
+	return sp, nil
+}
+
+// install does the actual work for Install, it also returns the
+// version that was active before (if any)
+func (o *Overlord) install(snapFilePath string, origin string, flags InstallFlags, meter progress.Meter) (sp *SnapPart, prevPart *SnapPart, err error) {
 	allowGadget := (flags & AllowGadget) != 0
 	inhibitHooks := (flags & InhibitHooks) != 0
 	allowUnauth := (flags & AllowUnauthenticated) != 0
 
 	s, err := NewSnapFile(snapFilePath, origin, allowUnauth)
 	if err != nil {
-		return nil, fmt.Errorf("can not open %s: %s", snapFilePath, err)
+		return nil, nil, fmt.Errorf("can not open %s: %s", snapFilePath, err)
 	}
 
 	// we do not Verify() the package here. This is done earlier in
 	// NewSnapFile() to ensure that we do not mount/inspect
 	// potentially dangerous snaps
 	if err := canInstall(s, allowGadget, meter); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// the "gadget" parts are special
 	if s.Type() == snap.TypeGadget {
 		if err := installGadgetHardwareUdevRules(s.m); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
@@ -72,13 +94,13 @@ func (o *Overlord) Install(snapFilePath string, origin string, flags InstallFlag
 	if currentActiveDir, _ := filepath.EvalSymlinks(filepath.Join(s.instdir, "..", "current")); currentActiveDir != "" {
 		oldPart, err = NewInstalledSnapPart(filepath.Join(currentActiveDir, "meta", "snap.yaml"), s.origin)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	if err := os.MkdirAll(s.instdir, 0755); err != nil {
 		logger.Noticef("Can not create %q: %v", s.instdir, err)
-		return nil, err
+		return nil, nil, err
 	}
 
 	// if anything goes wrong here we cleanup
@@ -93,12 +115,12 @@ func (o *Overlord) Install(snapFilePath string, origin string, flags InstallFlag
 	// we need to call the external helper so that we can reliable drop
 	// privs
 	if err := s.deb.Install(s.instdir); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// generate the mount unit for the squashfs
 	if err := addSquashfsMount(s.m, s.instdir, inhibitHooks, meter); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// if anything goes wrong we ensure we stop
 	defer func() {
@@ -112,7 +134,7 @@ func (o *Overlord) Install(snapFilePath string, origin string, flags InstallFlag
 	// FIXME: special handling is bad 'mkay
 	if s.m.Type == snap.TypeKernel {
 		if err := extractKernelAssets(s, meter, flags); err != nil {
-			return nil, fmt.Errorf("failed to install kernel %s", err)
+			return nil, nil, fmt.Errorf("failed to install kernel %s", err)
 		}
 	}
 
@@ -134,7 +156,7 @@ func (o *Overlord) Install(snapFilePath string, origin string, flags InstallFlag
 			}
 		}()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		err = copySnapData(fullName, oldPart.Version(), s.Version())
@@ -151,13 +173,24 @@ func (o *Overlord) Install(snapFilePath string, origin string, flags InstallFlag
 	}()
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if err = applySnapQuota(fullName, s.Version(), meter); err != nil {
+		return nil, nil, err
 	}
 
 	if !inhibitHooks {
 		newPart, err := newSnapPartFromYaml(filepath.Join(s.instdir, "meta", "snap.yaml"), s.origin, s.m)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+
+		// give the new version a chance to migrate the copied data
+		if oldPart != nil {
+			if err := runPostRefreshHook(newPart, oldPart.Version()); err != nil {
+				return nil, nil, err
+			}
 		}
 
 		// and finally make active
@@ -167,16 +200,19 @@ func (o *Overlord) Install(snapFilePath string, origin string, flags InstallFlag
 				if cerr := oldPart.activate(inhibitHooks, meter); cerr != nil {
 					logger.Noticef("When setting old %s version back to active: %v", s.Name(), cerr)
 				}
+				if cerr := applySnapQuota(fullName, oldPart.Version(), meter); cerr != nil {
+					logger.Noticef("When applying the quota to old %s version: %v", s.Name(), cerr)
+				}
 			}
 		}()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		// oh, one more thing: refresh the security bits
 		deps, err := newPart.Dependents()
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		sysd := systemd.New(dirs.GlobalRootDir, meter)
@@ -203,14 +239,14 @@ func (o *Overlord) Install(snapFilePath string, origin string, flags InstallFlag
 				timeout := time.Duration(svc.StopTimeout)
 				if err = sysd.Stop(serviceName, timeout); err != nil {
 					meter.Notify(fmt.Sprintf("unable to stop %s; aborting install: %s", serviceName, err))
-					return nil, err
+					return nil, nil, err
 				}
 				stopped[serviceName] = timeout
 			}
 		}
 
 		if err := newPart.RefreshDependentsSecurity(oldPart, meter); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		started := make(map[string]time.Duration)
@@ -226,13 +262,18 @@ func (o *Overlord) Install(snapFilePath string, origin string, flags InstallFlag
 		for serviceName, timeout := range stopped {
 			if err = sysd.Start(serviceName); err != nil {
 				meter.Notify(fmt.Sprintf("unable to restart %s; aborting install: %s", serviceName, err))
-				return nil, err
+				return nil, nil, err
 			}
 			started[serviceName] = timeout
 		}
 	}
 
-	return newSnapPartFromYaml(filepath.Join(s.instdir, "meta", "snap.yaml"), s.origin, s.m)
+	sp, err = newSnapPartFromYaml(filepath.Join(s.instdir, "meta", "snap.yaml"), s.origin, s.m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sp, oldPart, nil
 }
 
 // CanInstall checks whether the SnapPart passes a series of tests required for installation
@@ -264,6 +305,11 @@ func canInstall(s *SnapFile, allowGadget bool, inter interacter) error {
 	}
 
 	curr, _ := filepath.EvalSymlinks(filepath.Join(s.instdir, "..", "current"))
+	// fail before asking for a license we could not install anyway
+	if err := checkDiskSpace(s, curr); err != nil {
+		return err
+	}
+
 	if err := checkLicenseAgreement(s.m, inter, s.deb, curr); err != nil {
 		return err
 	}
@@ -316,6 +362,17 @@ func (o *Overlord) Uninstall(s *SnapPart, meter progress.Meter) error {
 	// best effort(?)
 	os.Remove(filepath.Dir(s.basedir))
 
+	// the last version is gone, so are the downloads kept for it and
+	// its hold, that must not come back if the snap is installed again
+	if !helpers.FileExists(filepath.Dir(s.basedir)) {
+		if err := removeDownloadCache(QualifiedName(s)); err != nil {
+			logger.Noticef("Cannot clean the download cache of %s: %v", QualifiedName(s), err)
+		}
+		if err := removeHold(QualifiedName(s)); err != nil {
+			logger.Noticef("Cannot remove the hold of %s: %v", QualifiedName(s), err)
+		}
+	}
+
 	// remove the snap
 	if err := os.RemoveAll(squashfs.BlobPath(s.basedir)); err != nil {
 		return err
@@ -336,7 +393,10 @@ func (o *Overlord) Uninstall(s *SnapPart, meter progress.Meter) error {
 // It returns an error on failure
 func (o *Overlord) SetActive(s *SnapPart, active bool, meter progress.Meter) error {
 	if active {
-		return s.activate(false, meter)
+		if err := s.activate(false, meter); err != nil {
+			return err
+		}
+		return applySnapQuota(QualifiedName(s), s.Version(), meter)
 	}
 
 	return s.deactivate(false, meter)
@@ -22,8 +22,10 @@ package snappy
 import (
 	"fmt"
 	"path/filepath"
+	"time"
 
 	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
 	"github.com/ubuntu-core/snappy/progress"
 )
 
@@ -34,11 +36,17 @@ const (
 	// DoPurgeActive requests that the data files of an active
 	// package be removed. Without this that is disallowed.
 	DoPurgeActive PurgeFlags = 1 << iota
+	// DoPurgeNoSnapshot requests that the data be removed right away,
+	// without keeping it in a snapshot until it expires.
+	DoPurgeNoSnapshot
 )
 
 var remove = removeSnapData
 
 // Purge a part by a partSpec string, name[.origin][=version]
+//
+// Unless DoPurgeNoSnapshot is given the data is saved in snapshots first,
+// that expire after the time set by the GC policy.
 func Purge(partSpec string, flags PurgeFlags, meter progress.Meter) error {
 	var e error
 	datadirs := DataDirs(partSpec)
@@ -79,11 +87,32 @@ func Purge(partSpec string, flags PurgeFlags, meter progress.Meter) error {
 		}
 	}
 
-	// Conduct the purge.
+	if flags&DoPurgeNoSnapshot == 0 {
+		e = snapshotPurged(datadirs)
+	}
+
+	// Conduct the purge, unless the data could not be kept.
+	if e == nil {
+		for _, datadir := range datadirs {
+			if err := remove(datadir.QualifiedName(), datadir.Version); err != nil {
+				e = err
+				meter.Notify(fmt.Sprintf("unable to purge %s version %s: %s", datadir.QualifiedName(), datadir.Version, err.Error()))
+			}
+		}
+	}
+
+	// snaps no longer installed have no use for their downloads, nor
+	// their holds
 	for _, datadir := range datadirs {
-		if err := remove(datadir.QualifiedName(), datadir.Version); err != nil {
-			e = err
-			meter.Notify(fmt.Sprintf("unable to purge %s version %s: %s", datadir.QualifiedName(), datadir.Version, err.Error()))
+		qn := datadir.QualifiedName()
+		if helpers.FileExists(filepath.Join(dirs.SnapSnapsDir, qn)) {
+			continue
+		}
+		if err := removeDownloadCache(qn); err != nil {
+			meter.Notify(fmt.Sprintf("unable to clean the download cache of %s: %s", qn, err))
+		}
+		if err := removeHold(qn); err != nil {
+			meter.Notify(fmt.Sprintf("unable to remove the hold of %s: %s", qn, err))
 		}
 	}
 
@@ -96,3 +125,31 @@ func Purge(partSpec string, flags PurgeFlags, meter progress.Meter) error {
 
 	return e
 }
+
+// snapshotPurged saves the data about to be purged in snapshots that
+// expire after the time set by the GC policy.
+func snapshotPurged(datadirs []SnapDataDir) error {
+	policy, err := ReadGCPolicy()
+	if err != nil {
+		return err
+	}
+	expires := time.Now().Add(policy.snapshotExpiry).UTC()
+
+	expireSnapshots(false)
+
+	// the data of a version is in a datadir per user and the system one
+	saved := make(map[string]bool)
+	for _, datadir := range datadirs {
+		key := datadir.QualifiedName() + "=" + datadir.Version
+		if saved[key] {
+			continue
+		}
+		saved[key] = true
+
+		if _, err := saveSnapshot(datadir.QualifiedName(), datadir.Version, &expires); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
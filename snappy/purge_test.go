@@ -20,10 +20,12 @@
 package snappy
 
 import (
+	"bytes"
 	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -248,5 +250,80 @@ func (s *purgeSuite) TestPurgeRemovedWorks(c *C) {
 	}
 }
 
+func (s *purgeSuite) TestPurgeKeepsASnapshot(c *C) {
+	ddirs, _ := s.mkpkg(c)
+	writeGCPolicy(c, "snapshot-expiry: 1h\n")
+
+	err := Purge("hello-app", 0, &MockProgressMeter{})
+	c.Assert(err, IsNil)
+	for _, ddir := range ddirs {
+		c.Check(helpers.FileExists(ddir), Equals, false)
+	}
+
+	snapshots, err := Snapshots(nil)
+	c.Assert(err, IsNil)
+	c.Assert(snapshots, HasLen, 1)
+	c.Check(snapshots[0].Name, Equals, "hello-app")
+	c.Check(snapshots[0].Version, Equals, "1.10")
+	c.Check(snapshots[0].Users, DeepEquals, []string{"user1"})
+	c.Assert(snapshots[0].Expires, NotNil)
+	c.Check(snapshots[0].Expires.Sub(snapshots[0].Time) > 59*time.Minute, Equals, true)
+	c.Check(snapshots[0].Expires.Sub(snapshots[0].Time) < 61*time.Minute, Equals, true)
+
+	// the purge can be undone
+	c.Assert(RestoreSnapshot(snapshots[0].ID, ""), IsNil)
+	for _, ddir := range ddirs {
+		c.Check(helpers.FileExists(filepath.Join(ddir, "canary.txt")), Equals, true)
+	}
+}
+
+func (s *purgeSuite) TestPurgeNoSnapshot(c *C) {
+	ddirs, _ := s.mkpkg(c)
+
+	err := Purge("hello-app", DoPurgeNoSnapshot, &MockProgressMeter{})
+	c.Assert(err, IsNil)
+	for _, ddir := range ddirs {
+		c.Check(helpers.FileExists(ddir), Equals, false)
+	}
+
+	snapshots, err := Snapshots(nil)
+	c.Assert(err, IsNil)
+	c.Check(snapshots, HasLen, 0)
+}
+
+func (s *purgeSuite) TestPurgeKeepsDataIfSnapshotFails(c *C) {
+	ddirs, _ := s.mkpkg(c)
+	writeGCPolicy(c, "snapshot-expiry: never\n")
+
+	err := Purge("hello-app", 0, &MockProgressMeter{})
+	c.Check(err, ErrorMatches, `cannot use gc policy from .*`)
+	for _, ddir := range ddirs {
+		c.Check(helpers.FileExists(ddir), Equals, true)
+	}
+}
+
+func (s *purgeSuite) TestPurgeExpiresSnapshots(c *C) {
+	s.mkpkg(c)
+	s.mkpkg(c, "2.0")
+
+	c.Assert(Purge("hello-app=1.10", 0, &MockProgressMeter{}), IsNil)
+	snapshots, err := Snapshots(nil)
+	c.Assert(err, IsNil)
+	c.Assert(snapshots, HasLen, 1)
+
+	// the snapshot expired
+	past := time.Now().Add(-time.Minute)
+	snapshots[0].Expires = &past
+	c.Assert(os.Remove(snapshotPath(1)), IsNil)
+	c.Assert(writeSnapshot(snapshotPath(1), snapshots[0], bytes.NewReader(make([]byte, snapshots[0].Size))), IsNil)
+
+	c.Assert(Purge("hello-app=2.0", 0, &MockProgressMeter{}), IsNil)
+	snapshots, err = Snapshots(nil)
+	c.Assert(err, IsNil)
+	c.Assert(snapshots, HasLen, 1)
+	c.Check(snapshots[0].ID, Equals, 1)
+	c.Check(snapshots[0].Version, Equals, "2.0")
+}
+
 func (s *purgeSuite) TestPurgeBogusNameFails(c *C) {
 }
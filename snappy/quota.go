@@ -0,0 +1,437 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/progress"
+)
+
+// The ways a quota is enforced
+const (
+	// QuotaEnforcementProject is a filesystem project quota on the
+	// data directory: writes beyond the limit fail
+	QuotaEnforcementProject = "project"
+	// QuotaEnforcementSoft is used where project quotas are not
+	// available: the usage is measured, and warned about when the data
+	// is over the limit
+	QuotaEnforcementSoft = "soft"
+)
+
+var (
+	// quotaWarnPercent is the percentage of its quota from which the
+	// usage of a snap is warned about
+	quotaWarnPercent uint64 = 90
+	// quotaFirstProjectID is the project id of the first quota
+	quotaFirstProjectID uint32 = 1000
+)
+
+// Quota limits the size of the data directory of a snap (that of the
+// system, not the ones of the users)
+type Quota struct {
+	Snap  string `json:"snap"`
+	Limit uint64 `json:"limit"`
+	// ProjectID is the id of the project quota, 0 if the quota is
+	// enforced softly
+	ProjectID uint32 `json:"project-id,omitempty"`
+}
+
+// Enforcement returns how the quota is enforced
+func (q *Quota) Enforcement() string {
+	if q.ProjectID != 0 {
+		return QuotaEnforcementProject
+	}
+
+	return QuotaEnforcementSoft
+}
+
+// QuotaUsage is how much of its quota a version of a snap uses
+type QuotaUsage struct {
+	Snap        string `json:"snap"`
+	Version     string `json:"version"`
+	Limit       uint64 `json:"limit"`
+	Usage       uint64 `json:"usage"`
+	Enforcement string `json:"enforcement"`
+}
+
+// Percent returns the percentage of the quota that is used
+func (u *QuotaUsage) Percent() uint64 {
+	if u.Limit == 0 {
+		return 0
+	}
+
+	return u.Usage * 100 / u.Limit
+}
+
+// Warn returns whether the usage is close to, or over, the limit
+func (u *QuotaUsage) Warn() bool {
+	return u.Percent() >= quotaWarnPercent
+}
+
+// Exceeded returns whether the usage is over the limit
+func (u *QuotaUsage) Exceeded() bool {
+	return u.Usage > u.Limit
+}
+
+// quotaPath returns the path of the file that records the quota of the
+// given snap
+func quotaPath(qualifiedName string) string {
+	return filepath.Join(dirs.SnapMetaDir, qualifiedName+".quota")
+}
+
+// quotaDataDir returns the data directory the quota of the given version
+// of the given snap applies to
+func quotaDataDir(qualifiedName, version string) string {
+	return filepath.Join(dirs.SnapDataDir, qualifiedName, version)
+}
+
+// otherQuotaDataDirs returns the data directories of the versions of the
+// given snap other than the given one, or all of them if the version is
+// empty
+func otherQuotaDataDirs(qualifiedName, version string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapDataDir, qualifiedName, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var others []string
+	for _, match := range matches {
+		if filepath.Base(match) == version {
+			continue
+		}
+		// skips the "current" symlink
+		if fi, err := os.Lstat(match); err != nil || !fi.IsDir() {
+			continue
+		}
+		others = append(others, match)
+	}
+
+	return others, nil
+}
+
+// releaseQuotaDataDirs takes the given data directories out of the
+// project of their quota, so they no longer count against it
+func releaseQuotaDataDirs(qualifiedName string, dataDirs []string) {
+	for _, dataDir := range dataDirs {
+		if err := clearProjectQuota(dataDir); err != nil {
+			logger.Noticef("Cannot take %s out of the quota of %s: %v", dataDir, qualifiedName, err)
+		}
+	}
+}
+
+// SnapQuota returns the quota of the snap with the given qualified name,
+// or nil if it has none
+func SnapQuota(qualifiedName string) *Quota {
+	content, err := ioutil.ReadFile(quotaPath(qualifiedName))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Noticef("Ignoring the quota of %s: %v", qualifiedName, err)
+		}
+		return nil
+	}
+
+	var quota Quota
+	if err := json.Unmarshal(content, &quota); err != nil {
+		logger.Noticef("Ignoring the quota of %s: cannot parse it: %v", qualifiedName, err)
+		return nil
+	}
+
+	return &quota
+}
+
+// Quotas returns the quotas of all the snaps that have one
+func Quotas() ([]*Quota, error) {
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapMetaDir, "*.quota"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	quotas := make([]*Quota, 0, len(matches))
+	for _, match := range matches {
+		quota := SnapQuota(strings.TrimSuffix(filepath.Base(match), ".quota"))
+		if quota != nil {
+			quotas = append(quotas, quota)
+		}
+	}
+
+	return quotas, nil
+}
+
+// activePartByName returns the active version of the installed snap with
+// the given name
+func activePartByName(name string) (Part, error) {
+	installed, err := NewLocalSnapRepository().Installed()
+	if err != nil {
+		return nil, err
+	}
+	parts := FindSnapsByName(name, installed)
+	if len(parts) == 0 {
+		return nil, ErrNotInstalled
+	}
+	for _, part := range parts {
+		if part.IsActive() {
+			return part, nil
+		}
+	}
+
+	return nil, ErrPackageNotFound
+}
+
+// nextQuotaProjectID returns a project id no other quota uses
+func nextQuotaProjectID() (uint32, error) {
+	quotas, err := Quotas()
+	if err != nil {
+		return 0, err
+	}
+
+	id := quotaFirstProjectID
+	for _, quota := range quotas {
+		if quota.ProjectID >= id {
+			id = quota.ProjectID + 1
+		}
+	}
+
+	return id, nil
+}
+
+// SetQuota limits the data directory of the active version of the
+// installed snap with the given name to the given number of bytes. A
+// project quota is used if the filesystem supports it, otherwise the
+// quota is enforced softly. An existing quota of the snap is replaced.
+func SetQuota(name string, limit uint64) (*Quota, error) {
+	part, err := activePartByName(name)
+	if err != nil {
+		return nil, err
+	}
+	qn := QualifiedName(part)
+	if limit == 0 {
+		return nil, fmt.Errorf("cannot set a quota of 0 bytes on %s", qn)
+	}
+
+	quota := &Quota{Snap: qn, Limit: limit}
+	if old := SnapQuota(qn); old != nil {
+		quota.ProjectID = old.ProjectID
+	}
+	if quota.ProjectID == 0 {
+		if quota.ProjectID, err = nextQuotaProjectID(); err != nil {
+			return nil, err
+		}
+	}
+
+	dataDir := quotaDataDir(qn, part.Version())
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := setProjectQuota(dataDir, quota.ProjectID, limit); err != nil {
+		logger.Noticef("Enforcing the quota of %s softly: %v", qn, err)
+		quota.ProjectID = 0
+	}
+
+	content, err := json.Marshal(quota)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dirs.SnapMetaDir, 0755); err != nil {
+		return nil, err
+	}
+	if err := helpers.AtomicWriteFile(quotaPath(qn), content, 0644, 0); err != nil {
+		return nil, err
+	}
+
+	return quota, nil
+}
+
+// RemoveQuota removes the quota of the installed snap with the given name
+func RemoveQuota(name string) error {
+	part, err := activePartByName(name)
+	if err != nil {
+		return err
+	}
+	qn := QualifiedName(part)
+
+	quota := SnapQuota(qn)
+	if quota == nil {
+		return nil
+	}
+	if quota.ProjectID != 0 {
+		// nothing may be left in the project, or the snap that gets
+		// its id next would inherit the usage
+		dataDirs, err := otherQuotaDataDirs(qn, "")
+		if err != nil {
+			return err
+		}
+		releaseQuotaDataDirs(qn, dataDirs)
+	}
+
+	if err := os.Remove(quotaPath(qn)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// SnapQuotaUsage returns how much of its quota the given version of a
+// snap uses, or nil if it has no quota. Only the data directory of the
+// active version is in the project of the quota, so that is all that is
+// measured.
+func SnapQuotaUsage(part Part) (*QuotaUsage, error) {
+	qn := QualifiedName(part)
+	quota := SnapQuota(qn)
+	if quota == nil {
+		return nil, nil
+	}
+
+	usage, err := diskUsage(quotaDataDir(qn, part.Version()))
+	if err != nil {
+		return nil, err
+	}
+
+	return &QuotaUsage{
+		Snap:        qn,
+		Version:     part.Version(),
+		Limit:       quota.Limit,
+		Usage:       usage,
+		Enforcement: quota.Enforcement(),
+	}, nil
+}
+
+// QuotaUsages returns how much of their quota the active snaps that have
+// one use
+func QuotaUsages() ([]*QuotaUsage, error) {
+	installed, err := NewLocalSnapRepository().Installed()
+	if err != nil {
+		return nil, err
+	}
+
+	var usages []*QuotaUsage
+	for _, part := range installed {
+		if !part.IsActive() {
+			continue
+		}
+		usage, err := SnapQuotaUsage(part)
+		if err != nil {
+			return nil, err
+		}
+		if usage != nil {
+			usages = append(usages, usage)
+		}
+	}
+	sort.Sort(quotaUsagesBySnap(usages))
+
+	return usages, nil
+}
+
+type quotaUsagesBySnap []*QuotaUsage
+
+func (s quotaUsagesBySnap) Len() int           { return len(s) }
+func (s quotaUsagesBySnap) Less(i, j int) bool { return s[i].Snap < s[j].Snap }
+func (s quotaUsagesBySnap) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// applySnapQuota applies the quota of the given snap, if any, to the data
+// directory of the given version that is being made active; the data of
+// the other versions is taken out of the project, so the kept copies do
+// not count against the quota. If the project quota cannot be applied the
+// quota is enforced softly: the data is only measured, and a warning
+// given if it does not fit in it.
+func applySnapQuota(qualifiedName, version string, meter progress.Meter) error {
+	quota := SnapQuota(qualifiedName)
+	if quota == nil {
+		return nil
+	}
+
+	dataDir := quotaDataDir(qualifiedName, version)
+	if quota.ProjectID != 0 {
+		err := setProjectQuota(dataDir, quota.ProjectID, quota.Limit)
+		if err == nil {
+			others, err := otherQuotaDataDirs(qualifiedName, version)
+			if err != nil {
+				return err
+			}
+			releaseQuotaDataDirs(qualifiedName, others)
+			return nil
+		}
+		logger.Noticef("Enforcing the quota of %s softly: %v", qualifiedName, err)
+	}
+
+	usage, err := diskUsage(dataDir)
+	if err != nil {
+		return err
+	}
+	if usage > quota.Limit {
+		msg := fmt.Sprintf("The data of %s uses %d bytes, over its quota of %d bytes.", qualifiedName, usage, quota.Limit)
+		logger.Noticef("%s", msg)
+		meter.Notify(msg)
+	}
+
+	return nil
+}
+
+// var to make testing easier
+var setProjectQuota = setProjectQuotaImpl
+
+// setProjectQuotaImpl puts the given directory, and everything that is
+// created below it, in the given project and limits the project to the
+// given number of bytes; a limit of 0 means no limit
+func setProjectQuotaImpl(dir string, id uint32, limit uint64) error {
+	output, err := exec.Command("findmnt", "-n", "-o", "TARGET", "--target", dir).Output()
+	if err != nil {
+		return fmt.Errorf("cannot find the filesystem of %s: %v", dir, err)
+	}
+	mountpoint := strings.TrimSpace(string(output))
+
+	project := strconv.FormatUint(uint64(id), 10)
+	if output, err := exec.Command("chattr", "-R", "+P", "-p", project, dir).CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot put %s in project %s: %s", dir, project, strings.TrimSpace(string(output)))
+	}
+
+	// setquota takes the limits in blocks of 1KiB
+	blocks := strconv.FormatUint((limit+1023)/1024, 10)
+	if output, err := exec.Command("setquota", "-P", project, "0", blocks, "0", "0", mountpoint).CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot set the quota of project %s: %s", project, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// var to make testing easier
+var clearProjectQuota = clearProjectQuotaImpl
+
+// clearProjectQuotaImpl takes the given directory, and everything below
+// it, out of its project
+func clearProjectQuotaImpl(dir string) error {
+	if output, err := exec.Command("chattr", "-R", "-P", "-p", "0", dir).CombinedOutput(); err != nil {
+		return fmt.Errorf("cannot take %s out of its project: %s", dir, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+)
+
+type projectQuotaCall struct {
+	dir   string
+	id    uint32
+	limit uint64
+}
+
+// mockProjectQuota makes setting project quotas fail with the given
+// error, and returns the calls made; taking a directory out of its
+// project is recorded as a call with no id
+func mockProjectQuota(err error) *[]projectQuotaCall {
+	var calls []projectQuotaCall
+	setProjectQuota = func(dir string, id uint32, limit uint64) error {
+		calls = append(calls, projectQuotaCall{dir, id, limit})
+		return err
+	}
+	clearProjectQuota = func(dir string) error {
+		calls = append(calls, projectQuotaCall{dir: dir})
+		return nil
+	}
+
+	return &calls
+}
+
+func (s *SnapTestSuite) writeFooData(c *C, version string, size int) {
+	dataDir := filepath.Join(dirs.SnapDataDir, "foo."+testOrigin, version)
+	c.Assert(os.MkdirAll(dataDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dataDir, "data"), make([]byte, size), 0644), IsNil)
+}
+
+func (s *SnapTestSuite) TestSetQuotaProject(c *C) {
+	s.makeInstalledFoo(c)
+	calls := mockProjectQuota(nil)
+	dataDir := filepath.Join(dirs.SnapDataDir, "foo."+testOrigin, "1")
+
+	c.Check(SnapQuota("foo."+testOrigin), IsNil)
+
+	quota, err := SetQuota("foo", 1000)
+	c.Assert(err, IsNil)
+	c.Check(quota, DeepEquals, &Quota{Snap: "foo." + testOrigin, Limit: 1000, ProjectID: quotaFirstProjectID})
+	c.Check(quota.Enforcement(), Equals, QuotaEnforcementProject)
+	c.Check(SnapQuota("foo."+testOrigin), DeepEquals, quota)
+
+	// a new quota replaces the old one, in the same project
+	quota, err = SetQuota("foo."+testOrigin, 2000)
+	c.Assert(err, IsNil)
+	c.Check(quota.ProjectID, Equals, quotaFirstProjectID)
+
+	quotas, err := Quotas()
+	c.Assert(err, IsNil)
+	c.Check(quotas, DeepEquals, []*Quota{quota})
+
+	c.Assert(RemoveQuota("foo"), IsNil)
+	c.Check(SnapQuota("foo."+testOrigin), IsNil)
+	c.Check(*calls, DeepEquals, []projectQuotaCall{
+		{dataDir, quotaFirstProjectID, 1000},
+		{dataDir, quotaFirstProjectID, 2000},
+		// nothing is left in the project
+		{dir: dataDir},
+	})
+
+	// removing it again is fine
+	c.Check(RemoveQuota("foo"), IsNil)
+}
+
+func (s *SnapTestSuite) TestSetQuotaSoft(c *C) {
+	s.makeInstalledFoo(c)
+	calls := mockProjectQuota(errors.New("no project quotas here"))
+
+	quota, err := SetQuota("foo", 1000)
+	c.Assert(err, IsNil)
+	c.Check(quota, DeepEquals, &Quota{Snap: "foo." + testOrigin, Limit: 1000})
+	c.Check(quota.Enforcement(), Equals, QuotaEnforcementSoft)
+
+	// there is no project quota to lift
+	c.Assert(RemoveQuota("foo"), IsNil)
+	c.Check(*calls, HasLen, 1)
+}
+
+func (s *SnapTestSuite) TestSetQuotaErrors(c *C) {
+	mockProjectQuota(nil)
+
+	_, err := SetQuota("foo", 1000)
+	c.Check(err, Equals, ErrNotInstalled)
+	c.Check(RemoveQuota("foo"), Equals, ErrNotInstalled)
+
+	s.makeInstalledFoo(c)
+	_, err = SetQuota("foo", 0)
+	c.Check(err, ErrorMatches, `cannot set a quota of 0 bytes on foo\..*`)
+}
+
+func (s *SnapTestSuite) TestQuotaUsages(c *C) {
+	s.makeInstalledFoo(c)
+	mockProjectQuota(nil)
+	s.writeFooData(c, "1", 950)
+
+	usages, err := QuotaUsages()
+	c.Assert(err, IsNil)
+	c.Check(usages, HasLen, 0)
+
+	// the usage is what du says, the limit puts it at 95%
+	used, err := diskUsage(filepath.Join(dirs.SnapDataDir, "foo."+testOrigin, "1"))
+	c.Assert(err, IsNil)
+	c.Assert(used >= 950, Equals, true)
+	limit := used + used/19
+	_, err = SetQuota("foo", limit)
+	c.Assert(err, IsNil)
+
+	usages, err = QuotaUsages()
+	c.Assert(err, IsNil)
+	c.Assert(usages, HasLen, 1)
+	c.Check(usages[0], DeepEquals, &QuotaUsage{
+		Snap:        "foo." + testOrigin,
+		Version:     "1",
+		Limit:       limit,
+		Usage:       used,
+		Enforcement: QuotaEnforcementProject,
+	})
+	c.Check(usages[0].Percent(), Equals, uint64(95))
+	c.Check(usages[0].Warn(), Equals, true)
+	c.Check(usages[0].Exceeded(), Equals, false)
+}
+
+func (s *SnapTestSuite) TestApplySnapQuota(c *C) {
+	qn := "foo." + testOrigin
+	meter := &MockProgressMeter{}
+
+	// no quota, nothing to do
+	c.Check(applySnapQuota(qn, "2", meter), IsNil)
+
+	s.makeInstalledFoo(c)
+	mockProjectQuota(errors.New("no project quotas here"))
+	_, err := SetQuota("foo", 100000)
+	c.Assert(err, IsNil)
+
+	s.writeFooData(c, "2", 50)
+	c.Check(applySnapQuota(qn, "2", meter), IsNil)
+	c.Check(meter.notified, HasLen, 0)
+
+	// going over a soft quota is only warned about
+	s.writeFooData(c, "2", 200000)
+	c.Check(applySnapQuota(qn, "2", meter), IsNil)
+	c.Assert(meter.notified, HasLen, 1)
+	c.Check(meter.notified[0], Matches, `The data of foo\..* uses \d+ bytes, over its quota of 100000 bytes\.`)
+}
+
+func (s *SnapTestSuite) TestApplySnapQuotaProject(c *C) {
+	s.makeInstalledFoo(c)
+	calls := mockProjectQuota(nil)
+	_, err := SetQuota("foo", 100)
+	c.Assert(err, IsNil)
+
+	// the project quota takes care of it, and the data of the old
+	// version no longer counts against it
+	meter := &MockProgressMeter{}
+	s.writeFooData(c, "1", 50)
+	s.writeFooData(c, "2", 200)
+	c.Assert(os.Symlink("2", filepath.Join(dirs.SnapDataDir, "foo."+testOrigin, "current")), IsNil)
+	c.Check(applySnapQuota("foo."+testOrigin, "2", meter), IsNil)
+	c.Check((*calls)[1:], DeepEquals, []projectQuotaCall{
+		{filepath.Join(dirs.SnapDataDir, "foo."+testOrigin, "2"), quotaFirstProjectID, 100},
+		{dir: filepath.Join(dirs.SnapDataDir, "foo."+testOrigin, "1")},
+	})
+	c.Check(meter.notified, HasLen, 0)
+
+	// and if it cannot be applied the quota is enforced softly
+	mockProjectQuota(errors.New("no project quotas here"))
+	c.Check(applySnapQuota("foo."+testOrigin, "2", meter), IsNil)
+	c.Check(meter.notified, HasLen, 1)
+}
+
+func (s *SnapTestSuite) TestInstallOverSoftQuota(c *C) {
+	canary := s.installFooWithData(c)
+	mockProjectQuota(errors.New("no project quotas here"))
+	_, err := SetQuota("foo", 4)
+	c.Assert(err, IsNil)
+
+	// the update is not blocked, only warned about
+	meter := &MockProgressMeter{}
+	snapFile := makeTestSnapPackage(c, "name: foo\nversion: 2.0\n")
+	_, err = installClick(snapFile, AllowUnauthenticated, meter, testOrigin)
+	c.Assert(err, IsNil)
+	c.Check(meter.notified, HasLen, 1)
+
+	current, err := os.Readlink(filepath.Join(dirs.SnapSnapsDir, "foo."+testOrigin, "current"))
+	c.Assert(err, IsNil)
+	c.Check(current, Equals, "2.0")
+	c.Check(helpers.FileExists(canary), Equals, true)
+}
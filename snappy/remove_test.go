@@ -20,8 +20,14 @@
 package snappy
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
 	. "gopkg.in/check.v1"
 
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
 	"github.com/ubuntu-core/snappy/progress"
 	"github.com/ubuntu-core/snappy/snap"
 )
@@ -82,3 +88,20 @@ func (s *SnapTestSuite) TestSnapRemoveGC(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(installed, HasLen, 0)
 }
+
+func (s *SnapTestSuite) TestSnapRemoveCleansDownloadCache(c *C) {
+	makeTwoTestSnaps(c, snap.TypeApp)
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0755), IsNil)
+	cached := filepath.Join(dirs.SnapDownloadCacheDir, "foo."+testOrigin+"_2.snap")
+	c.Assert(ioutil.WriteFile(cached, []byte("blob"), 0644), IsNil)
+	other := filepath.Join(dirs.SnapDownloadCacheDir, "bar."+testOrigin+"_1.snap")
+	c.Assert(ioutil.WriteFile(other, []byte("blob"), 0644), IsNil)
+
+	// a version is left, the download is kept
+	c.Assert(Remove("foo=1.0", 0, &progress.NullProgress{}), IsNil)
+	c.Check(helpers.FileExists(cached), Equals, true)
+
+	c.Assert(Remove("foo", 0, &progress.NullProgress{}), IsNil)
+	c.Check(helpers.FileExists(cached), Equals, false)
+	c.Check(helpers.FileExists(other), Equals, true)
+}
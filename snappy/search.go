@@ -19,7 +19,13 @@
 
 package snappy
 
-import "strings"
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ubuntu-core/snappy/snap"
+)
 
 // Search searches all repositories with the given keywords in the args slice
 func Search(args []string) (SharedNames, error) {
@@ -27,3 +33,149 @@ func Search(args []string) (SharedNames, error) {
 
 	return m.Search(strings.Join(args, ","))
 }
+
+// The keys store searches can be sorted by.
+const (
+	SortByName        = "name"
+	SortByLastUpdated = "last_updated"
+	SortByRatings     = "ratings"
+)
+
+// storeSortKeys maps the sort keys to what the store sorts by; recently
+// updated and well rated snaps come first.
+var storeSortKeys = map[string]string{
+	SortByName:        "package_name",
+	SortByLastUpdated: "-last_updated",
+	SortByRatings:     "-ratings_average",
+}
+
+// SearchOptions narrows down and orders a search of the store.
+type SearchOptions struct {
+	// Query is matched against the name of the snaps, empty finds all.
+	Query string
+	// Types restricts the search to snaps of the given types.
+	Types []snap.Type
+	// Sort is one of the SortBy* keys, empty leaves the order to the store.
+	Sort string
+	// Page is the page to return, starting from 1; 0 is the first page.
+	Page int
+	// Size is the number of snaps per page, 0 leaves it to the store.
+	Size int
+}
+
+func (opts *SearchOptions) validate() error {
+	if opts.Sort != "" && storeSortKeys[opts.Sort] == "" {
+		return fmt.Errorf("cannot sort search results by %q", opts.Sort)
+	}
+	if opts.Page < 0 {
+		return fmt.Errorf("cannot search for page %d", opts.Page)
+	}
+	if opts.Size < 0 {
+		return fmt.Errorf("cannot search with a page size of %d", opts.Size)
+	}
+
+	return nil
+}
+
+// A SearchPage is one page of the results of a search of the store.
+type SearchPage struct {
+	Parts []Part
+	// Page is the number of this page, starting from 1.
+	Page int
+	// Pages is the number of pages the search has.
+	Pages int
+}
+
+// hasType checks whether the part is of one of the given types, any type
+// will do if none is given.
+func hasType(part Part, types []snap.Type) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, t := range types {
+		if part.Type() == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// searchPage filters, sorts and pages through the given remote parts the
+// way the store would for the given options.
+func searchPage(parts []*RemoteSnapPart, opts SearchOptions) *SearchPage {
+	found := make([]*RemoteSnapPart, 0, len(parts))
+	for _, part := range parts {
+		if hasType(part, opts.Types) {
+			found = append(found, part)
+		}
+	}
+
+	switch opts.Sort {
+	case SortByName:
+		sort.Sort(byRemoteName(found))
+	case SortByLastUpdated:
+		sort.Stable(byRemoteLastUpdated(found))
+	case SortByRatings:
+		sort.Stable(byRemoteRatings(found))
+	}
+
+	page := opts.Page
+	if page < 1 {
+		page = 1
+	}
+	size := opts.Size
+	if size < 1 {
+		size = len(found)
+	}
+
+	pages := 1
+	if size > 0 {
+		pages = (len(found) + size - 1) / size
+	}
+	if pages < 1 {
+		pages = 1
+	}
+
+	start := (page - 1) * size
+	if start > len(found) {
+		start = len(found)
+	}
+	end := start + size
+	if end > len(found) {
+		end = len(found)
+	}
+
+	result := &SearchPage{
+		Parts: make([]Part, 0, end-start),
+		Page:  page,
+		Pages: pages,
+	}
+	for _, part := range found[start:end] {
+		result.Parts = append(result.Parts, part)
+	}
+
+	return result
+}
+
+type byRemoteName []*RemoteSnapPart
+
+func (ps byRemoteName) Len() int           { return len(ps) }
+func (ps byRemoteName) Swap(a, b int)      { ps[a], ps[b] = ps[b], ps[a] }
+func (ps byRemoteName) Less(a, b int) bool { return ps[a].pkg.Name < ps[b].pkg.Name }
+
+type byRemoteLastUpdated []*RemoteSnapPart
+
+func (ps byRemoteLastUpdated) Len() int      { return len(ps) }
+func (ps byRemoteLastUpdated) Swap(a, b int) { ps[a], ps[b] = ps[b], ps[a] }
+func (ps byRemoteLastUpdated) Less(a, b int) bool {
+	return ps[a].Date().After(ps[b].Date())
+}
+
+type byRemoteRatings []*RemoteSnapPart
+
+func (ps byRemoteRatings) Len() int      { return len(ps) }
+func (ps byRemoteRatings) Swap(a, b int) { ps[a], ps[b] = ps[b], ps[a] }
+func (ps byRemoteRatings) Less(a, b int) bool {
+	return ps[a].pkg.RatingsAverage > ps[b].pkg.RatingsAverage
+}
@@ -609,6 +609,12 @@ func removePolicy(m *snapYaml, baseDir string) error {
 		return err
 	}
 
+	for _, name := range []string{postRefreshHookProfile, healthCheckProfile} {
+		if err := removeOneSecurityPolicy(m, name, baseDir); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -768,6 +774,14 @@ func generatePolicy(m *snapYaml, baseDir string) error {
 		}
 	}
 
+	// and for the other hooks and the health check
+	for _, name := range hookProfiles(m, baseDir) {
+		if err := snappyConfig.generatePolicyForServiceBinary(m, name, baseDir); err != nil {
+			foundError = err
+			logger.Noticef("Failed to obtain APP_ID for %s: %v", name, err)
+		}
+	}
+
 	for _, app := range m.Apps {
 		skill, err := findSkillForApp(m, app)
 		if err != nil {
@@ -902,6 +916,16 @@ func CompareGeneratePolicyFromFile(fn string) error {
 		}
 	}
 
+	for _, name := range hookProfiles(m, baseDir) {
+		p, err := snappyConfig.generatePolicyForServiceBinaryResult(m, name, baseDir)
+		if err != nil {
+			return nil
+		}
+		if err := comparePolicyToCurrent(p); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,303 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ubuntu-core/snappy/asserts"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/progress"
+	"github.com/ubuntu-core/snappy/snap"
+	"github.com/ubuntu-core/snappy/snap/remote"
+)
+
+// SnapDirStoreRepository is a store that serves snaps from a local
+// directory, e.g. a mounted usb stick, for systems without network access.
+//
+// For every snap the directory contains a "<name>.<origin>.json" file with
+// the same details the ubuntu store sends. The snap itself is found at the
+// download_url of the details, relative to the directory, or at
+// "<name>.<origin>_<revision>.snap" if it has none. Assertions about the
+// snap are read from "<name>.<origin>_<revision>.assert" and added to the
+// system assertion database before the snap is installed.
+type SnapDirStoreRepository struct {
+	dir string
+}
+
+// NewDirStoreSnapRepository creates a new SnapDirStoreRepository for the
+// given directory
+func NewDirStoreSnapRepository(dir string) *SnapDirStoreRepository {
+	return &SnapDirStoreRepository{dir: dir}
+}
+
+// details reads the store details of the given snap
+func (s *SnapDirStoreRepository) details(path string) (*remote.Snap, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var data remote.Snap
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, fmt.Errorf("cannot read details from %q: %v", path, err)
+	}
+
+	return &data, nil
+}
+
+// all returns the details of all the snaps in the directory
+func (s *SnapDirStoreRepository) all() ([]*remote.Snap, error) {
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	snaps := make([]*remote.Snap, 0, len(matches))
+	for _, match := range matches {
+		data, err := s.details(match)
+		if err != nil {
+			return nil, err
+		}
+		snaps = append(snaps, data)
+	}
+
+	return snaps, nil
+}
+
+// Snap returns the RemoteSnapPart for the given name or an error.
+// Directories hold a single channel, so the channel is ignored.
+func (s *SnapDirStoreRepository) Snap(snapName, channel string) (*RemoteSnapPart, error) {
+	name, origin := SplitOrigin(snapName)
+	if origin != "" {
+		data, err := s.details(filepath.Join(s.dir, name+"."+origin+".json"))
+		if os.IsNotExist(err) {
+			return nil, ErrPackageNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return NewRemoteSnapPart(*data), nil
+	}
+
+	// without an origin prefer the alias, like the ubuntu store does
+	var found *remote.Snap
+	snaps, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range snaps {
+		if data.Name != name {
+			continue
+		}
+		if found == nil || data.Alias != "" {
+			found = data
+		}
+	}
+	if found == nil {
+		return nil, ErrPackageNotFound
+	}
+
+	return NewRemoteSnapPart(*found), nil
+}
+
+// Details returns details for the given snap in this repository
+func (s *SnapDirStoreRepository) Details(name string, origin string) ([]Part, error) {
+	snapName := name
+	if origin != "" {
+		snapName = name + "." + origin
+	}
+	snap, err := s.Snap(snapName, "")
+	if err != nil {
+		return nil, err
+	}
+	return []Part{snap}, nil
+}
+
+// Find (installable) parts from the directory, matching the given search
+// term.
+func (s *SnapDirStoreRepository) Find(searchTerm string) ([]Part, error) {
+	snaps, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []Part
+	for _, data := range snaps {
+		if searchTerm == "" || strings.Contains(data.Name, searchTerm) {
+			parts = append(parts, NewRemoteSnapPart(*data))
+		}
+	}
+
+	return parts, nil
+}
+
+// FindPage finds the given page of the (installable) parts from the
+// directory matching the search options.
+func (s *SnapDirStoreRepository) FindPage(opts SearchOptions) (*SearchPage, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	snaps, err := s.all()
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []*RemoteSnapPart
+	for _, data := range snaps {
+		if opts.Query == "" || strings.Contains(data.Name, opts.Query) {
+			parts = append(parts, NewRemoteSnapPart(*data))
+		}
+	}
+
+	return searchPage(parts, opts), nil
+}
+
+// Updates returns the available updates
+func (s *SnapDirStoreRepository) Updates() (parts []Part, err error) {
+	installed, err := ActiveSnapIterByType(FullName, snap.TypeApp, snap.TypeFramework, snap.TypeGadget, snap.TypeOS, snap.TypeKernel)
+	if err != nil || len(installed) == 0 {
+		return nil, err
+	}
+
+	for _, fullName := range installed {
+		data, err := s.details(filepath.Join(s.dir, fullName+".json"))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		current := ActiveSnapByName(data.Name)
+		if current == nil || current.Version() != data.Version {
+			parts = append(parts, NewRemoteSnapPart(*data))
+		}
+	}
+
+	return parts, nil
+}
+
+// SnapUpdate returns the available update of the given installed snap, or
+// nil if there is none.
+func (s *SnapDirStoreRepository) SnapUpdate(part Part) (*RemoteSnapPart, error) {
+	data, err := s.details(filepath.Join(s.dir, FullName(part)+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data.Version == part.Version() {
+		return nil, nil
+	}
+
+	return NewRemoteSnapPart(*data), nil
+}
+
+// path returns the path of the given snap and the given extension in the
+// directory
+func (s *SnapDirStoreRepository) path(remoteSnap *RemoteSnapPart, ext string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s.%s_%d%s", remoteSnap.pkg.Name, remoteSnap.pkg.Origin, remoteSnap.pkg.Revision, ext))
+}
+
+// Download returns the path of the given snap in the directory after
+// verifying its sha512. The snap is used in place and must not be removed.
+//
+// If the directory has a "<name>.<origin>_<from>_<to>.delta" file from the
+// active revision the snap is reconstructed from it into the download
+// cache instead.
+func (s *SnapDirStoreRepository) Download(remoteSnap *RemoteSnapPart, pbar progress.Meter) (string, error) {
+	if path, err := s.downloadDelta(remoteSnap); err == nil {
+		return path, nil
+	} else if err != errNoDelta {
+		logger.Noticef("Cannot update %s using a delta, using the full snap: %v", remoteSnap.Name(), err)
+	}
+
+	path := s.path(remoteSnap, ".snap")
+	if url := remoteSnap.pkg.DownloadURL; url != "" {
+		path = url
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(s.dir, path)
+		}
+	}
+
+	if !helpers.FileExists(path) {
+		return "", fmt.Errorf("cannot find %q", path)
+	}
+	if err := verifyDownload(path, remoteSnap.pkg.DownloadSha512); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+func (s *SnapDirStoreRepository) downloadDelta(remoteSnap *RemoteSnapPart) (string, error) {
+	if remoteSnap.pkg.DownloadSha512 == "" {
+		return "", errNoDelta
+	}
+	active := activeStorePart(remoteSnap.Name(), remoteSnap.Origin())
+	if active == nil {
+		return "", errNoDelta
+	}
+	delta := filepath.Join(s.dir, deltaName(remoteSnap, active.Revision()))
+	if !helpers.FileExists(delta) {
+		return "", errNoDelta
+	}
+
+	return reconstructFromDelta(remoteSnap, active, delta)
+}
+
+// Assertions returns the assertions about the given snap that are shipped
+// in the directory.
+func (s *SnapDirStoreRepository) Assertions(remoteSnap *RemoteSnapPart) ([]asserts.Assertion, error) {
+	f, err := os.Open(s.path(remoteSnap, ".assert"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var assertions []asserts.Assertion
+	dec := asserts.NewDecoder(f)
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read assertions of %s: %v", remoteSnap.Name(), err)
+		}
+		assertions = append(assertions, a)
+	}
+
+	return assertions, nil
+}
@@ -0,0 +1,347 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/asserts"
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/progress"
+	"github.com/ubuntu-core/snappy/snap"
+	"github.com/ubuntu-core/snappy/snap/remote"
+)
+
+type dirRepoTestSuite struct {
+	dir   string
+	store *SnapDirStoreRepository
+}
+
+var _ = Suite(&dirRepoTestSuite{})
+
+func (s *dirRepoTestSuite) SetUpTest(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(dirs.SnapSnapsDir, 0755), IsNil)
+
+	s.dir = c.MkDir()
+	s.store = NewDirStoreSnapRepository(s.dir)
+
+	s.makeSnap(c, "foo.bar", `{"package_name": "foo", "origin": "bar", "version": "1.0", "revision": 3, "alias": "foo"}`)
+	s.makeSnap(c, "foo.baz", `{"package_name": "foo", "origin": "baz", "version": "2.0", "revision": 1}`)
+	s.makeSnap(c, "hello.bar", `{"package_name": "hello", "origin": "bar", "version": "1.0", "revision": 7,
+"download_url": "blobs/hello.snap", "download_sha512": "`+sha512sum("hello")+`"}`)
+	c.Assert(os.MkdirAll(filepath.Join(s.dir, "blobs"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "blobs", "hello.snap"), []byte("hello"), 0644), IsNil)
+}
+
+func (s *dirRepoTestSuite) TearDownTest(c *C) {
+	ActiveSnapIterByType = activeSnapIterByTypeImpl
+}
+
+func (s *dirRepoTestSuite) makeSnap(c *C, fullName, details string) {
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, fullName+".json"), []byte(details), 0644), IsNil)
+}
+
+func (s *dirRepoTestSuite) TestSnap(c *C) {
+	part, err := s.store.Snap("foo.baz", "")
+	c.Assert(err, IsNil)
+	c.Check(part.Name(), Equals, "foo")
+	c.Check(part.Origin(), Equals, "baz")
+	c.Check(part.Version(), Equals, "2.0")
+
+	// without an origin the alias is picked
+	part, err = s.store.Snap("foo", "")
+	c.Assert(err, IsNil)
+	c.Check(part.Origin(), Equals, "bar")
+
+	_, err = s.store.Snap("foo.other", "")
+	c.Check(err, Equals, ErrPackageNotFound)
+	_, err = s.store.Snap("other", "")
+	c.Check(err, Equals, ErrPackageNotFound)
+}
+
+func (s *dirRepoTestSuite) TestSnapBrokenDetails(c *C) {
+	s.makeSnap(c, "broken.bar", "{")
+	_, err := s.store.Snap("broken.bar", "")
+	c.Check(err, ErrorMatches, `cannot read details from ".*/broken.bar.json": .*`)
+}
+
+func (s *dirRepoTestSuite) TestDetails(c *C) {
+	parts, err := s.store.Details("hello", "bar")
+	c.Assert(err, IsNil)
+	c.Assert(parts, HasLen, 1)
+	c.Check(parts[0].Name(), Equals, "hello")
+}
+
+func (s *dirRepoTestSuite) TestFind(c *C) {
+	parts, err := s.store.Find("")
+	c.Assert(err, IsNil)
+	c.Assert(parts, HasLen, 3)
+
+	parts, err = s.store.Find("ell")
+	c.Assert(err, IsNil)
+	c.Assert(parts, HasLen, 1)
+	c.Check(QualifiedName(parts[0]), Equals, "hello.bar")
+}
+
+func (s *dirRepoTestSuite) TestFindPage(c *C) {
+	s.makeSnap(c, "fwk.bar", `{"package_name": "fwk", "origin": "bar", "version": "1.0", "content": "framework"}`)
+	s.makeSnap(c, "app.bar", `{"package_name": "app", "origin": "bar", "version": "1.0", "content": "app", "ratings_average": 4.5}`)
+	s.makeSnap(c, "other.bar", `{"package_name": "other", "origin": "bar", "version": "1.0", "content": "app", "ratings_average": 3}`)
+
+	page, err := s.store.FindPage(SearchOptions{Types: []snap.Type{snap.TypeApp}, Sort: SortByRatings, Size: 1})
+	c.Assert(err, IsNil)
+	c.Check(page.Page, Equals, 1)
+	c.Check(page.Pages, Equals, 2)
+	c.Assert(page.Parts, HasLen, 1)
+	c.Check(page.Parts[0].Name(), Equals, "app")
+
+	page, err = s.store.FindPage(SearchOptions{Types: []snap.Type{snap.TypeApp}, Sort: SortByRatings, Page: 2, Size: 1})
+	c.Assert(err, IsNil)
+	c.Check(page.Page, Equals, 2)
+	c.Assert(page.Parts, HasLen, 1)
+	c.Check(page.Parts[0].Name(), Equals, "other")
+
+	page, err = s.store.FindPage(SearchOptions{Sort: SortByName})
+	c.Assert(err, IsNil)
+	c.Check(page.Pages, Equals, 1)
+	names := make([]string, len(page.Parts))
+	for i, part := range page.Parts {
+		names[i] = part.Name()
+	}
+	c.Check(names, DeepEquals, []string{"app", "foo", "foo", "fwk", "hello", "other"})
+
+	// past the last page there is nothing
+	page, err = s.store.FindPage(SearchOptions{Page: 3, Size: 6})
+	c.Assert(err, IsNil)
+	c.Check(page.Parts, HasLen, 0)
+
+	_, err = s.store.FindPage(SearchOptions{Size: -1})
+	c.Check(err, ErrorMatches, "cannot search with a page size of -1")
+}
+
+func (s *dirRepoTestSuite) TestUpdates(c *C) {
+	ActiveSnapIterByType = func(f func(Part) string, snapTs ...snap.Type) ([]string, error) {
+		return []string{"hello.bar", "other.bar"}, nil
+	}
+
+	parts, err := s.store.Updates()
+	c.Assert(err, IsNil)
+	c.Assert(parts, HasLen, 1)
+	c.Check(parts[0].Name(), Equals, "hello")
+}
+
+func (s *dirRepoTestSuite) TestSnapUpdate(c *C) {
+	part, err := s.store.Snap("hello.bar", "")
+	c.Assert(err, IsNil)
+	upd, err := s.store.SnapUpdate(part)
+	c.Assert(err, IsNil)
+	c.Check(upd, IsNil)
+
+	part = NewRemoteSnapPart(remote.Snap{Name: "hello", Origin: "bar", Version: "0.9"})
+	upd, err = s.store.SnapUpdate(part)
+	c.Assert(err, IsNil)
+	c.Assert(upd, NotNil)
+	c.Check(upd.Version(), Equals, "1.0")
+
+	part = NewRemoteSnapPart(remote.Snap{Name: "other", Origin: "bar", Version: "0.9"})
+	upd, err = s.store.SnapUpdate(part)
+	c.Assert(err, IsNil)
+	c.Check(upd, IsNil)
+}
+
+func (s *dirRepoTestSuite) TestDownload(c *C) {
+	part, err := s.store.Snap("hello.bar", "")
+	c.Assert(err, IsNil)
+
+	path, err := s.store.Download(part, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, filepath.Join(s.dir, "blobs", "hello.snap"))
+
+	c.Assert(ioutil.WriteFile(path, []byte("tampered"), 0644), IsNil)
+	_, err = s.store.Download(part, nil)
+	c.Check(err, Equals, ErrHashMismatch)
+}
+
+func (s *dirRepoTestSuite) TestDownloadDefaultPath(c *C) {
+	part, err := s.store.Snap("foo.bar", "")
+	c.Assert(err, IsNil)
+
+	_, err = s.store.Download(part, nil)
+	c.Check(err, ErrorMatches, `cannot find ".*/foo.bar_3.snap"`)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "foo.bar_3.snap"), []byte("foo"), 0644), IsNil)
+	path, err := s.store.Download(part, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, filepath.Join(s.dir, "foo.bar_3.snap"))
+}
+
+func (s *dirRepoTestSuite) TestDownloadDelta(c *C) {
+	makeActiveStoreSnap(c, 1, "I was ")
+	origApplyDelta := applyDelta
+	defer func() { applyDelta = origApplyDelta }()
+	mockApplyDelta(c)
+
+	s.makeSnap(c, "foo."+testOrigin, `{"package_name": "foo", "origin": "`+testOrigin+`", "version": "2", "revision": 2,
+"download_sha512": "`+sha512sum("I was downloaded")+`"}`)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "foo."+testOrigin+"_1_2.delta"), []byte("downloaded"), 0644), IsNil)
+
+	part, err := s.store.Snap("foo."+testOrigin, "")
+	c.Assert(err, IsNil)
+	path, err := s.store.Download(part, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, downloadCachePath(part))
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "I was downloaded")
+
+	// a delta that does not apply falls back to the full snap
+	c.Assert(os.Remove(path), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "foo."+testOrigin+"_1_2.delta"), []byte("broken"), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "foo."+testOrigin+"_2.snap"), []byte("I was downloaded"), 0644), IsNil)
+	path, err = s.store.Download(part, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, filepath.Join(s.dir, "foo."+testOrigin+"_2.snap"))
+}
+
+func (s *dirRepoTestSuite) TestAssertions(c *C) {
+	part, err := s.store.Snap("foo.bar", "")
+	c.Assert(err, IsNil)
+
+	// having no assertions is fine
+	assertions, err := s.store.Assertions(part)
+	c.Assert(err, IsNil)
+	c.Check(assertions, HasLen, 0)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(s.dir, "foo.bar_3.assert"), []byte("garbage"), 0644), IsNil)
+	_, err = s.store.Assertions(part)
+	c.Check(err, ErrorMatches, "cannot read assertions of foo: .*")
+}
+
+func (s *dirRepoTestSuite) TestNewStoreRepository(c *C) {
+	os.Setenv("SNAPPY_LOCAL_STORE", s.dir)
+	defer os.Unsetenv("SNAPPY_LOCAL_STORE")
+
+	store, ok := NewStoreRepository().(*SnapDirStoreRepository)
+	c.Assert(ok, Equals, true)
+	c.Check(store.dir, Equals, s.dir)
+
+	os.Unsetenv("SNAPPY_LOCAL_STORE")
+	_, ok = NewStoreRepository().(*SnapUbuntuStoreRepository)
+	c.Check(ok, Equals, true)
+}
+
+func (s *dirRepoTestSuite) TestNewStoreRepositoryIgnoresEnvWithModel(c *C) {
+	os.Setenv("SNAPPY_LOCAL_STORE", s.dir)
+	defer os.Unsetenv("SNAPPY_LOCAL_STORE")
+
+	origModelStore := modelStore
+	defer func() { modelStore = origModelStore }()
+
+	modelStore = func() (string, *url.URL, error) {
+		u, err := url.Parse("https://store.example.com/api/v1/")
+		return "brand-store", u, err
+	}
+	store, ok := NewStoreRepository().(*SnapUbuntuStoreRepository)
+	c.Assert(ok, Equals, true)
+	c.Check(store.StoreID(), Equals, "brand-store")
+
+	// nor when the model cannot be read
+	modelStore = func() (string, *url.URL, error) {
+		return "", nil, errors.New("broken assertion database")
+	}
+	_, ok = NewStoreRepository().(*SnapUbuntuStoreRepository)
+	c.Check(ok, Equals, true)
+}
+
+const testSnapAssertion = "type: model\n" +
+	"authority-id: brand-id1\n" +
+	"brand-id: brand-id1\n" +
+	"model: baz-3000\n" +
+	"series: 16\n" +
+	"os: core\n" +
+	"architecture: amd64\n" +
+	"gadget: brand-gadget\n" +
+	"kernel: baz-linux\n" +
+	"store: brand-store\n" +
+	"allowed-modes: \n" +
+	"required-snaps: \n" +
+	"class: fixed\n" +
+	"timestamp: 2016-01-02T10:00:00Z\n" +
+	"body-length: 0" +
+	"\n\n" +
+	"openpgp c2ln"
+
+// mockAddAssertions makes adding assertions record them instead, failing
+// with the given error
+func mockAddAssertions(added *[]asserts.Assertion, err error) (restore func()) {
+	orig := addAssertions
+	addAssertions = func(assertions []asserts.Assertion) error {
+		*added = append(*added, assertions...)
+		return err
+	}
+
+	return func() { addAssertions = orig }
+}
+
+func (s *SnapTestSuite) TestInstallAddsStoreAssertions(c *C) {
+	defer makeDirStore(c, map[string]string{"foo": "name: foo\nversion: 1"})()
+	assertFile := filepath.Join(os.Getenv("SNAPPY_LOCAL_STORE"), "foo."+testOrigin+"_1.assert")
+	c.Assert(ioutil.WriteFile(assertFile, []byte(testSnapAssertion), 0644), IsNil)
+
+	var added []asserts.Assertion
+	defer mockAddAssertions(&added, nil)()
+
+	_, err := Install("foo", "", 0, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Assert(added, HasLen, 1)
+	c.Check(added[0].Type(), Equals, asserts.ModelType)
+	c.Check(ActiveSnapByName("foo"), NotNil)
+}
+
+func (s *SnapTestSuite) TestInstallFailsIfStoreAssertionsFail(c *C) {
+	defer makeDirStore(c, map[string]string{"foo": "name: foo\nversion: 1"})()
+	assertFile := filepath.Join(os.Getenv("SNAPPY_LOCAL_STORE"), "foo."+testOrigin+"_1.assert")
+	c.Assert(ioutil.WriteFile(assertFile, []byte(testSnapAssertion), 0644), IsNil)
+
+	var added []asserts.Assertion
+	defer mockAddAssertions(&added, errors.New("no valid signature"))()
+
+	_, err := Install("foo", "", 0, &progress.NullProgress{})
+	c.Assert(err, ErrorMatches, ".*cannot add assertions of foo: no valid signature")
+	c.Check(ActiveSnapByName("foo"), IsNil)
+}
+
+func (s *SnapTestSuite) TestInstallWithoutStoreAssertions(c *C) {
+	defer makeDirStore(c, map[string]string{"foo": "name: foo\nversion: 1"})()
+
+	var added []asserts.Assertion
+	defer mockAddAssertions(&added, errors.New("should not be called"))()
+
+	_, err := Install("foo", "", 0, &progress.NullProgress{})
+	c.Assert(err, IsNil)
+	c.Check(added, HasLen, 0)
+}
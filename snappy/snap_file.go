@@ -20,6 +20,7 @@
 package snappy
 
 import (
+	"os"
 	"path/filepath"
 	"time"
 
@@ -36,6 +37,7 @@ type SnapFile struct {
 
 	origin  string
 	instdir string
+	size    int64
 }
 
 // NewSnapFile loads a snap from the given snapFile
@@ -45,6 +47,11 @@ func NewSnapFile(snapFile string, origin string, unsignedOk bool) (*SnapFile, er
 		return nil, err
 	}
 
+	st, err := os.Stat(snapFile)
+	if err != nil {
+		return nil, err
+	}
+
 	yamlData, err := d.MetaMember("snap.yaml")
 	if err != nil {
 		return nil, err
@@ -71,6 +78,7 @@ func NewSnapFile(snapFile string, origin string, unsignedOk bool) (*SnapFile, er
 		origin:  origin,
 		m:       m,
 		deb:     d,
+		size:    st.Size(),
 	}, nil
 }
 
@@ -119,9 +127,10 @@ func (s *SnapFile) DownloadSize() int64 {
 	return 0
 }
 
-// InstalledSize returns the installed size
+// InstalledSize returns the installed size, that is the size of the
+// snap file as it is kept (and mounted) as is
 func (s *SnapFile) InstalledSize() int64 {
-	return 0
+	return s.size
 }
 
 // Hash returns the hash
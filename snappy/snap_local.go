@@ -155,8 +155,21 @@ func (s *SnapPart) Hash() string {
 	return s.hash
 }
 
+// Revision returns the store revision of the snap, or 0 if it was not
+// installed from the store
+func (s *SnapPart) Revision() int64 {
+	if r := s.remoteM; r != nil {
+		return r.Revision
+	}
+
+	return 0
+}
+
 // Channel returns the channel used
 func (s *SnapPart) Channel() string {
+	if ch := TrackedChannel(QualifiedName(s)); ch != "" {
+		return ch
+	}
 	if r := s.remoteM; r != nil {
 		return r.Channel
 	}
@@ -24,14 +24,19 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/ubuntu-core/snappy/arch"
+	"github.com/ubuntu-core/snappy/asserts"
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/logger"
 	"github.com/ubuntu-core/snappy/oauth"
 	"github.com/ubuntu-core/snappy/progress"
 	"github.com/ubuntu-core/snappy/release"
@@ -77,6 +82,10 @@ type SnapUbuntuStoreRepository struct {
 	searchURI  *url.URL
 	detailsURI *url.URL
 	bulkURI    string
+
+	// the store id is only set here if the model assertion selects the store
+	storeID   string
+	fromModel bool
 }
 
 var (
@@ -103,16 +112,34 @@ func getStructFields(s interface{}) []string {
 	return fields
 }
 
+const defaultCPIURL = "https://search.apps.ubuntu.com/api/v1/"
+
 func cpiURL() string {
 	if os.Getenv("SNAPPY_USE_STAGING_CPI") != "" {
 		return "https://search.apps.staging.ubuntu.com/api/v1/"
 	}
-	// FIXME: this will become a store-url assertion
 	if os.Getenv("SNAPPY_FORCE_CPI_URL") != "" {
 		return os.Getenv("SNAPPY_FORCE_CPI_URL")
 	}
 
-	return "https://search.apps.ubuntu.com/api/v1/"
+	return defaultCPIURL
+}
+
+// storeURIs returns the search, details and bulk URIs of the store API
+// at the given base URI
+func storeURIs(baseURI *url.URL) (searchURI, detailsURI, bulkURI *url.URL) {
+	v := url.Values{}
+	v.Set("fields", strings.Join(getStructFields(remote.Snap{}), ","))
+
+	searchURI = baseURI.ResolveReference(&url.URL{Path: "search"})
+	searchURI.RawQuery = v.Encode()
+
+	detailsURI = baseURI.ResolveReference(&url.URL{Path: "package/"})
+
+	bulkURI = baseURI.ResolveReference(&url.URL{Path: "click-metadata"})
+	bulkURI.RawQuery = v.Encode()
+
+	return searchURI, detailsURI, bulkURI
 }
 
 func init() {
@@ -121,39 +148,85 @@ func init() {
 		panic(err)
 	}
 
-	storeSearchURI, err = storeBaseURI.Parse("search")
+	storeSearchURI, storeDetailsURI, storeBulkURI = storeURIs(storeBaseURI)
+}
+
+// modelStore returns the id of the store selected by the model assertion
+// of the device and the base URI of its API. The URI comes from the store
+// assertion for the store id and defaults to the ubuntu store. A nil URI
+// is returned if the device has no model assertion.
+var modelStore = func() (storeID string, baseURI *url.URL, err error) {
+	if !helpers.FileExists(dirs.SnapAssertsDBDir) {
+		return "", nil, nil
+	}
+	db, err := asserts.OpenSysDatabase("")
 	if err != nil {
-		panic(err)
+		return "", nil, err
 	}
 
-	v := url.Values{}
-	v.Set("fields", strings.Join(getStructFields(remote.Snap{}), ","))
-	storeSearchURI.RawQuery = v.Encode()
-
-	storeDetailsURI, err = storeBaseURI.Parse("package/")
-
+	models, err := db.FindMany(asserts.ModelType, nil)
+	if err == asserts.ErrNotFound {
+		return "", nil, nil
+	}
 	if err != nil {
-		panic(err)
+		return "", nil, err
+	}
+	if len(models) != 1 {
+		return "", nil, fmt.Errorf("expected one model assertion, got %d", len(models))
 	}
+	storeID = models[0].(*asserts.Model).Store()
 
-	storeBulkURI, err = storeBaseURI.Parse("click-metadata")
+	store, err := db.Find(asserts.StoreType, map[string]string{"store": storeID})
+	if err == asserts.ErrNotFound {
+		baseURI, err = url.Parse(defaultCPIURL)
+		return storeID, baseURI, err
+	}
 	if err != nil {
-		panic(err)
+		return "", nil, err
 	}
-	storeBulkURI.RawQuery = v.Encode()
+
+	return storeID, store.(*asserts.Store).URL(), nil
 }
 
 type searchResults struct {
 	Payload struct {
 		Packages []remote.Snap `json:"clickindex:package"`
 	} `json:"_embedded"`
+	Links struct {
+		Last *struct {
+			Href string `json:"href"`
+		} `json:"last"`
+	} `json:"_links"`
 }
 
-// NewUbuntuStoreSnapRepository creates a new SnapUbuntuStoreRepository
+// NewUbuntuStoreSnapRepository creates a new SnapUbuntuStoreRepository.
+//
+// Devices with a model assertion use the store it selects, the environment
+// is only consulted on devices without one.
 func NewUbuntuStoreSnapRepository() *SnapUbuntuStoreRepository {
 	if storeSearchURI == nil && storeDetailsURI == nil && storeBulkURI == nil {
 		return nil
 	}
+
+	storeID, baseURI, err := modelStore()
+	if err != nil {
+		// a broken assertion database must not hand the choice of
+		// the store over to the environment
+		logger.Noticef("cannot select the store from the model assertion: %v", err)
+		storeID = ""
+		baseURI, _ = url.Parse(defaultCPIURL)
+	}
+	if baseURI != nil {
+		searchURI, detailsURI, bulkURI := storeURIs(baseURI)
+		return &SnapUbuntuStoreRepository{
+			searchURI:  searchURI,
+			detailsURI: detailsURI,
+			bulkURI:    bulkURI.String(),
+			storeID:    storeID,
+			fromModel:  true,
+		}
+	}
+
 	// see https://wiki.ubuntu.com/AppStore/Interfaces/ClickPackageIndex
 	return &SnapUbuntuStoreRepository{
 		searchURI:  storeSearchURI,
@@ -162,8 +235,42 @@ func NewUbuntuStoreSnapRepository() *SnapUbuntuStoreRepository {
 	}
 }
 
+// StoreID returns the id of the store, it is empty for the default store.
+func (s *SnapUbuntuStoreRepository) StoreID() string {
+	if s.fromModel {
+		return s.storeID
+	}
+
+	return storeIDWithoutModel()
+}
+
+// SelectedStoreID returns the id of the store a new store repository would
+// use, without making one; it is empty for the default store.
+func SelectedStoreID() string {
+	storeID, baseURI, err := modelStore()
+	if err != nil {
+		// see NewUbuntuStoreSnapRepository
+		return ""
+	}
+	if baseURI != nil {
+		return storeID
+	}
+
+	return storeIDWithoutModel()
+}
+
+// storeIDWithoutModel returns the id of the store of devices without a
+// model assertion, from the environment or the gadget snap.
+func storeIDWithoutModel() string {
+	if storeID := os.Getenv("UBUNTU_STORE_ID"); storeID != "" {
+		return storeID
+	}
+
+	return StoreID()
+}
+
 // small helper that sets the correct http headers for the ubuntu store
-func setUbuntuStoreHeaders(req *http.Request) {
+func (s *SnapUbuntuStoreRepository) setUbuntuStoreHeaders(req *http.Request) {
 	req.Header.Set("Accept", "application/hal+json")
 
 	// frameworks
@@ -172,10 +279,11 @@ func setUbuntuStoreHeaders(req *http.Request) {
 	req.Header.Set("X-Ubuntu-Architecture", string(arch.UbuntuArchitecture()))
 	req.Header.Set("X-Ubuntu-Release", release.String())
 	req.Header.Set("X-Ubuntu-Wire-Protocol", UbuntuCoreWireProtocol)
+	if haveDeltaTool() {
+		req.Header.Set("X-Ubuntu-Delta-Formats", deltaFormat)
+	}
 
-	if storeID := os.Getenv("UBUNTU_STORE_ID"); storeID != "" {
-		req.Header.Set("X-Ubuntu-Store", storeID)
-	} else if storeID := StoreID(); storeID != "" {
+	if storeID := s.StoreID(); storeID != "" {
 		req.Header.Set("X-Ubuntu-Store", storeID)
 	}
 
@@ -186,10 +294,14 @@ func setUbuntuStoreHeaders(req *http.Request) {
 	}
 }
 
-// Snap returns the RemoteSnapPart for the given name or an error.
-func (s *SnapUbuntuStoreRepository) Snap(snapName string) (*RemoteSnapPart, error) {
-
-	url, err := s.detailsURI.Parse(snapName)
+// Snap returns the RemoteSnapPart for the given name in the given channel
+// or an error.
+func (s *SnapUbuntuStoreRepository) Snap(snapName, channel string) (*RemoteSnapPart, error) {
+	path := snapName
+	if channel != "" {
+		path += "/" + channel
+	}
+	url, err := s.detailsURI.Parse(path)
 	if err != nil {
 		return nil, err
 	}
@@ -200,7 +312,7 @@ func (s *SnapUbuntuStoreRepository) Snap(snapName string) (*RemoteSnapPart, erro
 	}
 
 	// set headers
-	setUbuntuStoreHeaders(req)
+	s.setUbuntuStoreHeaders(req)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -223,6 +335,9 @@ func (s *SnapUbuntuStoreRepository) Snap(snapName string) (*RemoteSnapPart, erro
 	if err := dec.Decode(&detailsData); err != nil {
 		return nil, err
 	}
+	if detailsData.Channel == "" {
+		detailsData.Channel = channel
+	}
 
 	return NewRemoteSnapPart(detailsData), nil
 }
@@ -233,7 +348,7 @@ func (s *SnapUbuntuStoreRepository) Details(name string, origin string) (parts [
 	if origin != "" {
 		snapName = name + "." + origin
 	}
-	snap, err := s.Snap(snapName)
+	snap, err := s.Snap(snapName, "")
 	if err != nil {
 		return nil, err
 	}
@@ -250,13 +365,48 @@ func (s *SnapUbuntuStoreRepository) All() ([]Part, error) {
 // XXX: this is actually Search, but that name is taken until we clean up
 // aliases
 func (s *SnapUbuntuStoreRepository) Find(searchTerm string) ([]Part, error) {
+	page, err := s.FindPage(SearchOptions{Query: searchTerm})
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Parts, nil
+}
+
+// FindPage finds the given page of the (installable) parts matching the
+// search options, the store does the filtering, sorting and paging.
+func (s *SnapUbuntuStoreRepository) FindPage(opts SearchOptions) (*SearchPage, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
 	u := *s.searchURI // make a copy, so we can mutate it
+	q := u.Query()
 
-	if searchTerm != "" {
-		q := u.Query()
-		q.Set("q", "name:"+searchTerm)
-		u.RawQuery = q.Encode()
+	var terms []string
+	if opts.Query != "" {
+		terms = append(terms, "name:"+opts.Query)
+	}
+	if len(opts.Types) > 0 {
+		types := make([]string, len(opts.Types))
+		for i, t := range opts.Types {
+			types[i] = string(t)
+		}
+		terms = append(terms, "content:"+strings.Join(types, ","))
 	}
+	if len(terms) > 0 {
+		q.Set("q", strings.Join(terms, " "))
+	}
+	if opts.Sort != "" {
+		q.Set("sort", storeSortKeys[opts.Sort])
+	}
+	if opts.Page > 0 {
+		q.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.Size > 0 {
+		q.Set("size", strconv.Itoa(opts.Size))
+	}
+	u.RawQuery = q.Encode()
 
 	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
@@ -264,7 +414,7 @@ func (s *SnapUbuntuStoreRepository) Find(searchTerm string) ([]Part, error) {
 	}
 
 	// set headers
-	setUbuntuStoreHeaders(req)
+	s.setUbuntuStoreHeaders(req)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -280,12 +430,42 @@ func (s *SnapUbuntuStoreRepository) Find(searchTerm string) ([]Part, error) {
 		return nil, err
 	}
 
-	parts := make([]Part, len(searchData.Payload.Packages))
+	page := &SearchPage{
+		Parts: make([]Part, len(searchData.Payload.Packages)),
+		Page:  opts.Page,
+	}
 	for i, pkg := range searchData.Payload.Packages {
-		parts[i] = NewRemoteSnapPart(pkg)
+		page.Parts[i] = NewRemoteSnapPart(pkg)
+	}
+	if page.Page < 1 {
+		page.Page = 1
 	}
 
-	return parts, nil
+	// the store links to the last page of the results, there is no
+	// such link when there is only the one page
+	page.Pages = page.Page
+	if last := searchData.Links.Last; last != nil {
+		if n := pageFromLink(last.Href); n > page.Pages {
+			page.Pages = n
+		}
+	}
+
+	return page, nil
+}
+
+// pageFromLink returns the page a link of the store points to, 0 if it
+// points to none.
+func pageFromLink(href string) int {
+	u, err := url.Parse(href)
+	if err != nil {
+		return 0
+	}
+	n, err := strconv.Atoi(u.Query().Get("page"))
+	if err != nil {
+		return 0
+	}
+
+	return n
 }
 
 // Search searches the repository for the given searchTerm
@@ -300,7 +480,7 @@ func (s *SnapUbuntuStoreRepository) Search(searchTerm string) (SharedNames, erro
 	}
 
 	// set headers
-	setUbuntuStoreHeaders(req)
+	s.setUbuntuStoreHeaders(req)
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -344,7 +524,51 @@ func (s *SnapUbuntuStoreRepository) Updates() (parts []Part, err error) {
 	if err != nil || len(installed) == 0 {
 		return nil, err
 	}
-	jsonData, err := json.Marshal(map[string][]string{"name": installed})
+
+	return s.updates(installed)
+}
+
+// SnapUpdate returns the available update of the given installed snap, or
+// nil if there is none. Only the given snap is sent to the store.
+func (s *SnapUbuntuStoreRepository) SnapUpdate(part Part) (*RemoteSnapPart, error) {
+	parts, err := s.updates([]string{fullNameWithChannel(part)})
+	if err != nil {
+		return nil, err
+	}
+	for _, upd := range parts {
+		if upd.Name() == part.Name() && upd.Origin() == part.Origin() && upd.Version() != part.Version() {
+			return upd.(*RemoteSnapPart), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// bulkRequest is what is sent to the bulk metadata endpoint of the store
+type bulkRequest struct {
+	Name []string `json:"name"`
+	// InstalledRevisions are the revisions of the active snaps that
+	// were installed from the store, by name and origin, for the store
+	// to offer deltas from them
+	InstalledRevisions map[string]int64 `json:"installed-revisions,omitempty"`
+}
+
+// updates asks the bulk metadata endpoint of the store for the given
+// snaps, named with their origin and channel, and returns those that have
+// a different version than the active one.
+func (s *SnapUbuntuStoreRepository) updates(names []string) (parts []Part, err error) {
+	bulk := bulkRequest{Name: names}
+	for _, name := range names {
+		qn := strings.SplitN(name, "/", 2)[0]
+		if active := activeStorePart(SplitOrigin(qn)); active != nil {
+			if bulk.InstalledRevisions == nil {
+				bulk.InstalledRevisions = make(map[string]int64)
+			}
+			bulk.InstalledRevisions[qn] = active.Revision()
+		}
+	}
+
+	jsonData, err := json.Marshal(bulk)
 	if err != nil {
 		return nil, err
 	}
@@ -354,7 +578,7 @@ func (s *SnapUbuntuStoreRepository) Updates() (parts []Part, err error) {
 		return nil, err
 	}
 	// set headers
-	setUbuntuStoreHeaders(req)
+	s.setUbuntuStoreHeaders(req)
 	// the updates call is a special snowflake right now
 	// (see LP: #1427155)
 	req.Header.Set("Accept", "application/json")
@@ -383,24 +607,201 @@ func (s *SnapUbuntuStoreRepository) Updates() (parts []Part, err error) {
 	return parts, nil
 }
 
+// downloadCachePath returns the path of the given snap revision in the
+// download cache
+func downloadCachePath(remoteSnap *RemoteSnapPart) string {
+	return filepath.Join(dirs.SnapDownloadCacheDir, fmt.Sprintf("%s.%s_%d.snap", remoteSnap.pkg.Name, remoteSnap.pkg.Origin, remoteSnap.pkg.Revision))
+}
+
+// downloadCacheEntries returns the paths of the revisions, complete or
+// partial, of the snap with the given name, that may be qualified with an
+// origin, in the download cache
+func downloadCacheEntries(name string) ([]string, error) {
+	if !strings.Contains(name, ".") {
+		name += ".*"
+	}
+
+	return filepath.Glob(filepath.Join(dirs.SnapDownloadCacheDir, name+"_*.snap*"))
+}
+
+// removeDownloadCache removes everything the download cache has of the
+// snap with the given qualified name
+func removeDownloadCache(qualifiedName string) error {
+	entries, err := downloadCacheEntries(qualifiedName)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.Remove(entry); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyDownload checks that the given file has the expected sha512, an
+// empty expected hash is not checked.
+func verifyDownload(path, sha512 string) error {
+	if sha512 == "" {
+		return nil
+	}
+	hash, err := helpers.Sha512sum(path)
+	if err != nil {
+		return err
+	}
+	if hash != sha512 {
+		return ErrHashMismatch
+	}
+
+	return nil
+}
+
 // Download downloads the given snap and returns its filename.
-// The file is saved in temporary storage, and should be removed
-// after use to prevent the disk from running out of space.
+//
+// The snap is downloaded into the download cache. An interrupted download
+// is resumed by the next attempt and the sha512 of the result is verified
+// against the one announced by the store. If the same revision is already
+// in the cache it is reused instead of being downloaded again. If the
+// store offers a delta from the active revision the snap is reconstructed
+// from it, falling back to the full download if that fails.
+//
+// Without a sha512 from the store nothing in the cache can be verified,
+// so the snap is always downloaded from scratch.
 func (s *SnapUbuntuStoreRepository) Download(remoteSnap *RemoteSnapPart, pbar progress.Meter) (path string, err error) {
-	w, err := ioutil.TempFile("", remoteSnap.pkg.Name)
+	if err := os.MkdirAll(dirs.SnapDownloadCacheDir, 0755); err != nil {
+		return "", err
+	}
+
+	target := downloadCachePath(remoteSnap)
+	if helpers.FileExists(target) {
+		if remoteSnap.pkg.DownloadSha512 != "" && verifyDownload(target, remoteSnap.pkg.DownloadSha512) == nil {
+			return target, nil
+		}
+		// the cached blob is damaged, or cannot be checked, download
+		// it again
+		if err := os.Remove(target); err != nil {
+			return "", err
+		}
+	}
+
+	if err := s.downloadDelta(remoteSnap, pbar); err != nil {
+		if err != errNoDelta {
+			logger.Noticef("Cannot update %s using a delta, downloading the full snap: %v", remoteSnap.Name(), err)
+		}
+		if err := s.downloadFull(remoteSnap, target, pbar); err != nil {
+			return "", err
+		}
+	}
+
+	// other revisions of the same snap are no longer needed
+	others, err := downloadCacheEntries(remoteSnap.pkg.Name + "." + remoteSnap.pkg.Origin)
 	if err != nil {
 		return "", err
 	}
+	for _, other := range others {
+		if other != target {
+			os.Remove(other)
+		}
+	}
+
+	return target, nil
+}
+
+// downloadDelta downloads the delta from the active revision of the
+// given snap, if the store offers one, and reconstructs the snap from it.
+func (s *SnapUbuntuStoreRepository) downloadDelta(remoteSnap *RemoteSnapPart, pbar progress.Meter) (err error) {
+	active, delta := deltaFromActive(remoteSnap)
+	if delta == nil {
+		return errNoDelta
+	}
+
+	deltaPath := filepath.Join(dirs.SnapDownloadCacheDir, deltaName(remoteSnap, active.Revision()))
+	defer os.Remove(deltaPath)
+
+	w, err := os.Create(deltaPath)
+	if err != nil {
+		return err
+	}
 	defer func() {
 		if cerr := w.Close(); cerr != nil && err == nil {
 			err = cerr
 		}
-		if err != nil {
-			os.Remove(w.Name())
-			path = ""
+	}()
+
+	url := delta.AnonDownloadURL
+	if url == "" {
+		url = delta.DownloadURL
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	s.setUbuntuStoreHeaders(req)
+
+	if err := download(remoteSnap.Name(), w, req, pbar); err != nil {
+		return err
+	}
+	if err := verifyDownload(deltaPath, delta.DownloadSha512); err != nil {
+		return err
+	}
+
+	_, err = reconstructFromDelta(remoteSnap, active, deltaPath)
+	return err
+}
+
+// downloadFull downloads the whole snap into the target path, resuming an
+// earlier partial download if the result can be verified.
+func (s *SnapUbuntuStoreRepository) downloadFull(remoteSnap *RemoteSnapPart, target string, pbar progress.Meter) error {
+	partial := target + ".partial"
+	resumable := remoteSnap.pkg.DownloadSha512 != ""
+	if !resumable {
+		if err := os.Remove(partial); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := checkDownloadSpace(remoteSnap, partial); err != nil {
+		return err
+	}
+	if err := s.downloadPartial(remoteSnap, partial, pbar); err != nil {
+		if !resumable {
+			os.Remove(partial)
+		}
+		return err
+	}
+
+	if err := verifyDownload(partial, remoteSnap.pkg.DownloadSha512); err != nil {
+		// there is no point in resuming a broken download
+		os.Remove(partial)
+		return err
+	}
+
+	return os.Rename(partial, target)
+}
+
+// downloadPartial downloads the given snap into the partial file, resuming
+// from whatever the file already contains.
+func (s *SnapUbuntuStoreRepository) downloadPartial(remoteSnap *RemoteSnapPart, partial string, pbar progress.Meter) (err error) {
+	w, err := os.OpenFile(partial, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := w.Close(); cerr != nil && err == nil {
+			err = cerr
 		}
 	}()
 
+	fi, err := w.Stat()
+	if err != nil {
+		return err
+	}
+	offset := fi.Size()
+	if size := remoteSnap.pkg.DownloadSize; size > 0 && offset >= size {
+		// nothing left to download
+		return nil
+	}
+
 	// try anonymous download first and fallback to authenticated
 	url := remoteSnap.pkg.AnonDownloadURL
 	if url == "" {
@@ -408,18 +809,37 @@ func (s *SnapUbuntuStoreRepository) Download(remoteSnap *RemoteSnapPart, pbar pr
 	}
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return "", err
+		return err
+	}
+	s.setUbuntuStoreHeaders(req)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
 	}
-	setUbuntuStoreHeaders(req)
 
 	if err := download(remoteSnap.Name(), w, req, pbar); err != nil {
-		return "", err
+		if derr, ok := err.(*ErrDownload); ok && derr.Code == http.StatusRequestedRangeNotSatisfiable {
+			// the partial download is unusable, start over next time
+			os.Remove(partial)
+		}
+		return err
 	}
 
-	return w.Name(), w.Sync()
+	if err := w.Sync(); err != nil {
+		os.Remove(partial)
+		return err
+	}
+
+	return nil
 }
 
-// download writes an http.Request showing a progress.Meter
+// truncater is implemented by writers that can be emptied
+type truncater interface {
+	Truncate(size int64) error
+}
+
+// download writes an http.Request showing a progress.Meter.
+// Requests for a range are resumed if the server supports it and started
+// over otherwise.
 var download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
 	client := &http.Client{}
 
@@ -429,7 +849,21 @@ var download = func(name string, w io.Writer, req *http.Request, pbar progress.M
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
+	switch resp.StatusCode {
+	case 200:
+		if req.Header.Get("Range") != "" {
+			// the server sends the whole snap, drop what we already have
+			t, ok := w.(truncater)
+			if !ok {
+				return fmt.Errorf("cannot restart download of %s", name)
+			}
+			if err := t.Truncate(0); err != nil {
+				return err
+			}
+		}
+	case 206:
+		// resuming
+	default:
 		return &ErrDownload{Code: resp.StatusCode, URL: req.URL}
 	}
 
@@ -20,15 +20,23 @@
 package snappy
 
 import (
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/ubuntu-core/snappy/asserts"
 	"github.com/ubuntu-core/snappy/dirs"
 	"github.com/ubuntu-core/snappy/helpers"
 	"github.com/ubuntu-core/snappy/progress"
+	"github.com/ubuntu-core/snappy/snap/remote"
+	"github.com/ubuntu-core/snappy/snap/squashfs"
 
 	. "gopkg.in/check.v1"
 )
@@ -36,7 +44,8 @@ import (
 type remoteRepoTestSuite struct {
 	store *SnapUbuntuStoreRepository
 
-	origDownloadFunc func(string, io.Writer, *http.Request, progress.Meter) error
+	origDownloadFunc   func(string, io.Writer, *http.Request, progress.Meter) error
+	origApplyDeltaFunc func(string, string, string) error
 }
 
 var _ = Suite(&remoteRepoTestSuite{})
@@ -44,12 +53,14 @@ var _ = Suite(&remoteRepoTestSuite{})
 func (t *remoteRepoTestSuite) SetUpTest(c *C) {
 	t.store = NewUbuntuStoreSnapRepository()
 	t.origDownloadFunc = download
+	t.origApplyDeltaFunc = applyDelta
 	dirs.SetRootDir(c.MkDir())
 	c.Assert(os.MkdirAll(dirs.SnapSnapsDir, 0755), IsNil)
 }
 
 func (t *remoteRepoTestSuite) TearDownTest(c *C) {
 	download = t.origDownloadFunc
+	applyDelta = t.origApplyDeltaFunc
 }
 
 func (t *remoteRepoTestSuite) TestDownloadOK(c *C) {
@@ -71,15 +82,19 @@ func (t *remoteRepoTestSuite) TestDownloadFails(c *C) {
 	var tmpfile *os.File
 	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
 		tmpfile = w.(*os.File)
+		w.Write([]byte("partial"))
 		return fmt.Errorf("uh, it failed")
 	}
 
 	// simulate a failed download
-	path, err := t.store.Download(&RemoteSnapPart{}, nil)
+	remoteSnap := NewRemoteSnapPart(remote.Snap{Name: "foo", Origin: "bar", Revision: 42, DownloadSha512: sha512sum("partial download")})
+	path, err := t.store.Download(remoteSnap, nil)
 	c.Assert(err, ErrorMatches, "uh, it failed")
 	c.Assert(path, Equals, "")
-	// ... and ensure that the tempfile is removed
-	c.Assert(helpers.FileExists(tmpfile.Name()), Equals, false)
+	// ... and ensure that the partial download is kept for resuming
+	content, err := ioutil.ReadFile(tmpfile.Name())
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "partial")
 }
 
 func (t *remoteRepoTestSuite) TestDownloadSyncFails(c *C) {
@@ -99,3 +114,448 @@ func (t *remoteRepoTestSuite) TestDownloadSyncFails(c *C) {
 	// ... and ensure that the tempfile is removed
 	c.Assert(helpers.FileExists(tmpfile.Name()), Equals, false)
 }
+
+func sha512sum(data string) string {
+	hash := sha512.Sum512([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+func (t *remoteRepoTestSuite) TestDownloadResumes(c *C) {
+	remoteSnap := NewRemoteSnapPart(remote.Snap{
+		Name:           "foo",
+		Origin:         "bar",
+		Revision:       42,
+		DownloadSha512: sha512sum("I was downloaded"),
+	})
+
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		c.Check(req.Header.Get("Range"), Equals, "")
+		w.Write([]byte("I was "))
+		return fmt.Errorf("connection dropped")
+	}
+	_, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, ErrorMatches, "connection dropped")
+
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		c.Check(req.Header.Get("Range"), Equals, "bytes=6-")
+		w.Write([]byte("downloaded"))
+		return nil
+	}
+	path, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+	c.Assert(path, Equals, filepath.Join(dirs.SnapDownloadCacheDir, "foo.bar_42.snap"))
+
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "I was downloaded")
+	c.Assert(helpers.FileExists(path+".partial"), Equals, false)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadHashMismatch(c *C) {
+	remoteSnap := NewRemoteSnapPart(remote.Snap{
+		Name:           "foo",
+		Origin:         "bar",
+		Revision:       42,
+		DownloadSha512: sha512sum("I was downloaded"),
+	})
+
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		w.Write([]byte("I was tampered with"))
+		return nil
+	}
+	path, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, Equals, ErrHashMismatch)
+	c.Assert(path, Equals, "")
+
+	// nothing is left behind to be resumed
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapDownloadCacheDir, "*"))
+	c.Assert(err, IsNil)
+	c.Assert(matches, HasLen, 0)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadReusesCachedRevision(c *C) {
+	remoteSnap := NewRemoteSnapPart(remote.Snap{
+		Name:           "foo",
+		Origin:         "bar",
+		Revision:       42,
+		DownloadSha512: sha512sum("I was downloaded"),
+	})
+
+	n := 0
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		n++
+		w.Write([]byte("I was downloaded"))
+		return nil
+	}
+	path1, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+	path2, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+	c.Assert(path2, Equals, path1)
+	c.Assert(n, Equals, 1)
+
+	// a damaged blob is downloaded again
+	c.Assert(ioutil.WriteFile(path1, []byte("garbage"), 0644), IsNil)
+	path3, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+	c.Assert(path3, Equals, path1)
+	c.Assert(n, Equals, 2)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadWithoutSha512StartsOver(c *C) {
+	remoteSnap := NewRemoteSnapPart(remote.Snap{Name: "foo", Origin: "bar", Revision: 42})
+
+	// a failed download leaves nothing to resume
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		w.Write([]byte("I was "))
+		return fmt.Errorf("connection dropped")
+	}
+	_, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, ErrorMatches, "connection dropped")
+	c.Check(helpers.FileExists(downloadCachePath(remoteSnap)+".partial"), Equals, false)
+
+	// a stale partial download is not resumed
+	partial := downloadCachePath(remoteSnap) + ".partial"
+	c.Assert(ioutil.WriteFile(partial, []byte("I was "), 0644), IsNil)
+	n := 0
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		n++
+		c.Check(req.Header.Get("Range"), Equals, "")
+		w.Write([]byte("I was downloaded"))
+		return nil
+	}
+	path, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "I was downloaded")
+
+	// and a cached blob is not reused
+	_, err = t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 2)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadPrunesOtherRevisions(c *C) {
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		w.Write([]byte("I was downloaded"))
+		return nil
+	}
+
+	old, err := t.store.Download(NewRemoteSnapPart(remote.Snap{Name: "foo", Origin: "bar", Revision: 41}), nil)
+	c.Assert(err, IsNil)
+	other, err := t.store.Download(NewRemoteSnapPart(remote.Snap{Name: "foo", Origin: "baz", Revision: 1}), nil)
+	c.Assert(err, IsNil)
+	path, err := t.store.Download(NewRemoteSnapPart(remote.Snap{Name: "foo", Origin: "bar", Revision: 42}), nil)
+	c.Assert(err, IsNil)
+
+	c.Assert(helpers.FileExists(old), Equals, false)
+	c.Assert(helpers.FileExists(other), Equals, true)
+	c.Assert(helpers.FileExists(path), Equals, true)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadFailsWithoutSpace(c *C) {
+	defer mockFreeSpace(10)()
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		c.Fatalf("no download expected")
+		return nil
+	}
+
+	remoteSnap := NewRemoteSnapPart(remote.Snap{Name: "foo", Origin: "bar", Revision: 42, DownloadSize: 100, DownloadSha512: sha512sum(string(make([]byte, 100)))})
+	_, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, DeepEquals, &ErrInsufficientSpace{
+		Snap:   "foo",
+		Path:   dirs.SnapDownloadCacheDir,
+		Needed: 100,
+		Free:   10,
+	})
+
+	// only what is left to download is needed
+	c.Assert(ioutil.WriteFile(downloadCachePath(remoteSnap)+".partial", make([]byte, 95), 0644), IsNil)
+	download = func(name string, w io.Writer, req *http.Request, pbar progress.Meter) error {
+		w.Write(make([]byte, 5))
+		return nil
+	}
+	_, err = t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadRangeNotSupported(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Range"), Equals, "bytes=6-")
+		// ignore the range and send everything
+		io.WriteString(w, "I was downloaded")
+	}))
+	defer mockServer.Close()
+
+	remoteSnap := NewRemoteSnapPart(remote.Snap{
+		Name:            "foo",
+		Origin:          "bar",
+		Revision:        42,
+		AnonDownloadURL: mockServer.URL + "/snap",
+		DownloadSha512:  sha512sum("I was downloaded"),
+	})
+	partial := downloadCachePath(remoteSnap) + ".partial"
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(partial, []byte("I was "), 0644), IsNil)
+
+	path, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "I was downloaded")
+}
+
+func (t *remoteRepoTestSuite) TestDownloadRangeSupported(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Header.Get("Range"), Equals, "bytes=6-")
+		w.WriteHeader(http.StatusPartialContent)
+		io.WriteString(w, "downloaded")
+	}))
+	defer mockServer.Close()
+
+	remoteSnap := NewRemoteSnapPart(remote.Snap{
+		Name:            "foo",
+		Origin:          "bar",
+		Revision:        42,
+		AnonDownloadURL: mockServer.URL + "/snap",
+		DownloadSha512:  sha512sum("I was downloaded"),
+	})
+	partial := downloadCachePath(remoteSnap) + ".partial"
+	c.Assert(os.MkdirAll(dirs.SnapDownloadCacheDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(partial, []byte("I was "), 0644), IsNil)
+
+	path, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(string(content), Equals, "I was downloaded")
+}
+
+// makeActiveStoreSnap installs version 1 of foo from the given store
+// revision and returns the path of its blob
+func makeActiveStoreSnap(c *C, revision int64, blobContent string) string {
+	yamlPath, err := makeInstalledMockSnap(dirs.GlobalRootDir, "name: foo\nversion: 1")
+	c.Assert(err, IsNil)
+	c.Assert(makeSnapActive(yamlPath), IsNil)
+	c.Assert(NewRemoteSnapPart(remote.Snap{Name: "foo", Origin: testOrigin, Version: "1", Revision: revision}).saveStoreManifest(), IsNil)
+
+	blob := squashfs.BlobPath(filepath.Dir(filepath.Dir(yamlPath)))
+	c.Assert(os.MkdirAll(filepath.Dir(blob), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(blob, []byte(blobContent), 0644), IsNil)
+
+	return blob
+}
+
+// mockApplyDelta makes applying a delta append it to the source
+func mockApplyDelta(c *C) {
+	applyDelta = func(source, delta, target string) error {
+		old, err := ioutil.ReadFile(source)
+		c.Assert(err, IsNil)
+		diff, err := ioutil.ReadFile(delta)
+		c.Assert(err, IsNil)
+		return ioutil.WriteFile(target, append(old, diff...), 0644)
+	}
+}
+
+func (t *remoteRepoTestSuite) TestSnapUpdateSendsInstalledRevision(c *C) {
+	makeActiveStoreSnap(c, 7, "I was ")
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonReq, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		c.Check(string(jsonReq), Equals, `{"name":["foo.`+testOrigin+`"],"installed-revisions":{"foo.`+testOrigin+`":7}}`)
+		io.WriteString(w, "[]")
+	}))
+	defer mockServer.Close()
+	t.store.bulkURI = mockServer.URL
+
+	upd, err := t.store.SnapUpdate(ActiveSnapByName("foo"))
+	c.Assert(err, IsNil)
+	c.Check(upd, IsNil)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadDelta(c *C) {
+	makeActiveStoreSnap(c, 1, "I was ")
+	mockApplyDelta(c)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/delta")
+		io.WriteString(w, "downloaded")
+	}))
+	defer mockServer.Close()
+
+	remoteSnap := NewRemoteSnapPart(remote.Snap{
+		Name:            "foo",
+		Origin:          testOrigin,
+		Revision:        2,
+		AnonDownloadURL: mockServer.URL + "/snap",
+		DownloadSha512:  sha512sum("I was downloaded"),
+		Deltas: []remote.Delta{
+			{FromRevision: 0, ToRevision: 2, Format: deltaFormat, AnonDownloadURL: mockServer.URL + "/other"},
+			{FromRevision: 1, ToRevision: 2, Format: deltaFormat, AnonDownloadURL: mockServer.URL + "/delta", DownloadSha512: sha512sum("downloaded")},
+		},
+	})
+
+	path, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, downloadCachePath(remoteSnap))
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "I was downloaded")
+
+	// the delta itself is not kept around
+	c.Check(helpers.FileExists(filepath.Join(dirs.SnapDownloadCacheDir, deltaName(remoteSnap, 1))), Equals, false)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadDeltaMismatchFallsBack(c *C) {
+	makeActiveStoreSnap(c, 1, "I was not ")
+	mockApplyDelta(c)
+
+	var paths []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/delta":
+			io.WriteString(w, "downloaded")
+		case "/snap":
+			io.WriteString(w, "I was downloaded")
+		}
+	}))
+	defer mockServer.Close()
+
+	remoteSnap := NewRemoteSnapPart(remote.Snap{
+		Name:            "foo",
+		Origin:          testOrigin,
+		Revision:        2,
+		AnonDownloadURL: mockServer.URL + "/snap",
+		DownloadSha512:  sha512sum("I was downloaded"),
+		Deltas: []remote.Delta{
+			{FromRevision: 1, ToRevision: 2, Format: deltaFormat, AnonDownloadURL: mockServer.URL + "/delta"},
+		},
+	})
+
+	path, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "I was downloaded")
+	c.Check(paths, DeepEquals, []string{"/delta", "/snap"})
+	c.Check(helpers.FileExists(path+".delta"), Equals, false)
+}
+
+func (t *remoteRepoTestSuite) TestDownloadDeltaNotFromActiveRevision(c *C) {
+	makeActiveStoreSnap(c, 3, "I was ")
+	applyDelta = func(source, delta, target string) error {
+		c.Fatalf("unexpected delta")
+		return nil
+	}
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/snap")
+		io.WriteString(w, "I was downloaded")
+	}))
+	defer mockServer.Close()
+
+	remoteSnap := NewRemoteSnapPart(remote.Snap{
+		Name:            "foo",
+		Origin:          testOrigin,
+		Revision:        2,
+		AnonDownloadURL: mockServer.URL + "/snap",
+		DownloadSha512:  sha512sum("I was downloaded"),
+		Deltas: []remote.Delta{
+			{FromRevision: 1, ToRevision: 2, Format: deltaFormat, AnonDownloadURL: mockServer.URL + "/delta"},
+		},
+	})
+
+	_, err := t.store.Download(remoteSnap, nil)
+	c.Assert(err, IsNil)
+}
+
+func (t *remoteRepoTestSuite) addAssertion(c *C, typ *asserts.AssertionType, encoded string) {
+	encoded += "timestamp: " + time.Now().UTC().Format(time.RFC3339) + "\n" +
+		"body-length: 0" +
+		"\n\n" +
+		"openpgp c2ln"
+	a, err := asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	bs, err := asserts.OpenFSBackstore(dirs.SnapAssertsDBDir)
+	c.Assert(err, IsNil)
+	c.Assert(bs.Put(typ, a), IsNil)
+}
+
+func (t *remoteRepoTestSuite) addModel(c *C) {
+	t.addAssertion(c, asserts.ModelType, "type: model\n"+
+		"authority-id: brand-id1\n"+
+		"brand-id: brand-id1\n"+
+		"model: baz-3000\n"+
+		"series: 16\n"+
+		"os: core\n"+
+		"architecture: amd64\n"+
+		"gadget: brand-gadget\n"+
+		"kernel: baz-linux\n"+
+		"store: brand-store\n"+
+		"allowed-modes: \n"+
+		"required-snaps: \n"+
+		"class: fixed\n")
+}
+
+func (t *remoteRepoTestSuite) TestStoreFromEnvironmentWithoutModel(c *C) {
+	os.Setenv("UBUNTU_STORE_ID", "env-store")
+	defer os.Unsetenv("UBUNTU_STORE_ID")
+
+	store := NewUbuntuStoreSnapRepository()
+	c.Check(store.StoreID(), Equals, "env-store")
+	c.Check(store.searchURI, Equals, storeSearchURI)
+	c.Check(SelectedStoreID(), Equals, "env-store")
+}
+
+func (t *remoteRepoTestSuite) TestStoreFromModelAndStoreAssertion(c *C) {
+	t.addModel(c)
+	t.addAssertion(c, asserts.StoreType, "type: store\n"+
+		"authority-id: canonical\n"+
+		"store: brand-store\n"+
+		"url: https://store.example.com/api/v1/\n")
+
+	// the environment cannot override the model
+	os.Setenv("UBUNTU_STORE_ID", "env-store")
+	defer os.Unsetenv("UBUNTU_STORE_ID")
+
+	store := NewUbuntuStoreSnapRepository()
+	c.Check(store.StoreID(), Equals, "brand-store")
+	c.Check(SelectedStoreID(), Equals, "brand-store")
+	c.Check(store.detailsURI.String(), Equals, "https://store.example.com/api/v1/package/")
+	c.Check(store.searchURI.Path, Equals, "/api/v1/search")
+	c.Check(store.bulkURI, Matches, "https://store.example.com/api/v1/click-metadata\\?fields=.*")
+
+	req, err := http.NewRequest("GET", "http://example.com", nil)
+	c.Assert(err, IsNil)
+	store.setUbuntuStoreHeaders(req)
+	c.Check(req.Header.Get("X-Ubuntu-Store"), Equals, "brand-store")
+}
+
+func (t *remoteRepoTestSuite) TestDeltaFormatsHeader(c *C) {
+	orig := haveDeltaTool
+	defer func() { haveDeltaTool = orig }()
+
+	for _, have := range []bool{true, false} {
+		haveDeltaTool = func() bool { return have }
+
+		req, err := http.NewRequest("GET", "http://example.com", nil)
+		c.Assert(err, IsNil)
+		t.store.setUbuntuStoreHeaders(req)
+		if have {
+			c.Check(req.Header.Get("X-Ubuntu-Delta-Formats"), Equals, deltaFormat)
+		} else {
+			c.Check(req.Header.Get("X-Ubuntu-Delta-Formats"), Equals, "")
+		}
+	}
+}
+
+func (t *remoteRepoTestSuite) TestStoreFromModelWithoutStoreAssertion(c *C) {
+	t.addModel(c)
+
+	store := NewUbuntuStoreSnapRepository()
+	c.Check(store.StoreID(), Equals, "brand-store")
+	c.Check(store.detailsURI.String(), Equals, defaultCPIURL+"package/")
+}
@@ -86,6 +86,19 @@ type AppYaml struct {
 	UsesRef   []string `yaml:"uses"`
 }
 
+// HealthCheckYaml describes how to tell whether a new version of a snap
+// works after an update
+type HealthCheckYaml struct {
+	// Command is run (confined) once the grace period has passed, it
+	// must exit with status 0 for the snap to be considered healthy.
+	// It is a path relative to the snap, and takes no arguments. If it
+	// is not set only the state of the services is checked.
+	Command string `yaml:"command,omitempty"`
+	// GracePeriod is how long the services of the snap must keep
+	// running after the update, e.g. "30s"
+	GracePeriod string `yaml:"grace-period,omitempty"`
+}
+
 type usesYaml struct {
 	Type                string `yaml:"type"`
 	SecurityDefinitions `yaml:",inline"`
@@ -114,6 +127,10 @@ type snapYaml struct {
 	// Uses maps the used "skills" to the apps
 	Uses map[string]*usesYaml `yaml:"uses,omitempty"`
 
+	// HealthCheck is checked after an update, the previous version
+	// is made active again if it fails
+	HealthCheck *HealthCheckYaml `yaml:"health-check,omitempty"`
+
 	// FIXME: clarify those
 
 	// gadget snap only
@@ -176,6 +193,12 @@ func validateSnapYamlData(file string, yamlData []byte, m *snapYaml) error {
 		}
 	}
 
+	if m.HealthCheck != nil {
+		if err := verifyHealthCheckYaml(m.HealthCheck); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
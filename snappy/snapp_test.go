@@ -29,6 +29,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ubuntu-core/snappy/arch"
 	"github.com/ubuntu-core/snappy/dirs"
@@ -36,6 +37,7 @@ import (
 	"github.com/ubuntu-core/snappy/policy"
 	"github.com/ubuntu-core/snappy/release"
 	"github.com/ubuntu-core/snappy/snap"
+	"github.com/ubuntu-core/snappy/snap/remote"
 	"github.com/ubuntu-core/snappy/systemd"
 
 	. "gopkg.in/check.v1"
@@ -95,6 +97,10 @@ func (s *SnapTestSuite) TearDownTest(c *C) {
 	duCmd = "du"
 	stripGlobalRootDir = stripGlobalRootDirImpl
 	runUdevAdm = runUdevAdmImpl
+	runHookScript = runHookScriptImpl
+	healthCheckInterval = 2 * time.Second
+	setProjectQuota = setProjectQuotaImpl
+	clearProjectQuota = clearProjectQuotaImpl
 }
 
 func (s *SnapTestSuite) makeInstalledMockSnap(yamls ...string) (yamlFile string, err error) {
@@ -494,6 +500,87 @@ func (s *SnapTestSuite) TestUbuntuStoreFind(c *C) {
 	c.Check(parts[0].Name(), Equals, funkyAppName)
 }
 
+const mockSearchPageJSON = `{
+    "_embedded": {
+        "clickindex:package": [
+            {
+                "content": "application",
+                "origin": "chipaca",
+                "package_name": "foo",
+                "version": "1"
+            }
+        ]
+    },
+    "_links": {
+        "last": {
+            "href": "https://search.apps.ubuntu.com/api/v1/search?q=name%3Afoo&page=4&size=1"
+        }
+    }
+}`
+
+func (s *SnapTestSuite) TestUbuntuStoreFindPage(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		c.Check(q.Get("q"), Equals, "name:foo content:app,framework")
+		c.Check(q.Get("sort"), Equals, "-ratings_average")
+		c.Check(q.Get("page"), Equals, "2")
+		c.Check(q.Get("size"), Equals, "1")
+		io.WriteString(w, mockSearchPageJSON)
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	var err error
+	storeSearchURI, err = url.Parse(mockServer.URL)
+	c.Assert(err, IsNil)
+
+	repo := NewUbuntuStoreSnapRepository()
+	c.Assert(repo, NotNil)
+
+	page, err := repo.FindPage(SearchOptions{
+		Query: "foo",
+		Types: []snap.Type{snap.TypeApp, snap.TypeFramework},
+		Sort:  SortByRatings,
+		Page:  2,
+		Size:  1,
+	})
+	c.Assert(err, IsNil)
+	c.Check(page.Page, Equals, 2)
+	c.Check(page.Pages, Equals, 4)
+	c.Assert(page.Parts, HasLen, 1)
+	c.Check(page.Parts[0].Name(), Equals, "foo")
+}
+
+func (s *SnapTestSuite) TestUbuntuStoreFindPageSinglePage(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Query().Get("page"), Equals, "")
+		io.WriteString(w, MockSearchJSON)
+	}))
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	var err error
+	storeSearchURI, err = url.Parse(mockServer.URL)
+	c.Assert(err, IsNil)
+
+	repo := NewUbuntuStoreSnapRepository()
+	c.Assert(repo, NotNil)
+
+	page, err := repo.FindPage(SearchOptions{})
+	c.Assert(err, IsNil)
+	c.Check(page.Page, Equals, 1)
+	c.Check(page.Pages, Equals, 1)
+	c.Check(page.Parts, HasLen, 1)
+}
+
+func (s *SnapTestSuite) TestUbuntuStoreFindPageBadSort(c *C) {
+	repo := NewUbuntuStoreSnapRepository()
+	c.Assert(repo, NotNil)
+
+	_, err := repo.FindPage(SearchOptions{Sort: "popularity"})
+	c.Check(err, ErrorMatches, `cannot sort search results by "popularity"`)
+}
+
 func (s *SnapTestSuite) TestUbuntuStoreSearchDoesNotMutateSearchURI(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		c.Check(r.URL.RawQuery, Equals, "q=foo")
@@ -579,6 +666,41 @@ func (s *SnapTestSuite) TestUbuntuStoreRepositoryUpdates(c *C) {
 	c.Assert(results[0].Version(), Equals, "42")
 }
 
+func (s *SnapTestSuite) TestUbuntuStoreRepositorySnapUpdate(c *C) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonReq, err := ioutil.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		// only the given snap is sent to the store
+		c.Check(string(jsonReq), Equals, `{"name":["`+funkyAppName+`.`+funkyAppOrigin+`/edge"]}`)
+		io.WriteString(w, MockUpdatesJSON)
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	var err error
+	storeBulkURI, err = url.Parse(mockServer.URL + "/updates/")
+	c.Assert(err, IsNil)
+	repo := NewUbuntuStoreSnapRepository()
+	c.Assert(repo, NotNil)
+
+	// ensure the other installed snaps are not asked for
+	mockActiveSnapIterByType([]string{"foo", "bar"})
+
+	part := NewRemoteSnapPart(remote.Snap{Name: funkyAppName, Origin: funkyAppOrigin, Channel: "edge", Version: "1"})
+	upd, err := repo.SnapUpdate(part)
+	c.Assert(err, IsNil)
+	c.Assert(upd, NotNil)
+	c.Check(upd.Name(), Equals, funkyAppName)
+	c.Check(upd.Version(), Equals, "42")
+
+	// no update if the version is the same
+	part = NewRemoteSnapPart(remote.Snap{Name: funkyAppName, Origin: funkyAppOrigin, Channel: "edge", Version: "42"})
+	upd, err = repo.SnapUpdate(part)
+	c.Assert(err, IsNil)
+	c.Check(upd, IsNil)
+}
+
 func (s *SnapTestSuite) TestUbuntuStoreRepositoryUpdatesNoSnaps(c *C) {
 
 	var err error
@@ -602,7 +724,7 @@ func (s *SnapTestSuite) TestUbuntuStoreRepositoryHeaders(c *C) {
 	req, err := http.NewRequest("GET", "http://example.com", nil)
 	c.Assert(err, IsNil)
 
-	setUbuntuStoreHeaders(req)
+	NewUbuntuStoreSnapRepository().setUbuntuStoreHeaders(req)
 
 	c.Assert(req.Header.Get("X-Ubuntu-Release"), Equals, release.String())
 }
@@ -627,7 +749,7 @@ func (s *SnapTestSuite) TestUbuntuStoreRepositoryDetails(c *C) {
 	c.Assert(snap, NotNil)
 
 	// the actual test
-	result, err := snap.Snap(funkyAppName + "." + funkyAppOrigin)
+	result, err := snap.Snap(funkyAppName + "." + funkyAppOrigin, "")
 	c.Assert(err, IsNil)
 	c.Check(result.Name(), Equals, funkyAppName)
 	c.Check(result.Origin(), Equals, funkyAppOrigin)
@@ -655,7 +777,7 @@ func (s *SnapTestSuite) TestUbuntuStoreRepositoryNoDetails(c *C) {
 	c.Assert(snap, NotNil)
 
 	// the actual test
-	result, err := snap.Snap("no-such-pkg")
+	result, err := snap.Snap("no-such-pkg", "")
 	c.Assert(err, NotNil)
 	c.Assert(result, IsNil)
 }
@@ -943,7 +1065,7 @@ type: gadget
 	c.Assert(repo, NotNil)
 
 	// we just ensure that the right header is set
-	repo.Snap("xkcd")
+	repo.Snap("xkcd", "")
 }
 
 func (s *SnapTestSuite) TestUninstallBuiltIn(c *C) {
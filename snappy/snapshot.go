@@ -0,0 +1,705 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/snap"
+)
+
+// A snapshot is kept in dirs.SnapSnapshotsDir as a tar file named after
+// its id, holding its metadata and then the gzipped tar of the data:
+// the system data under system/ and that of every user under
+// home/<user>/.
+const (
+	snapshotExt      = ".snapshot"
+	snapshotMetaName = "meta.json"
+	snapshotDataName = "data.tar.gz"
+)
+
+// A Snapshot is an archive of the data of a version of a snap, both the
+// system data and that of all the users.
+type Snapshot struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Origin  string    `json:"origin"`
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+	// Users are the users whose data is in the snapshot
+	Users []string `json:"users,omitempty"`
+	// Size is the size of the compressed data
+	Size   int64  `json:"size"`
+	Sha512 string `json:"sha512"`
+	// Expires is when the snapshot is removed, automatic snapshots
+	// expire while those saved by hand are kept
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+func (s *Snapshot) qualifiedName() string {
+	return SnapDataDir{Name: s.Name, Origin: s.Origin}.QualifiedName()
+}
+
+func snapshotPath(id int) string {
+	return filepath.Join(dirs.SnapSnapshotsDir, strconv.Itoa(id)+snapshotExt)
+}
+
+// homesDir returns the directory the homes of the users are in
+func homesDir() string {
+	// dirs.SnapDataHomeGlob is <homes>/*/snaps
+	return filepath.Dir(filepath.Dir(dirs.SnapDataHomeGlob))
+}
+
+// snapshotIDs returns the ids of the snapshots on the system, in order
+func snapshotIDs() ([]int, error) {
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapSnapshotsDir, "*"+snapshotExt))
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0, len(matches))
+	for _, path := range matches {
+		id, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(path), snapshotExt))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
+// Snapshots returns the snapshots of the snaps with the given names, that
+// may be qualified with an origin, or of all snaps if none is given.
+func Snapshots(names []string) ([]*Snapshot, error) {
+	ids, err := snapshotIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []*Snapshot
+	for _, id := range ids {
+		snapshot, err := readSnapshotMeta(snapshotPath(id))
+		if err != nil {
+			return nil, err
+		}
+		snapshot.ID = id
+
+		if len(names) > 0 && !snapshotMatches(snapshot, names) {
+			continue
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	return snapshots, nil
+}
+
+func snapshotMatches(snapshot *Snapshot, names []string) bool {
+	for _, name := range names {
+		if name == snapshot.Name || name == snapshot.qualifiedName() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validatePathElement checks the given value can be used as a single
+// element of a path
+func validatePathElement(what, value string) error {
+	if value == "" || value == "." || strings.Contains(value, "/") || strings.Contains(value, "..") {
+		return fmt.Errorf("invalid %s: %q", what, value)
+	}
+
+	return nil
+}
+
+// validate checks that the metadata of the snapshot, that comes with
+// imported snapshots, cannot point outside of the data directories
+func (s *Snapshot) validate() error {
+	if err := snap.ValidateName(s.Name); err != nil {
+		return err
+	}
+	if s.Origin != "" {
+		if err := snap.ValidateName(s.Origin); err != nil {
+			return fmt.Errorf("invalid origin: %q", s.Origin)
+		}
+	}
+	if err := validatePathElement("version", s.Version); err != nil {
+		return err
+	}
+	for _, user := range s.Users {
+		if err := validatePathElement("user", user); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isBelow returns whether path is inside of dir
+func isBelow(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+// readSnapshotMeta reads the metadata at the start of the snapshot
+func readSnapshotMeta(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != snapshotMetaName {
+		return nil, fmt.Errorf("cannot read snapshot %q: no metadata found", path)
+	}
+
+	var snapshot Snapshot
+	if err := json.NewDecoder(tr).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("cannot read snapshot %q: %v", path, err)
+	}
+
+	return &snapshot, nil
+}
+
+// readSnapshot reads the snapshot at the given path, copying its data to
+// w, and checks the metadata is valid and the data matches its checksum
+func readSnapshot(path string, w io.Writer) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshot *Snapshot
+	hasher := sha512.New()
+	var size int64
+	err = helpers.TarIterate(f, func(tr *tar.Reader, hdr *tar.Header) error {
+		switch hdr.Name {
+		case snapshotMetaName:
+			snapshot = &Snapshot{}
+			return json.NewDecoder(tr).Decode(snapshot)
+		case snapshotDataName:
+			if snapshot == nil {
+				return fmt.Errorf("data before metadata")
+			}
+			n, err := io.Copy(io.MultiWriter(w, hasher), tr)
+			size = n
+			return err
+		default:
+			return fmt.Errorf("unexpected %q", hdr.Name)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snapshot %q: %v", path, err)
+	}
+	if snapshot == nil {
+		return nil, fmt.Errorf("cannot read snapshot %q: no metadata found", path)
+	}
+	if size != snapshot.Size || hex.EncodeToString(hasher.Sum(nil)) != snapshot.Sha512 {
+		return nil, fmt.Errorf("cannot read snapshot %q: data does not match the expected sha512", path)
+	}
+	// the checksum comes with the snapshot, it does not vouch for this
+	if err := snapshot.validate(); err != nil {
+		return nil, fmt.Errorf("cannot read snapshot %q: %v", path, err)
+	}
+
+	return snapshot, nil
+}
+
+// writeSnapshot writes a snapshot with the given metadata and data to a
+// new file at the given path
+func writeSnapshot(path string, snapshot *Snapshot, data io.Reader) (err error) {
+	meta, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	partial := path + ".partial"
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(partial)
+		}
+	}()
+
+	tw := tar.NewWriter(f)
+	now := time.Now()
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    snapshotMetaName,
+		Mode:    0600,
+		Size:    int64(len(meta)),
+		ModTime: now,
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(meta); err != nil {
+		return err
+	}
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    snapshotDataName,
+		Mode:    0600,
+		Size:    snapshot.Size,
+		ModTime: now,
+	}); err != nil {
+		return err
+	}
+	if _, err := io.Copy(tw, data); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+
+	return os.Rename(partial, path)
+}
+
+// newSnapshotPath returns the path and id for a new snapshot
+func newSnapshotPath() (string, int, error) {
+	ids, err := snapshotIDs()
+	if err != nil {
+		return "", 0, err
+	}
+
+	id := 1
+	if len(ids) > 0 {
+		id = ids[len(ids)-1] + 1
+	}
+
+	return snapshotPath(id), id, nil
+}
+
+// snapshotDataVersion finds the qualified name of the snap the part spec,
+// name[.origin][=version], is about and the version of its data to save;
+// without a version that of the active snap.
+func snapshotDataVersion(partSpec string) (fullName, version string, err error) {
+	name := partSpec
+	if idx := strings.IndexRune(partSpec, '='); idx > -1 {
+		name, version = partSpec[:idx], partSpec[idx+1:]
+	}
+
+	if version == "" {
+		installed, err := NewLocalSnapRepository().Installed()
+		if err != nil {
+			return "", "", err
+		}
+		for _, part := range FindSnapsByName(name, installed) {
+			if part.IsActive() {
+				version = part.Version()
+			}
+		}
+		if version == "" {
+			return "", "", fmt.Errorf("cannot snapshot %s: no version given and none is active", name)
+		}
+	}
+
+	fullNames := make(map[string]bool)
+	for _, datadir := range DataDirs(name + "=" + version) {
+		fullName = datadir.QualifiedName()
+		fullNames[fullName] = true
+	}
+	switch len(fullNames) {
+	case 0:
+		return "", "", fmt.Errorf("cannot snapshot %s: no data found for version %s", name, version)
+	case 1:
+		return fullName, version, nil
+	default:
+		return "", "", fmt.Errorf("cannot snapshot %s: more than one snap has that name, use name.origin", name)
+	}
+}
+
+// SaveSnapshot saves the data of the snap given by the part spec,
+// name[.origin][=version], in a new snapshot. Without a version the data
+// of the active version is saved.
+func SaveSnapshot(partSpec string) (*Snapshot, error) {
+	fullName, version, err := snapshotDataVersion(partSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	return saveSnapshot(fullName, version, nil)
+}
+
+// saveSnapshot saves the data of the given version of the snap in a new
+// snapshot that expires at the given time, if any.
+func saveSnapshot(fullName, version string, expires *time.Time) (*Snapshot, error) {
+	if err := os.MkdirAll(dirs.SnapSnapshotsDir, 0700); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.TempFile(dirs.SnapSnapshotsDir, ".data-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(data.Name())
+	defer data.Close()
+
+	name, origin := SplitOrigin(fullName)
+	snapshot := &Snapshot{
+		Name:    name,
+		Origin:  origin,
+		Version: version,
+		Time:    time.Now().UTC(),
+		Expires: expires,
+	}
+
+	dataDirs, err := snapDataDirs(fullName, version)
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := sha512.New()
+	gz := gzip.NewWriter(io.MultiWriter(data, hasher))
+	tw := tar.NewWriter(gz)
+	for _, dir := range dataDirs {
+		if !helpers.IsDirectory(dir) {
+			continue
+		}
+
+		prefix := "system"
+		if !strings.HasPrefix(dir, dirs.SnapDataDir+"/") {
+			// <homes>/<user>/snaps/<fullName>/<version>
+			user := filepath.Base(filepath.Dir(filepath.Dir(filepath.Dir(dir))))
+			prefix = filepath.Join("home", user)
+			snapshot.Users = append(snapshot.Users, user)
+		}
+
+		if err := tarDir(tw, dir, prefix); err != nil {
+			return nil, fmt.Errorf("cannot snapshot %s: %v", fullName, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	snapshot.Sha512 = hex.EncodeToString(hasher.Sum(nil))
+	if snapshot.Size, err = data.Seek(0, 1); err != nil {
+		return nil, err
+	}
+	if _, err := data.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	path, id, err := newSnapshotPath()
+	if err != nil {
+		return nil, err
+	}
+	snapshot.ID = id
+	if err := writeSnapshot(path, snapshot, data); err != nil {
+		return nil, fmt.Errorf("cannot snapshot %s: %v", fullName, err)
+	}
+
+	return snapshot, nil
+}
+
+// tarDir adds the contents of the given directory to the tar, under the
+// given prefix
+func tarDir(tw *tar.Writer, root, prefix string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// sockets and pipes only make sense while the snap is running
+		if info.Mode()&(os.ModeSocket|os.ModeNamedPipe) != 0 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if helpers.IsSymlink(info.Mode()) {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.Join(prefix, rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+
+		return err
+	})
+}
+
+// checkSnapshotPaths makes sure nothing in a snapshot is unpacked outside
+// of the given directory, be it through .. or through a symlink
+func checkSnapshotPaths(root string) helpers.UnpackTarTransformFunc {
+	return func(path string) (string, error) {
+		clean := filepath.Clean(path)
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return "", fmt.Errorf("invalid path %q", path)
+		}
+
+		for dir := filepath.Dir(clean); dir != "."; dir = filepath.Dir(dir) {
+			fi, err := os.Lstat(filepath.Join(root, dir))
+			if err == nil && helpers.IsSymlink(fi.Mode()) {
+				return "", fmt.Errorf("invalid path %q", path)
+			}
+		}
+
+		return clean, nil
+	}
+}
+
+// unpackSnapshotData unpacks the data saved in a snapshot into the given
+// directory, with its original ownership if run as root
+func unpackSnapshotData(dataPath, targetDir string) error {
+	unpack := func(fn func(io.Reader) error) error {
+		f, err := os.Open(dataPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+
+		return fn(gz)
+	}
+
+	if err := unpack(func(r io.Reader) error {
+		return helpers.UnpackTar(r, targetDir, checkSnapshotPaths(targetDir))
+	}); err != nil {
+		return err
+	}
+
+	if os.Getuid() != 0 {
+		return nil
+	}
+
+	return unpack(func(r io.Reader) error {
+		return helpers.TarIterate(r, func(tr *tar.Reader, hdr *tar.Header) error {
+			return os.Lchown(filepath.Join(targetDir, filepath.Clean(hdr.Name)), hdr.Uid, hdr.Gid)
+		})
+	})
+}
+
+// RestoreSnapshot replaces the data of the version of the snap the
+// snapshot was saved from, or of the given version, with the data saved
+// in the snapshot. The data of users that are no longer on the system is
+// not restored.
+func RestoreSnapshot(id int, version string) error {
+	if version != "" {
+		if err := validatePathElement("version", version); err != nil {
+			return fmt.Errorf("cannot restore snapshot %d: %v", id, err)
+		}
+	}
+
+	path := snapshotPath(id)
+	if !helpers.FileExists(path) {
+		return ErrSnapshotNotFound
+	}
+
+	staging, err := ioutil.TempDir(dirs.SnapSnapshotsDir, ".restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	dataPath := filepath.Join(staging, snapshotDataName)
+	data, err := os.Create(dataPath)
+	if err != nil {
+		return err
+	}
+	snapshot, err := readSnapshot(path, data)
+	data.Close()
+	if err != nil {
+		return err
+	}
+
+	unpacked := filepath.Join(staging, "data")
+	if err := unpackSnapshotData(dataPath, unpacked); err != nil {
+		return fmt.Errorf("cannot restore snapshot %d: %v", id, err)
+	}
+
+	if version == "" {
+		version = snapshot.Version
+	}
+	fullName := snapshot.qualifiedName()
+
+	targets := map[string]string{
+		filepath.Join(unpacked, "system"): filepath.Join(dirs.SnapDataDir, fullName, version),
+	}
+	for _, user := range snapshot.Users {
+		home := filepath.Join(homesDir(), user)
+		if !helpers.IsDirectory(home) {
+			continue
+		}
+		targets[filepath.Join(unpacked, "home", user)] = filepath.Join(home, "snaps", fullName, version)
+	}
+
+	for staged, target := range targets {
+		if !helpers.IsDirectory(staged) {
+			continue
+		}
+		if !isBelow(target, dirs.SnapDataDir) && !isBelow(target, homesDir()) {
+			return fmt.Errorf("cannot restore snapshot %d: invalid target %q", id, target)
+		}
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := copySnapDataDirectory(staged, target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expireSnapshots removes the snapshots that expired, on a dry run they
+// are only returned. It is best effort: snapshots that cannot be read or
+// removed are skipped and logged about.
+func expireSnapshots(dryRun bool) []*Snapshot {
+	ids, err := snapshotIDs()
+	if err != nil {
+		logger.Noticef("Cannot expire snapshots: %v", err)
+		return nil
+	}
+
+	now := time.Now()
+	var expired []*Snapshot
+	for _, id := range ids {
+		snapshot, err := readSnapshotMeta(snapshotPath(id))
+		if err != nil {
+			logger.Noticef("Skipping the expiry of snapshot %d: %v", id, err)
+			continue
+		}
+		snapshot.ID = id
+
+		if snapshot.Expires == nil || snapshot.Expires.After(now) {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(snapshotPath(id)); err != nil {
+				logger.Noticef("Cannot remove expired snapshot %d: %v", id, err)
+				continue
+			}
+		}
+		expired = append(expired, snapshot)
+	}
+
+	return expired
+}
+
+// SnapshotFile checks the snapshot with the given id and returns the path
+// to its file, for it to be exported.
+func SnapshotFile(id int) (string, error) {
+	path := snapshotPath(id)
+	if !helpers.FileExists(path) {
+		return "", ErrSnapshotNotFound
+	}
+	if _, err := readSnapshot(path, ioutil.Discard); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// ImportSnapshot checks the exported snapshot at the given path and adds
+// it to the snapshots of the system, under a new id.
+func ImportSnapshot(path string) (*Snapshot, error) {
+	if err := os.MkdirAll(dirs.SnapSnapshotsDir, 0700); err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.TempFile(dirs.SnapSnapshotsDir, ".data-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(data.Name())
+	defer data.Close()
+
+	snapshot, err := readSnapshot(path, data)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := data.Seek(0, 0); err != nil {
+		return nil, err
+	}
+
+	target, id, err := newSnapshotPath()
+	if err != nil {
+		return nil, err
+	}
+	snapshot.ID = id
+	if err := writeSnapshot(target, snapshot, data); err != nil {
+		return nil, fmt.Errorf("cannot import snapshot %q: %v", path, err)
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,300 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+	"github.com/ubuntu-core/snappy/systemd"
+)
+
+type snapshotSuite struct {
+	tempdir string
+}
+
+var _ = Suite(&snapshotSuite{})
+
+func (s *snapshotSuite) SetUpTest(c *C) {
+	s.tempdir = c.MkDir()
+	dirs.SetRootDir(s.tempdir)
+	os.MkdirAll(dirs.SnapMetaDir, 0755)
+	os.MkdirAll(filepath.Join(dirs.SnapServicesDir, "multi-user.target.wants"), 0755)
+	systemd.SystemctlCmd = func(cmd ...string) ([]byte, error) {
+		return []byte("ActiveState=inactive\n"), nil
+	}
+
+	dirs.SnapSeccompDir = c.MkDir()
+	dirs.SnapAppArmorDir = c.MkDir()
+
+	runAppArmorParser = mockRunAppArmorParser
+
+	makeMockSecurityEnv(c)
+}
+
+// mkpkg installs hello-app with some system data and some data of user1
+func (s *snapshotSuite) mkpkg(c *C, version string) (systemDir, homeDir string, part *SnapPart) {
+	app := "hello-app." + testOrigin
+	yamlFile, err := makeInstalledMockSnap(s.tempdir, "name: hello-app\nversion: "+version+"\n")
+	c.Assert(err, IsNil)
+
+	systemDir = filepath.Join(dirs.SnapDataDir, app, version)
+	c.Assert(os.MkdirAll(filepath.Join(systemDir, "db"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(systemDir, "db", "data"), []byte("system"), 0640), IsNil)
+	c.Assert(os.Symlink("db/data", filepath.Join(systemDir, "link")), IsNil)
+
+	homeDir = filepath.Join(s.tempdir, "home", "user1", "snaps", app, version)
+	c.Assert(os.MkdirAll(homeDir, 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(homeDir, "prefs"), []byte("user1"), 0600), IsNil)
+
+	part, err = NewInstalledSnapPart(yamlFile, testOrigin)
+	c.Assert(err, IsNil)
+
+	return systemDir, homeDir, part
+}
+
+func (s *snapshotSuite) TestSaveListRestore(c *C) {
+	systemDir, homeDir, part := s.mkpkg(c, "1.0")
+	c.Assert(part.activate(true, &MockProgressMeter{}), IsNil)
+
+	snapshot, err := SaveSnapshot("hello-app")
+	c.Assert(err, IsNil)
+	c.Check(snapshot.ID, Equals, 1)
+	c.Check(snapshot.Name, Equals, "hello-app")
+	c.Check(snapshot.Origin, Equals, testOrigin)
+	c.Check(snapshot.Version, Equals, "1.0")
+	c.Check(snapshot.Users, DeepEquals, []string{"user1"})
+	c.Check(snapshot.Sha512, HasLen, 128)
+
+	snapshots, err := Snapshots(nil)
+	c.Assert(err, IsNil)
+	c.Assert(snapshots, HasLen, 1)
+	c.Check(snapshots[0], DeepEquals, snapshot)
+
+	// the data changes after the snapshot
+	c.Assert(os.Remove(filepath.Join(systemDir, "db", "data")), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(systemDir, "junk"), nil, 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(homeDir, "prefs"), []byte("changed"), 0600), IsNil)
+
+	c.Assert(RestoreSnapshot(1, ""), IsNil)
+
+	content, err := ioutil.ReadFile(filepath.Join(systemDir, "link"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "system")
+	fi, err := os.Stat(filepath.Join(systemDir, "db", "data"))
+	c.Assert(err, IsNil)
+	c.Check(fi.Mode().Perm(), Equals, os.FileMode(0640))
+	c.Check(helpers.FileExists(filepath.Join(systemDir, "junk")), Equals, false)
+	content, err = ioutil.ReadFile(filepath.Join(homeDir, "prefs"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "user1")
+}
+
+func (s *snapshotSuite) TestRestoreIntoOtherVersion(c *C) {
+	s.mkpkg(c, "1.0")
+
+	_, err := SaveSnapshot("hello-app." + testOrigin + "=1.0")
+	c.Assert(err, IsNil)
+
+	c.Assert(RestoreSnapshot(1, "2.0"), IsNil)
+
+	content, err := ioutil.ReadFile(filepath.Join(dirs.SnapDataDir, "hello-app."+testOrigin, "2.0", "db", "data"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "system")
+	content, err = ioutil.ReadFile(filepath.Join(s.tempdir, "home", "user1", "snaps", "hello-app."+testOrigin, "2.0", "prefs"))
+	c.Assert(err, IsNil)
+	c.Check(string(content), Equals, "user1")
+}
+
+func (s *snapshotSuite) TestSaveNeedsAVersion(c *C) {
+	s.mkpkg(c, "1.0")
+
+	_, err := SaveSnapshot("hello-app")
+	c.Check(err, ErrorMatches, "cannot snapshot hello-app: no version given and none is active")
+
+	_, err = SaveSnapshot("hello-app=2.0")
+	c.Check(err, ErrorMatches, "cannot snapshot hello-app: no data found for version 2.0")
+}
+
+func (s *snapshotSuite) TestRestoreNotFound(c *C) {
+	c.Check(RestoreSnapshot(42, ""), Equals, ErrSnapshotNotFound)
+
+	_, err := SnapshotFile(42)
+	c.Check(err, Equals, ErrSnapshotNotFound)
+}
+
+func (s *snapshotSuite) TestExportImport(c *C) {
+	s.mkpkg(c, "1.0")
+
+	saved, err := SaveSnapshot("hello-app=1.0")
+	c.Assert(err, IsNil)
+
+	path, err := SnapshotFile(saved.ID)
+	c.Assert(err, IsNil)
+	exported := filepath.Join(c.MkDir(), "hello-app.snapshot")
+	c.Assert(helpers.CopyFile(path, exported, 0), IsNil)
+
+	imported, err := ImportSnapshot(exported)
+	c.Assert(err, IsNil)
+	c.Check(imported.ID, Equals, 2)
+	c.Check(imported.Sha512, Equals, saved.Sha512)
+	c.Check(imported.Time.Equal(saved.Time), Equals, true)
+
+	snapshots, err := Snapshots([]string{"hello-app." + testOrigin})
+	c.Assert(err, IsNil)
+	c.Check(snapshots, HasLen, 2)
+
+	snapshots, err = Snapshots([]string{"other"})
+	c.Assert(err, IsNil)
+	c.Check(snapshots, HasLen, 0)
+}
+
+func (s *snapshotSuite) TestImportChecksumMismatch(c *C) {
+	bad := filepath.Join(c.MkDir(), "bad.snapshot")
+	data := []byte("not the data")
+	err := writeSnapshot(bad, &Snapshot{Name: "foo", Size: int64(len(data)), Sha512: "0000"}, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+
+	_, err = ImportSnapshot(bad)
+	c.Check(err, ErrorMatches, `cannot read snapshot ".*/bad.snapshot": data does not match the expected sha512`)
+
+	ids, err := snapshotIDs()
+	c.Assert(err, IsNil)
+	c.Check(ids, HasLen, 0)
+}
+
+// writeBadSnapshot writes a snapshot with a matching checksum but the
+// given metadata
+func writeBadSnapshot(c *C, path string, snapshot Snapshot) {
+	data := []byte("some data")
+	sum := sha512.Sum512(data)
+	snapshot.Size = int64(len(data))
+	snapshot.Sha512 = hex.EncodeToString(sum[:])
+	c.Assert(writeSnapshot(path, &snapshot, bytes.NewReader(data)), IsNil)
+}
+
+func (s *snapshotSuite) TestImportInvalidMetadata(c *C) {
+	for i, snapshot := range []Snapshot{
+		{Name: "../../..", Version: "1.0"},
+		{Name: "foo", Origin: "../..", Version: "1.0"},
+		{Name: "foo", Version: "../../.."},
+		{Name: "foo", Version: "a/b"},
+		{Name: "foo", Version: ""},
+		{Name: "foo", Version: "1.0", Users: []string{"../.."}},
+		{Name: "foo", Version: "1.0", Users: []string{"user1/../.."}},
+	} {
+		bad := filepath.Join(c.MkDir(), "bad.snapshot")
+		writeBadSnapshot(c, bad, snapshot)
+
+		_, err := ImportSnapshot(bad)
+		c.Check(err, ErrorMatches, `cannot read snapshot ".*/bad.snapshot": invalid .*`, Commentf("%d", i))
+	}
+
+	ids, err := snapshotIDs()
+	c.Assert(err, IsNil)
+	c.Check(ids, HasLen, 0)
+}
+
+func (s *snapshotSuite) TestRestoreInvalid(c *C) {
+	s.mkpkg(c, "1.0")
+
+	_, err := SaveSnapshot("hello-app=1.0")
+	c.Assert(err, IsNil)
+
+	for _, version := range []string{"../../..", "..", "a/b"} {
+		err := RestoreSnapshot(1, version)
+		c.Check(err, ErrorMatches, `cannot restore snapshot 1: invalid version: .*`, Commentf(version))
+	}
+
+	// a snapshot that did not come through ImportSnapshot is checked too
+	c.Assert(os.MkdirAll(dirs.SnapSnapshotsDir, 0700), IsNil)
+	writeBadSnapshot(c, snapshotPath(2), Snapshot{Name: "hello-app", Version: "../../.."})
+	err = RestoreSnapshot(2, "")
+	c.Check(err, ErrorMatches, `cannot read snapshot ".*": invalid version: .*`)
+}
+
+func (s *snapshotSuite) TestCheckSnapshotPaths(c *C) {
+	root := c.MkDir()
+	c.Assert(os.Symlink("/etc", filepath.Join(root, "link")), IsNil)
+	check := checkSnapshotPaths(root)
+
+	path, err := check("system/./db/data")
+	c.Assert(err, IsNil)
+	c.Check(path, Equals, "system/db/data")
+
+	for _, path := range []string{"../etc/passwd", "system/../../x", "/etc/passwd", "link/passwd"} {
+		_, err := check(path)
+		c.Check(err, ErrorMatches, "invalid path .*", Commentf(path))
+	}
+}
+
+func (s *snapshotSuite) TestCollectGarbageExpiresSnapshots(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapSnapsDir, 0755), IsNil)
+	c.Assert(os.MkdirAll(dirs.SnapSnapshotsDir, 0700), IsNil)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+	for i, expires := range []*time.Time{&past, &future, nil} {
+		snapshot := &Snapshot{Name: "foo", Size: 4, Expires: expires}
+		c.Assert(writeSnapshot(snapshotPath(i+1), snapshot, bytes.NewReader([]byte("data"))), IsNil)
+	}
+
+	report, err := CollectGarbage(nil, DoGCDryRun, &MockProgressMeter{})
+	c.Assert(err, IsNil)
+	c.Check(report.ExpiredSnapshots, DeepEquals, []int{1})
+	c.Check(report.Reclaimed, Equals, int64(4))
+	c.Check(helpers.FileExists(snapshotPath(1)), Equals, true)
+
+	report, err = CollectGarbage(nil, 0, &MockProgressMeter{})
+	c.Assert(err, IsNil)
+	c.Check(report.ExpiredSnapshots, DeepEquals, []int{1})
+
+	ids, err := snapshotIDs()
+	c.Assert(err, IsNil)
+	c.Check(ids, DeepEquals, []int{2, 3})
+}
+
+func (s *snapshotSuite) TestExpireSnapshotsSkipsUnreadable(c *C) {
+	c.Assert(os.MkdirAll(dirs.SnapSnapsDir, 0755), IsNil)
+	c.Assert(os.MkdirAll(dirs.SnapSnapshotsDir, 0700), IsNil)
+	past := time.Now().Add(-time.Hour)
+	c.Assert(ioutil.WriteFile(snapshotPath(1), []byte("garbage"), 0600), IsNil)
+	c.Assert(writeSnapshot(snapshotPath(2), &Snapshot{Name: "foo", Size: 4, Expires: &past}, bytes.NewReader([]byte("data"))), IsNil)
+
+	expired := expireSnapshots(false)
+	c.Assert(expired, HasLen, 1)
+	c.Check(expired[0].ID, Equals, 2)
+
+	// the unreadable snapshot is left alone
+	ids, err := snapshotIDs()
+	c.Assert(err, IsNil)
+	c.Check(ids, DeepEquals, []int{1})
+
+	// and it does not get in the way of the gc after an install
+	c.Check(GarbageCollect("foo", DoInstallGC, &MockProgressMeter{}), IsNil)
+}
@@ -0,0 +1,225 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/snap"
+)
+
+// spaceNeed is the space needed on a single filesystem
+type spaceNeed struct {
+	path string
+	size uint64
+}
+
+// spaceNeeds adds up the space needed per filesystem
+type spaceNeeds map[uint64]*spaceNeed
+
+func (needs spaceNeeds) add(path string, size uint64) error {
+	path = existingParent(path)
+
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return err
+	}
+
+	dev := uint64(st.Dev)
+	if need, ok := needs[dev]; ok {
+		need.size += size
+	} else {
+		needs[dev] = &spaceNeed{path: path, size: size}
+	}
+
+	return nil
+}
+
+// existingParent returns path, or its closest parent that exists
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// treeSize returns the apparent size of all the files below path
+func treeSize(path string) (uint64, error) {
+	var size uint64
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += uint64(info.Size())
+		}
+		return nil
+	})
+
+	return size, err
+}
+
+// diskUsage returns the disk space used by path and everything below it,
+// like du does: sparse files only count the blocks they use, and files
+// with several hard links are only counted once
+func diskUsage(path string) (uint64, error) {
+	type inode struct {
+		dev uint64
+		ino uint64
+	}
+	seen := make(map[inode]bool)
+
+	var usage uint64
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			usage += uint64(info.Size())
+			return nil
+		}
+		if st.Nlink > 1 && !info.IsDir() {
+			ino := inode{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+			if seen[ino] {
+				return nil
+			}
+			seen[ino] = true
+		}
+		// st_blocks is in units of 512 bytes
+		usage += uint64(st.Blocks) * 512
+		return nil
+	})
+
+	return usage, err
+}
+
+// checkDiskSpace checks that there is enough free space for installing
+// the given snap: its blob, the kernel assets extracted to the boot
+// partition (if any), and a copy of the data of
+// the currently active version (if any).
+func checkDiskSpace(s *SnapFile, currentActiveDir string) error {
+	needs := make(spaceNeeds)
+
+	size := uint64(s.InstalledSize())
+	if err := needs.add(dirs.SnapBlobDir, size); err != nil {
+		return err
+	}
+
+	if s.Type() == snap.TypeKernel {
+		if bootloader, err := findBootloader(); err == nil {
+			assetsSize, err := kernelAssetsSize(s)
+			if err != nil {
+				return err
+			}
+			if err := needs.add(bootloader.Dir(), assetsSize); err != nil {
+				return err
+			}
+		}
+	}
+
+	if currentActiveDir != "" {
+		dataDirs, err := snapDataDirs(QualifiedName(s), filepath.Base(currentActiveDir))
+		if err != nil {
+			return err
+		}
+		for _, dataDir := range dataDirs {
+			size, err := treeSize(dataDir)
+			if err != nil {
+				return err
+			}
+			if err := needs.add(dataDir, size); err != nil {
+				return err
+			}
+		}
+	}
+
+	return needs.check(s.Name())
+}
+
+// checkDownloadSpace checks that there is enough free space in the
+// download cache for what is left to download of the given snap.
+func checkDownloadSpace(remoteSnap *RemoteSnapPart, partial string) error {
+	size := uint64(remoteSnap.pkg.DownloadSize)
+	if st, err := os.Stat(partial); err == nil {
+		if uint64(st.Size()) >= size {
+			return nil
+		}
+		size -= uint64(st.Size())
+	}
+
+	needs := make(spaceNeeds)
+	if err := needs.add(dirs.SnapDownloadCacheDir, size); err != nil {
+		return err
+	}
+
+	return needs.check(remoteSnap.Name())
+}
+
+// check checks that every filesystem has the space needed on it free,
+// for the given snap.
+func (needs spaceNeeds) check(snapName string) error {
+	// check in a stable order
+	devs := make([]uint64, 0, len(needs))
+	for dev := range needs {
+		devs = append(devs, dev)
+	}
+	sort.Sort(uint64Slice(devs))
+
+	for _, dev := range devs {
+		need := needs[dev]
+		free, err := freeSpace(need.path)
+		if err != nil {
+			return err
+		}
+		if need.size > free {
+			return &ErrInsufficientSpace{
+				Snap:   snapName,
+				Path:   need.path,
+				Needed: need.size,
+				Free:   free,
+			}
+		}
+	}
+
+	return nil
+}
+
+type uint64Slice []uint64
+
+func (s uint64Slice) Len() int           { return len(s) }
+func (s uint64Slice) Less(i, j int) bool { return s[i] < s[j] }
+func (s uint64Slice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,177 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/ubuntu-core/snappy/dirs"
+	"github.com/ubuntu-core/snappy/helpers"
+)
+
+func mockFreeSpace(free uint64) (restore func()) {
+	orig := freeSpace
+	freeSpace = func(path string) (uint64, error) {
+		return free, nil
+	}
+
+	return func() { freeSpace = orig }
+}
+
+func fileSize(c *C, path string) uint64 {
+	st, err := os.Stat(path)
+	c.Assert(err, IsNil)
+	return uint64(st.Size())
+}
+
+func (s *SnapTestSuite) TestSnapFileInstalledSize(c *C) {
+	snapFile := makeTestSnapPackage(c, "")
+	part, err := NewSnapFile(snapFile, testOrigin, true)
+	c.Assert(err, IsNil)
+	c.Check(part.InstalledSize(), Equals, int64(fileSize(c, snapFile)))
+}
+
+func (s *SnapTestSuite) TestInstallFailsWithoutSpaceForTheSnap(c *C) {
+	defer mockFreeSpace(10)()
+
+	snapFile := makeTestSnapPackage(c, "name: foo\nversion: 1.0\n")
+	_, err := installClick(snapFile, AllowUnauthenticated, &MockProgressMeter{}, testOrigin)
+	c.Assert(err, DeepEquals, &ErrInsufficientSpace{
+		Snap:   "foo",
+		Path:   existingParent(dirs.SnapBlobDir),
+		Needed: fileSize(c, snapFile),
+		Free:   10,
+	})
+
+	// nothing got installed
+	c.Check(helpers.FileExists(filepath.Join(dirs.SnapSnapsDir, "foo."+testOrigin)), Equals, false)
+}
+
+func (s *SnapTestSuite) TestInstallCountsTheDataToCopy(c *C) {
+	s.installFooWithData(c)
+	big := filepath.Join(dirs.SnapDataDir, "foo."+testOrigin, "1.0", "big")
+	c.Assert(ioutil.WriteFile(big, make([]byte, 10000), 0644), IsNil)
+	dataSize := uint64(10000 + len("ni ni ni"))
+
+	snapFile := makeTestSnapPackage(c, "name: foo\nversion: 2.0\n")
+	needed := fileSize(c, snapFile) + dataSize
+
+	// the snap alone fits, but not together with the copy of its data
+	restore := mockFreeSpace(needed - 1)
+	_, err := installClick(snapFile, AllowUnauthenticated, &MockProgressMeter{}, testOrigin)
+	restore()
+	c.Assert(err, FitsTypeOf, &ErrInsufficientSpace{})
+	c.Check(err.(*ErrInsufficientSpace).Needed, Equals, needed)
+
+	defer mockFreeSpace(needed)()
+	_, err = installClick(snapFile, AllowUnauthenticated, &MockProgressMeter{}, testOrigin)
+	c.Assert(err, IsNil)
+}
+
+func (s *SnapTestSuite) TestTreeSize(c *C) {
+	dir := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(dir, "a", "b"), 0755), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "a", "one"), make([]byte, 100), 0644), IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "a", "b", "two"), make([]byte, 23), 0644), IsNil)
+
+	size, err := treeSize(dir)
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, uint64(123))
+
+	size, err = treeSize(filepath.Join(dir, "not-there"))
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, uint64(0))
+}
+
+func (s *SnapTestSuite) TestDiskUsage(c *C) {
+	dir := c.MkDir()
+	empty, err := diskUsage(dir)
+	c.Assert(err, IsNil)
+
+	data := filepath.Join(dir, "data")
+	c.Assert(ioutil.WriteFile(data, make([]byte, 10000), 0644), IsNil)
+	withData, err := diskUsage(dir)
+	c.Assert(err, IsNil)
+	c.Check(withData-empty >= 10000, Equals, true)
+
+	// hard links are only counted once
+	c.Assert(os.Link(data, filepath.Join(dir, "link")), IsNil)
+	usage, err := diskUsage(dir)
+	c.Assert(err, IsNil)
+	c.Check(usage, Equals, withData)
+
+	// sparse files only count the blocks they use
+	f, err := os.Create(filepath.Join(dir, "sparse"))
+	c.Assert(err, IsNil)
+	c.Assert(f.Truncate(100*1024*1024), IsNil)
+	c.Assert(f.Close(), IsNil)
+	usage, err = diskUsage(dir)
+	c.Assert(err, IsNil)
+	c.Check(usage < 1024*1024, Equals, true)
+
+	usage, err = diskUsage(filepath.Join(dir, "not-there"))
+	c.Assert(err, IsNil)
+	c.Check(usage, Equals, uint64(0))
+}
+
+func (s *SnapTestSuite) TestExistingParent(c *C) {
+	dir := c.MkDir()
+	c.Check(existingParent(dir), Equals, dir)
+	c.Check(existingParent(filepath.Join(dir, "x", "y")), Equals, dir)
+}
+
+func (s *SnapTestSuite) TestKernelAssetsSize(c *C) {
+	files := [][]string{
+		{"vmlinuz-4.2", "I'm a kernel"},
+		{"initrd.img-4.2", "...and I'm an initrd"},
+		{"big-unrelated-file", string(make([]byte, 10000))},
+	}
+	snapPkg := makeTestSnapPackageWithFiles(c, packageKernel, files)
+	part, err := NewSnapFile(snapPkg, testOrigin, true)
+	c.Assert(err, IsNil)
+
+	// only the kernel and the initrd get extracted
+	size, err := kernelAssetsSize(part)
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, uint64(len(files[0][1])+len(files[1][1])))
+}
+
+func (s *SnapTestSuite) TestKernelAssetsSizeGrub(c *C) {
+	origGetGadget := getGadget
+	defer func() { getGadget = origGetGadget }()
+	getGadget = getFakeGrubGadget
+
+	files := [][]string{
+		{"vmlinuz-4.2", "I'm a kernel"},
+		{"initrd.img-4.2", "...and I'm an initrd"},
+	}
+	snapPkg := makeTestSnapPackageWithFiles(c, packageKernel, files)
+	part, err := NewSnapFile(snapPkg, testOrigin, true)
+	c.Assert(err, IsNil)
+
+	// grub boots the kernel from the snap, nothing gets extracted
+	size, err := kernelAssetsSize(part)
+	c.Assert(err, IsNil)
+	c.Check(size, Equals, uint64(0))
+}
@@ -0,0 +1,135 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2016 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snappy
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/ubuntu-core/snappy/asserts"
+	"github.com/ubuntu-core/snappy/logger"
+	"github.com/ubuntu-core/snappy/progress"
+)
+
+// StoreRepository is the interface of the places snaps are installed and
+// updated from.
+type StoreRepository interface {
+	// Snap returns the details of the snap with the given name in the
+	// given channel, the name may be qualified with an origin. An empty
+	// channel selects the default channel of the store.
+	Snap(name, channel string) (*RemoteSnapPart, error)
+
+	// Details returns the details of the given snap.
+	Details(name, origin string) ([]Part, error)
+
+	// Find returns the installable snaps matching the given search term.
+	Find(searchTerm string) ([]Part, error)
+
+	// FindPage returns the given page of the installable snaps matching
+	// the search options.
+	FindPage(opts SearchOptions) (*SearchPage, error)
+
+	// Updates returns the available updates of the installed snaps.
+	Updates() ([]Part, error)
+
+	// SnapUpdate returns the available update of the given installed
+	// snap, or nil if there is none.
+	SnapUpdate(part Part) (*RemoteSnapPart, error)
+
+	// Download makes the given snap available locally and returns the
+	// path to it.
+	Download(remoteSnap *RemoteSnapPart, pbar progress.Meter) (string, error)
+}
+
+var (
+	_ StoreRepository = (*SnapUbuntuStoreRepository)(nil)
+	_ StoreRepository = (*SnapDirStoreRepository)(nil)
+
+	_ assertionsRepository = (*SnapDirStoreRepository)(nil)
+)
+
+// assertionsRepository is implemented by the stores that ship the
+// assertions about their snaps alongside them.
+type assertionsRepository interface {
+	// Assertions returns the assertions about the given snap, in the
+	// order they have to be added to the assertion database.
+	Assertions(remoteSnap *RemoteSnapPart) ([]asserts.Assertion, error)
+}
+
+// addStoreAssertions adds the assertions the store ships about the given
+// snap to the system assertion database, if it ships any
+func addStoreAssertions(mStore StoreRepository, remoteSnap *RemoteSnapPart) error {
+	aStore, ok := mStore.(assertionsRepository)
+	if !ok {
+		return nil
+	}
+	assertions, err := aStore.Assertions(remoteSnap)
+	if err != nil || len(assertions) == 0 {
+		return err
+	}
+
+	if err := addAssertions(assertions); err != nil {
+		return fmt.Errorf("cannot add assertions of %s: %v", remoteSnap.Name(), err)
+	}
+
+	return nil
+}
+
+// addAssertions adds the given assertions to the system assertion
+// database, skipping the ones it already has
+var addAssertions = func(assertions []asserts.Assertion) error {
+	db, err := asserts.OpenSysDatabase("")
+	if err != nil {
+		return err
+	}
+
+	for _, a := range assertions {
+		primaryKey := make(map[string]string, len(a.Type().PrimaryKey))
+		for _, k := range a.Type().PrimaryKey {
+			primaryKey[k] = a.Header(k)
+		}
+		if cur, err := db.Find(a.Type(), primaryKey); err == nil && cur.Revision() >= a.Revision() {
+			continue
+		}
+		if err := db.Add(a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// NewStoreRepository returns the store snaps are installed from.
+//
+// This is the ubuntu store unless SNAPPY_LOCAL_STORE points to a
+// directory of snaps, see SnapDirStoreRepository. The environment is only
+// consulted on devices without a model assertion, the store of the others
+// is the one their model selects.
+var NewStoreRepository = func() StoreRepository {
+	if dir := os.Getenv("SNAPPY_LOCAL_STORE"); dir != "" {
+		_, baseURI, err := modelStore()
+		if err == nil && baseURI == nil {
+			return NewDirStoreSnapRepository(dir)
+		}
+		logger.Noticef("Ignoring SNAPPY_LOCAL_STORE: the store is selected by the model assertion")
+	}
+
+	return NewUbuntuStoreSnapRepository()
+}